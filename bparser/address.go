@@ -0,0 +1,42 @@
+package bparser
+
+import (
+	"github.com/davidhintelmann/blockchain/bparser/script"
+)
+
+/*
+Address method returns the address paid by scriptPubKey on the network, or an empty string when the script has no address,
+which matches the address field of bitcoin-core's RPCs.
+
+P2PKH and P2SH outputs are Base58Check encoded with PubKeyHashAddrID and ScriptHashAddrID, v0 witness outputs are bech32
+and taproot, anchor and unknown witness versions are bech32m encoded with Bech32HRP. P2PK, bare multisig, OP_RETURN and
+non-standard outputs have no address.
+*/
+func (n *Network) Address(scriptPubKey []byte) string {
+	switch script.Classify(scriptPubKey) {
+	case script.PubKeyHash:
+		return script.EncodeBase58Check(n.PubKeyHashAddrID, scriptPubKey[3:23])
+	case script.ScriptHash:
+		return script.EncodeBase58Check(n.ScriptHashAddrID, scriptPubKey[2:22])
+	case script.WitnessV0KeyHash, script.WitnessV0ScriptHash, script.WitnessV1Taproot, script.Anchor, script.WitnessUnknown:
+		version, program, _ := script.IsWitnessProgram(scriptPubKey)
+		address, err := script.EncodeSegWitAddress(n.Bech32HRP, version, program)
+		if err != nil {
+			return ""
+		}
+		return address
+	}
+	return ""
+}
+
+/*
+setAddresses function sets the address of every output of the block for network net.
+*/
+func setAddresses(block *BlockData, net *Network) {
+	for i := range block.Tx.Tx {
+		outputs := block.Tx.Tx[i].Outputs
+		for j := range outputs {
+			outputs[j].Address = net.Address(outputs[j].ScriptPubKey)
+		}
+	}
+}
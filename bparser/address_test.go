@@ -0,0 +1,61 @@
+package bparser_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+	"github.com/davidhintelmann/blockchain/bparser/script"
+)
+
+func TestNetworkAddress(t *testing.T) {
+	tests := []struct {
+		name   string
+		net    *bparser.Network
+		script string
+		want   string
+	}{
+		{"p2pkh main", &bparser.MainNet, "76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+		{"p2pkh testnet", &bparser.TestNet3, "76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac", "mpXwg4jMtRhuSpVq4xS3HFHmCmWp9NyGKt"},
+		{"p2sh main", &bparser.MainNet, "a9140000000000000000000000000000000000000000" + "87", "31h1vYVSYuKP6AhS86fbRdMw9XHieotbST"},
+		{"p2wpkh main", &bparser.MainNet, "0014751e76e8199196d454941c45d1b3a323f1433bd6", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{"p2wpkh regtest", &bparser.RegTest, "0014751e76e8199196d454941c45d1b3a323f1433bd6", "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080"},
+		{"p2wsh testnet", &bparser.TestNet4, "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7"},
+		{"p2tr main", &bparser.MainNet, "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+		{"p2pk has no address", &bparser.MainNet, "2102" + "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798" + "ac", ""},
+		{"op_return has no address", &bparser.MainNet, "6a0100", ""},
+	}
+
+	for _, test := range tests {
+		b, _ := hex.DecodeString(test.script)
+		if got := test.net.Address(b); got != test.want {
+			t.Errorf("Address() %s got = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+/*
+test ParseBlock labels the genesis coinbase output as P2PK, which has no address
+*/
+func TestParseBlockOutputType(t *testing.T) {
+	block, err := bparser.ParseBlock(geneisBlockDec, 0)
+	if err != nil {
+		t.Fatalf("can not parse genesis block\nerror: %v\n", err)
+	}
+	output := block.Tx.Tx[0].Outputs[0]
+	if output.Type != script.PubKey || output.Address != "" {
+		t.Errorf("ParseBlock() genesis output got type %s and address %q, want pubkey without address", output.Type, output.Address)
+	}
+
+	// a P2PKH output of a block with mainnet magic gets a mainnet address
+	tx := testTx(bparser.Hash{}, 0, 1, testOutput(50_0000_0000, p2pkhScript))
+	block, err = bparser.ParseBlock(buildBlock(testHeader(bparser.MainNet.GenesisHash, 1), tx), 1)
+	if err != nil {
+		t.Fatalf("can not parse block\nerror: %v\n", err)
+	}
+	output = block.Tx.Tx[0].Outputs[0]
+	want := bparser.MainNet.Address(p2pkhScript)
+	if output.Type != script.PubKeyHash || output.Address != want || want == "" {
+		t.Errorf("ParseBlock() got type %s and address %q, want pubkeyhash and %q", output.Type, output.Address, want)
+	}
+}
@@ -0,0 +1,194 @@
+package bparser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// SatoshiPerBitcoin is the number of satoshis in one bitcoin
+	SatoshiPerBitcoin Amount = 100_000_000
+	// MaxMoney is the most bitcoin which can ever exist, any amount above it is invalid
+	MaxMoney Amount = 21_000_000 * SatoshiPerBitcoin
+)
+
+// ErrAmountOverflow is returned by Amount.Add and Amount.Sub when the result does not fit in an int64.
+var ErrAmountOverflow = errors.New("amount overflows int64")
+
+/*
+Amount type is an amount of bitcoin in satoshis, as found in tx outputs. String formats it in BTC with all 8 decimals,
+e.g. 50.00000000 BTC, without going through a float so no precision is lost.
+*/
+type Amount int64
+
+/*
+BTC method returns the amount in bitcoin as a float64, use FormatBTC when the exact amount is needed.
+*/
+func (a Amount) BTC() float64 {
+	return float64(a) / float64(SatoshiPerBitcoin)
+}
+
+/*
+FormatBTC method returns the amount in bitcoin with 8 decimals, the same format bitcoin-core's RPCs use.
+*/
+func (a Amount) FormatBTC() string {
+	sign := ""
+	// negate as uint64 so the smallest int64 does not overflow
+	n := uint64(a)
+	if a < 0 {
+		sign = "-"
+		n = -n
+	}
+	return fmt.Sprintf("%s%d.%08d", sign, n/uint64(SatoshiPerBitcoin), n%uint64(SatoshiPerBitcoin))
+}
+
+func (a Amount) String() string {
+	return a.FormatBTC() + " BTC"
+}
+
+/*
+InRange method reports whether the amount is between 0 and MaxMoney, the range bitcoin-core allows for outputs and their totals.
+*/
+func (a Amount) InRange() bool {
+	return a >= 0 && a <= MaxMoney
+}
+
+/*
+Add method returns a + b, or ErrAmountOverflow when the sum does not fit in an int64.
+*/
+func (a Amount) Add(b Amount) (Amount, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, ErrAmountOverflow
+	}
+	return sum, nil
+}
+
+/*
+Sub method returns a - b, or ErrAmountOverflow when the difference does not fit in an int64.
+*/
+func (a Amount) Sub(b Amount) (Amount, error) {
+	diff := a - b
+	if (b > 0 && diff > a) || (b < 0 && diff < a) {
+		return 0, ErrAmountOverflow
+	}
+	return diff, nil
+}
+
+/*
+ParseAmount function parses an amount in bitcoin with up to 8 decimals, such as 0.5, 21000000 or 50.00000000 BTC.
+Amounts outside of 0 to MaxMoney are an error.
+*/
+func ParseAmount(s string) (Amount, error) {
+	btc := strings.TrimSuffix(strings.TrimSpace(s), " BTC")
+	whole, frac, _ := strings.Cut(btc, ".")
+	if whole == "" && frac != "" {
+		whole = "0"
+	}
+	if whole == "" || len(frac) > 8 || strings.ContainsAny(whole, "+-") {
+		errMsg := fmt.Sprintf("expected a positive amount in BTC with at most 8 decimals but got %q in ParseAmount() function\n", s)
+		return 0, errors.New(errMsg)
+	}
+
+	// MaxMoney has 8 digits before the decimal point, so anything longer is out of range
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || len(strings.TrimLeft(whole, "0")) > 8 {
+		errMsg := fmt.Sprintf("can not parse whole bitcoins %q in ParseAmount() function.\nerror: %v\n", whole, err)
+		return 0, errors.New(errMsg)
+	}
+	var f int64
+	if frac != "" {
+		f, err = strconv.ParseInt(frac+strings.Repeat("0", 8-len(frac)), 10, 64)
+		if err != nil || strings.ContainsAny(frac, "+-") {
+			errMsg := fmt.Sprintf("can not parse decimals %q in ParseAmount() function.\nerror: %v\n", frac, err)
+			return 0, errors.New(errMsg)
+		}
+	}
+
+	amount := Amount(w)*SatoshiPerBitcoin + Amount(f)
+	if !amount.InRange() {
+		errMsg := fmt.Sprintf("amount %s is more than the 21 million BTC limit in ParseAmount() function\n", amount)
+		return 0, errors.New(errMsg)
+	}
+	return amount, nil
+}
+
+/*
+BlockSubsidy method returns the new coins a block at height may create, 50 BTC halved every SubsidyHalvingInterval blocks.
+*/
+func (n *Network) BlockSubsidy(height int) Amount {
+	halvings := height / n.SubsidyHalvingInterval
+	if halvings >= 64 {
+		return 0
+	}
+	return 50 * SatoshiPerBitcoin >> halvings
+}
+
+/*
+OutputTotal method returns the sum of the outputs of the tx, an error is returned when any output or the total
+is outside of 0 to MaxMoney, as bitcoin-core requires.
+*/
+func (tx TxData) OutputTotal() (Amount, error) {
+	var total Amount
+	for i, output := range tx.Outputs {
+		if !output.Amount.InRange() {
+			errMsg := fmt.Sprintf("output %d of tx %s has amount %s outside of the valid range in OutputTotal() method\n", i, tx.TxId, output.Amount)
+			return 0, errors.New(errMsg)
+		}
+		// both are at most MaxMoney, so the sum can not overflow
+		total += output.Amount
+		if !total.InRange() {
+			errMsg := fmt.Sprintf("outputs of tx %s total %s, more than the 21 million BTC limit in OutputTotal() method\n", tx.TxId, total)
+			return 0, errors.New(errMsg)
+		}
+	}
+	return total, nil
+}
+
+/*
+Reward method returns the total paid by the coinbase tx of the block, which is at most the block subsidy plus the fees of the block.
+*/
+func (b BlockData) Reward() (Amount, error) {
+	if len(b.Tx.Tx) == 0 {
+		return 0, nil
+	}
+	return b.Tx.Tx[0].OutputTotal()
+}
+
+/*
+FeeKnown method reports whether Fee is set, which needs the outputs spent by every input to be attached with ApplyUndo
+or UTXOSet.ConnectBlock. It is always false for the coinbase tx.
+*/
+func (tx TxData) FeeKnown() bool {
+	if tx.IsCoinbase() {
+		return false
+	}
+	for _, in := range tx.Inputs {
+		if in.PrevOut == nil {
+			return false
+		}
+	}
+	return len(tx.Inputs) > 0
+}
+
+/*
+setFee method sets the Fee and FeeRate of the tx from in, the total of the outputs spent by its inputs.
+*/
+func (tx *TxData) setFee(in Amount) error {
+	if !in.InRange() {
+		errMsg := fmt.Sprintf("inputs of tx %s total %s, outside of the valid range in setFee() method\n", tx.TxId, in)
+		return errors.New(errMsg)
+	}
+	out, err := tx.OutputTotal()
+	if err != nil {
+		return err
+	}
+
+	tx.Fee = in - out
+	if tx.VSize > 0 {
+		tx.FeeRate = float64(tx.Fee) / float64(tx.VSize)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package bparser_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+func TestAmountFormat(t *testing.T) {
+	tests := []struct {
+		amount bparser.Amount
+		want   string
+	}{
+		{0, "0.00000000 BTC"},
+		{1, "0.00000001 BTC"},
+		{5_000_000_000, "50.00000000 BTC"},
+		{bparser.MaxMoney, "21000000.00000000 BTC"},
+		{-185_179, "-0.00185179 BTC"},
+		{math.MinInt64, "-92233720368.54775808 BTC"},
+	}
+
+	for _, test := range tests {
+		if got := test.amount.String(); got != test.want {
+			t.Errorf("String() of %d got = %s, want %s", int64(test.amount), got, test.want)
+		}
+	}
+
+	if got := bparser.Amount(150_000_000).BTC(); got != 1.5 {
+		t.Errorf("BTC() got = %f, want 1.5", got)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    bparser.Amount
+		wantErr bool
+	}{
+		{"50", 5_000_000_000, false},
+		{"0.5", 50_000_000, false},
+		{".00000001", 1, false},
+		{"50.00000000 BTC", 5_000_000_000, false},
+		{"21000000", bparser.MaxMoney, false},
+		{"00000000021000000", bparser.MaxMoney, false},
+		{"21000000.00000001", 0, true},
+		{"100000000", 0, true},
+		{"99999999999999999999", 0, true},
+		{"0.000000001", 0, true},
+		{"-1", 0, true},
+		{"+1", 0, true},
+		{"1.-5", 0, true},
+		{"1e8", 0, true},
+		{"", 0, true},
+		{".", 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := bparser.ParseAmount(test.s)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseAmount(%q) error = %v, wantErr %v", test.s, err, test.wantErr)
+		} else if got != test.want {
+			t.Errorf("ParseAmount(%q) got = %d, want %d", test.s, got, test.want)
+		}
+	}
+}
+
+func TestAmountArithmetic(t *testing.T) {
+	if sum, err := bparser.Amount(1).Add(2); err != nil || sum != 3 {
+		t.Errorf("Add() got = %d, %v, want 3", sum, err)
+	}
+	if diff, err := bparser.Amount(1).Sub(2); err != nil || diff != -1 {
+		t.Errorf("Sub() got = %d, %v, want -1", diff, err)
+	}
+
+	overflows := []func() (bparser.Amount, error){
+		func() (bparser.Amount, error) { return bparser.Amount(math.MaxInt64).Add(1) },
+		func() (bparser.Amount, error) { return bparser.Amount(math.MinInt64).Add(-1) },
+		func() (bparser.Amount, error) { return bparser.Amount(math.MinInt64).Sub(1) },
+		func() (bparser.Amount, error) { return bparser.Amount(0).Sub(math.MinInt64) },
+	}
+	for i, fn := range overflows {
+		if _, err := fn(); !errors.Is(err, bparser.ErrAmountOverflow) {
+			t.Errorf("overflow %d expected ErrAmountOverflow but got %v", i, err)
+		}
+	}
+
+	if bparser.Amount(-1).InRange() || (bparser.MaxMoney + 1).InRange() || !bparser.MaxMoney.InRange() {
+		t.Errorf("InRange() does not match 0 to MaxMoney")
+	}
+}
+
+func TestBlockSubsidy(t *testing.T) {
+	tests := []struct {
+		net    *bparser.Network
+		height int
+		want   bparser.Amount
+	}{
+		{&bparser.MainNet, 0, 5_000_000_000},
+		{&bparser.MainNet, 209_999, 5_000_000_000},
+		{&bparser.MainNet, 210_000, 2_500_000_000},
+		{&bparser.MainNet, 840_000, 312_500_000},
+		{&bparser.MainNet, 210_000 * 33, 0},
+		{&bparser.MainNet, 210_000 * 64, 0},
+		{&bparser.RegTest, 150, 2_500_000_000},
+	}
+
+	for _, test := range tests {
+		if got := test.net.BlockSubsidy(test.height); got != test.want {
+			t.Errorf("BlockSubsidy(%d) on %s got = %s, want %s", test.height, test.net.Name, got, test.want)
+		}
+	}
+}
+
+/*
+test Reward and OutputTotal methods, the genesis coinbase pays 50 BTC and outputs above MaxMoney are an error
+*/
+func TestBlockReward(t *testing.T) {
+	block, err := bparser.ParseBlock(geneisBlockDec, 0)
+	if err != nil {
+		t.Fatalf("can not parse genesis block\nerror: %v\n", err)
+	}
+	if reward, err := block.Reward(); err != nil || reward != bparser.MainNet.BlockSubsidy(0) {
+		t.Errorf("Reward() got = %s, %v, want %s", reward, err, bparser.MainNet.BlockSubsidy(0))
+	}
+
+	tx := bparser.TxData{Outputs: []bparser.TxOutputs{{Amount: bparser.MaxMoney}, {Amount: 1}}}
+	if _, err := tx.OutputTotal(); err == nil {
+		t.Errorf("OutputTotal() above MaxMoney expected an error")
+	}
+	tx.Outputs = []bparser.TxOutputs{{Amount: -1}}
+	if _, err := tx.OutputTotal(); err == nil {
+		t.Errorf("OutputTotal() of a negative output expected an error")
+	}
+}
@@ -0,0 +1,189 @@
+package bparser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// maxBlockSize is the largest serialized block allowed by consensus (BIP141)
+	maxBlockSize = 4_000_000
+)
+
+/*
+BlockReader type reads blocks one at a time from a bitcoin-core .dat file, or any other io.Reader.
+
+Each record in a dat file is the 4 byte magic number, a 4 byte little-endian block size and then the block itself.
+Bitcoin-core preallocates dat files, so the end of a file is usually padded with zeros which BlockReader skips over.
+The magic number depends on the network the dat file belongs to.
+
+# Example
+
+	file, _ := os.Open("blk00000.dat")
+	br := NewBlockReader(file, &MainNet)
+	for {
+		blk, offset, err := br.Next()
+		if err == io.EOF {
+			break
+		}
+		...
+	}
+*/
+type BlockReader struct {
+	src    io.Reader
+	r      *bufio.Reader
+	magic  []byte
+	offset int64
+}
+
+/*
+NewBlockReader function returns a BlockReader which reads blocks of network net from r.
+*/
+func NewBlockReader(r io.Reader, net *Network) *BlockReader {
+	return &BlockReader{
+		src:   r,
+		r:     bufio.NewReaderSize(r, 1<<20),
+		magic: net.Magic[:],
+	}
+}
+
+/*
+Offset method returns the number of bytes read from the underlying reader so far.
+*/
+func (br *BlockReader) Offset() int64 {
+	return br.offset
+}
+
+/*
+Next method reads the next block record. It returns the record bytes, which start with the magic number and block size
+so they can be passed straight to ParseBlock, and the offset of the magic number within the file.
+
+io.EOF is returned once there are no more blocks, including when the rest of the file is zero padding.
+*/
+func (br *BlockReader) Next() ([]byte, int64, error) {
+	return br.nextRecord(blockHeaderSize, maxBlockSize)
+}
+
+/*
+nextRecord method reads the next magic number, size and record of minSize to maxSize bytes.
+Blocks in blk files and undo data in rev files both use this layout.
+*/
+func (br *BlockReader) nextRecord(minSize uint32, maxSize uint32) ([]byte, int64, error) {
+	size, start, err := br.nextPrefix(minSize, maxSize)
+	if err != nil {
+		return nil, start, err
+	}
+
+	record := make([]byte, 8+int(size))
+	copy(record, br.magic)
+	binary.LittleEndian.PutUint32(record[4:8], size)
+	n, err := io.ReadFull(br.r, record[8:])
+	br.offset += int64(n)
+	if err != nil {
+		errMsg := fmt.Sprintf("can not read record of %d bytes at offset %d, only read %d bytes in Next() method.\nerror: %v\n", size, start, n, err)
+		return nil, start, errors.New(errMsg)
+	}
+
+	return record, start, nil
+}
+
+/*
+NextBlock method reads and parses the next block, the returned BlockData has FileOffset set to the offset of the block in the file.
+
+io.EOF is returned once there are no more blocks.
+*/
+func (br *BlockReader) NextBlock(blockNum int) (BlockData, error) {
+	blk, offset, err := br.Next()
+	if err != nil {
+		return BlockData{}, err
+	}
+
+	block, err := ParseBlock(blk, blockNum)
+	if merkleErr := (*MerkleError)(nil); errors.As(err, &merkleErr) {
+		block.FileOffset = offset
+		return block, err
+	} else if err != nil {
+		errMsg := fmt.Sprintf("can not parse block at offset %d in NextBlock() method.\nerror: %v\n", offset, err)
+		return BlockData{}, errors.New(errMsg)
+	}
+	block.FileOffset = offset
+
+	return block, nil
+}
+
+/*
+nextPrefix method reads the next magic number and record size, which must be between minSize and maxSize.
+Returns the size and the offset of the magic number.
+*/
+func (br *BlockReader) nextPrefix(minSize uint32, maxSize uint32) (uint32, int64, error) {
+	if err := br.skipPadding(); err != nil {
+		return 0, br.offset, err
+	}
+
+	start := br.offset
+	var prefix [8]byte
+	n, err := io.ReadFull(br.r, prefix[:])
+	br.offset += int64(n)
+	if err != nil {
+		errMsg := fmt.Sprintf("can not read magic number and size at offset %d in Next() method.\nerror: %v\n", start, err)
+		return 0, start, errors.New(errMsg)
+	}
+
+	if !bytes.Equal(prefix[:4], br.magic) {
+		errMsg := fmt.Sprintf("expected magic number %X at offset %d but got %X in Next() method\n", br.magic, start, prefix[:4])
+		return 0, start, errors.New(errMsg)
+	}
+
+	size := binary.LittleEndian.Uint32(prefix[4:])
+	if size < minSize || size > maxSize {
+		errMsg := fmt.Sprintf("record size %d at offset %d is out of range in Next() method\n", size, start)
+		return 0, start, errors.New(errMsg)
+	}
+
+	return size, start, nil
+}
+
+/*
+skip method moves n bytes forward. When the bytes are not already buffered and the underlying reader is an io.Seeker,
+such as an os.File or DatFile, they are seeked past instead of being read.
+*/
+func (br *BlockReader) skip(n int64) error {
+	if seeker, ok := br.src.(io.Seeker); ok && n > int64(br.r.Buffered()) {
+		rest := n - int64(br.r.Buffered())
+		if _, err := seeker.Seek(rest, io.SeekCurrent); err != nil {
+			return err
+		}
+		br.r.Reset(br.src)
+		br.offset += n
+		return nil
+	}
+
+	discarded, err := br.r.Discard(int(n))
+	br.offset += int64(discarded)
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+/*
+skipPadding method discards zero bytes before the next magic number, returns io.EOF if only zeros are left.
+*/
+func (br *BlockReader) skipPadding() error {
+	for {
+		b, err := br.r.ReadByte()
+		if err == io.EOF {
+			return io.EOF
+		} else if err != nil {
+			return err
+		}
+		if b != 0 {
+			return br.r.UnreadByte()
+		}
+		br.offset++
+	}
+}
@@ -0,0 +1,91 @@
+package bparser_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+/*
+genesisWithMagic function returns a copy of the genesis block where part of the coinbase message is replaced by the magic number.
+*/
+func genesisWithMagic() []byte {
+	coinbase := bytes.Clone(geneisBlockDec[89:])
+	copy(coinbase[140-89:144-89], []byte{249, 190, 180, 217})
+	return buildBlock(geneisBlockDec[8:88], coinbase)
+}
+
+func TestBlockReader(t *testing.T) {
+	var file []byte
+	file = append(file, geneisBlockDec...)
+	file = append(file, genesisWithMagic()...)
+	// dat files are preallocated so they end with zero padding
+	file = append(file, make([]byte, 1024)...)
+
+	br := bparser.NewBlockReader(bytes.NewReader(file), &bparser.MainNet)
+	wantOffsets := []int64{0, int64(len(geneisBlockDec))}
+	for i, wantOffset := range wantOffsets {
+		block, err := br.NextBlock(i)
+		if err != nil {
+			t.Fatalf("NextBlock() returned error for block %d\nerror: %v\n", i, err)
+		} else if block.FileOffset != wantOffset {
+			t.Errorf("NextBlock() got FileOffset = %d, want FileOffset = %d", block.FileOffset, wantOffset)
+		} else if block.Size != 285 {
+			t.Errorf("NextBlock() got Size = %d, want Size = %d", block.Size, 285)
+		}
+	}
+
+	if _, _, err := br.Next(); err != io.EOF {
+		t.Errorf("Next() got error = %v at end of file, want io.EOF", err)
+	} else if br.Offset() != int64(len(file)) {
+		t.Errorf("Offset() got = %d at end of file, want %d", br.Offset(), len(file))
+	}
+
+	// magic number inside a block used to split the block in two
+	n, err := bparser.ParseBlocks(file, 1, 10, []byte{0})
+	if err != nil {
+		t.Errorf("ParseBlocks() returned error\nerror: %v\n", err)
+	} else if n != 2 {
+		t.Errorf("ParseBlocks() got %d blocks, want 2", n)
+	}
+}
+
+func TestBlockReaderErrors(t *testing.T) {
+	badMagic := bytes.Clone(geneisBlockDec)
+	badMagic[0] = 1
+
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "truncated block", input: geneisBlockDec[:200]},
+		{name: "truncated size", input: geneisBlockDec[:6]},
+		{name: "wrong magic number", input: badMagic},
+		{name: "block size too large", input: []byte{249, 190, 180, 217, 255, 255, 255, 255}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			br := bparser.NewBlockReader(bytes.NewReader(tt.input), &bparser.MainNet)
+			if _, _, err := br.Next(); err == nil || err == io.EOF {
+				t.Errorf("Next() got error = %v, want a parse error", err)
+			}
+		})
+	}
+}
+
+/*
+test a read error in the magic number and size is reported as it is, not as an unexpected EOF
+*/
+func TestBlockReaderPrefixReadError(t *testing.T) {
+	errDisk := errors.New("disk failure")
+	br := bparser.NewBlockReader(io.MultiReader(bytes.NewReader(geneisBlockDec[:6]), iotest.ErrReader(errDisk)), &bparser.MainNet)
+	if _, _, err := br.Next(); err == nil || !strings.Contains(err.Error(), errDisk.Error()) {
+		t.Errorf("Next() got error = %v, want it to contain %v", err, errDisk)
+	}
+}
@@ -0,0 +1,315 @@
+package bparser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// status flags of a block index record, from bitcoin-core's chain.h
+const (
+	BlockValidHeader       = 1
+	BlockValidTree         = 2
+	BlockValidTransactions = 3
+	BlockValidChain        = 4
+	BlockValidScripts      = 5
+	BlockValidMask         = 7
+	BlockHaveData          = 8
+	BlockHaveUndo          = 16
+	BlockFailedValid       = 32
+	BlockFailedChild       = 64
+	BlockOptWitness        = 128
+)
+
+const (
+	// prefix of block index records, followed by the block hash
+	blockIndexPrefix = 'b'
+	// key holding the number of the last blk file
+	lastBlockFileKey = 'l'
+)
+
+/*
+DiskBlockIndex type is a single record of bitcoin-core's block index (CDiskBlockIndex), which says where a block and its undo data are stored.
+
+DataPos and UndoPos point at the first byte of the block and undo data, after the magic number and size.
+They are only meaningful when Status has BlockHaveData and BlockHaveUndo set.
+*/
+type DiskBlockIndex struct {
+	Height  int
+	Status  uint32
+	TxCount int64
+	FileNum int
+	DataPos int64
+	UndoPos int64
+	Header  BlockHeaderData
+}
+
+/*
+HaveData method returns true when the block is stored in a blk file.
+*/
+func (d DiskBlockIndex) HaveData() bool {
+	return d.Status&BlockHaveData != 0
+}
+
+/*
+HaveUndo method returns true when the undo data of the block is stored in a rev file.
+*/
+func (d DiskBlockIndex) HaveUndo() bool {
+	return d.Status&BlockHaveUndo != 0
+}
+
+/*
+Failed method returns true when the block, or one of its ancestors, failed validation.
+*/
+func (d DiskBlockIndex) Failed() bool {
+	return d.Status&(BlockFailedValid|BlockFailedChild) != 0
+}
+
+/*
+BlockIndex type reads bitcoin-core's block index LevelDB database, found in blocks/index of the data directory.
+
+The database is locked while bitcoin-core is running, so either stop the node or copy the index folder first.
+
+# Example
+
+	index, _ := OpenBlockIndex("/home/user/.bitcoin/blocks/index", &MainNet)
+	defer index.Close()
+	entry, _ := index.ByHeight(100_000)
+	block, _ := index.ReadBlock(blocksDir, entry)
+*/
+type BlockIndex struct {
+	db   *leveldb.DB
+	net  *Network
+	main []DiskBlockIndex
+}
+
+/*
+OpenBlockIndex function opens the block index database at path read only.
+*/
+func OpenBlockIndex(path string, net *Network) (*BlockIndex, error) {
+	db, err := leveldb.OpenFile(path, &opt.Options{ReadOnly: true, ErrorIfMissing: true})
+	if err != nil {
+		errMsg := fmt.Sprintf("can not open block index at %s in OpenBlockIndex() function.\nerror: %v\n", path, err)
+		return nil, errors.New(errMsg)
+	}
+
+	return &BlockIndex{db: db, net: net}, nil
+}
+
+/*
+Close method closes the block index database.
+*/
+func (bi *BlockIndex) Close() error {
+	return bi.db.Close()
+}
+
+/*
+Get method returns the block index record for the block with the given hash.
+*/
+func (bi *BlockIndex) Get(hash Hash) (DiskBlockIndex, error) {
+	key := hashKey(blockIndexPrefix, hash)
+	value, err := bi.db.Get(key, nil)
+	if err != nil {
+		errMsg := fmt.Sprintf("can not find block %s in block index in Get() method.\nerror: %v\n", hash, err)
+		return DiskBlockIndex{}, errors.New(errMsg)
+	}
+
+	return ParseDiskBlockIndex(key[1:], value)
+}
+
+/*
+ForEach method calls fn for every record in the block index, in key order which is not height order.
+Iteration stops at the first error returned by fn.
+*/
+func (bi *BlockIndex) ForEach(fn func(DiskBlockIndex) error) error {
+	iter := bi.db.NewIterator(util.BytesPrefix([]byte{blockIndexPrefix}), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		entry, err := ParseDiskBlockIndex(iter.Key()[1:], iter.Value())
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return iter.Error()
+}
+
+/*
+LastFile method returns the number of the last blk file bitcoin-core has written to.
+*/
+func (bi *BlockIndex) LastFile() (int, error) {
+	value, err := bi.db.Get([]byte{lastBlockFileKey}, nil)
+	if err != nil {
+		errMsg := fmt.Sprintf("can not read last block file from block index in LastFile() method.\nerror: %v\n", err)
+		return -1, errors.New(errMsg)
+	} else if len(value) != 4 {
+		errMsg := fmt.Sprintf("expected last block file to have 4 bytes but got %d in LastFile() method\n", len(value))
+		return -1, errors.New(errMsg)
+	}
+
+	return int(int32(binary.LittleEndian.Uint32(value))), nil
+}
+
+/*
+HeaderChain method builds a HeaderChain from every valid record in the block index, without reading any blk files.
+FileOffset of each entry is the offset of the block's magic number, or -1 when the block data is not stored.
+*/
+func (bi *BlockIndex) HeaderChain() (*HeaderChain, error) {
+	chain := NewHeaderChain(bi.net)
+	err := bi.ForEach(func(entry DiskBlockIndex) error {
+		if entry.Failed() {
+			return nil
+		}
+		offset := int64(-1)
+		if entry.HaveData() {
+			offset = entry.DataPos - 8
+		}
+		return chain.Add(entry.Header, entry.FileNum, offset)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := chain.Build(); err != nil {
+		return nil, err
+	}
+
+	return chain, nil
+}
+
+/*
+ByHeight method returns the record of the main chain block at height.
+The main chain is built from the whole index the first time ByHeight is called.
+*/
+func (bi *BlockIndex) ByHeight(height int) (DiskBlockIndex, error) {
+	if bi.main == nil {
+		chain, err := bi.HeaderChain()
+		if err != nil {
+			return DiskBlockIndex{}, err
+		}
+
+		bi.main = make([]DiskBlockIndex, 0, len(chain.MainChain()))
+		for _, entry := range chain.MainChain() {
+			record, err := bi.Get(entry.Header.BlockHash)
+			if err != nil {
+				return DiskBlockIndex{}, err
+			}
+			bi.main = append(bi.main, record)
+		}
+	}
+
+	if height < 0 || height >= len(bi.main) {
+		errMsg := fmt.Sprintf("height %d is not in the main chain, which has %d blocks, in ByHeight() method\n", height, len(bi.main))
+		return DiskBlockIndex{}, errors.New(errMsg)
+	}
+
+	return bi.main[height], nil
+}
+
+/*
+ReadBlock method seeks directly to the block in its blk file and parses it, BlockNumber of the returned block is its height.
+*/
+func (bi *BlockIndex) ReadBlock(dir *BlocksDir, entry DiskBlockIndex) (BlockData, error) {
+	if !entry.HaveData() {
+		errMsg := fmt.Sprintf("block %s at height %d is not stored in a blk file in ReadBlock() method\n", entry.Header.BlockHash, entry.Height)
+		return BlockData{}, errors.New(errMsg)
+	}
+
+	file, err := dir.OpenBlockFile(entry.FileNum)
+	if err != nil {
+		return BlockData{}, err
+	}
+	defer file.Close()
+
+	// data position points after the magic number and block size
+	offset := entry.DataPos - 8
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		errMsg := fmt.Sprintf("can not seek to offset %d in blk%05d.dat in ReadBlock() method.\nerror: %v\n", offset, entry.FileNum, err)
+		return BlockData{}, errors.New(errMsg)
+	}
+
+	br := NewBlockReader(file, bi.net)
+	br.offset = offset
+	block, err := br.NextBlock(entry.Height)
+	if err != nil {
+		return BlockData{}, err
+	}
+
+	if block.Header.BlockHash != entry.Header.BlockHash {
+		errMsg := fmt.Sprintf("expected block %s at offset %d in blk%05d.dat but got %s in ReadBlock() method\n", entry.Header.BlockHash, offset, entry.FileNum, block.Header.BlockHash)
+		return BlockData{}, errors.New(errMsg)
+	}
+
+	return block, nil
+}
+
+/*
+ParseDiskBlockIndex function decodes a block index record, hash is the key without its 'b' prefix and value is the serialized CDiskBlockIndex.
+
+	VARINT client version
+	VARINT height
+	VARINT status
+	VARINT tx count
+	VARINT file number, if status has BlockHaveData or BlockHaveUndo
+	VARINT data position, if status has BlockHaveData
+	VARINT undo position, if status has BlockHaveUndo
+	80 byte block header
+*/
+func ParseDiskBlockIndex(hash []byte, value []byte) (DiskBlockIndex, error) {
+	r := varIntReader{b: value}
+	r.varInt() // client version
+	entry := DiskBlockIndex{
+		Height:  int(r.varInt()),
+		Status:  uint32(r.varInt()),
+		TxCount: int64(r.varInt()),
+		FileNum: -1,
+		DataPos: -1,
+		UndoPos: -1,
+	}
+	if entry.Status&(BlockHaveData|BlockHaveUndo) != 0 {
+		entry.FileNum = int(r.varInt())
+	}
+	if entry.Status&BlockHaveData != 0 {
+		entry.DataPos = int64(r.varInt())
+	}
+	if entry.Status&BlockHaveUndo != 0 {
+		entry.UndoPos = int64(r.varInt())
+	}
+	header := r.bytes(blockHeaderSize)
+	if r.err != nil {
+		errMsg := fmt.Sprintf("can not decode block index record in ParseDiskBlockIndex() function.\nerror: %v\n", r.err)
+		return DiskBlockIndex{}, errors.New(errMsg)
+	}
+
+	parsedHeader, err := parseBlockHeader(header)
+	if err != nil {
+		return DiskBlockIndex{}, err
+	}
+	keyHash, err := NewHash(hash)
+	if err != nil {
+		return DiskBlockIndex{}, err
+	}
+	if parsedHeader.BlockHash != keyHash {
+		errMsg := fmt.Sprintf("block index record for %s holds the header of %s in ParseDiskBlockIndex() function\n", keyHash, parsedHeader.BlockHash)
+		return DiskBlockIndex{}, errors.New(errMsg)
+	}
+	entry.Header = parsedHeader
+
+	return entry, nil
+}
+
+/*
+hashKey function returns a LevelDB key made of prefix and the hash in internal byte order.
+*/
+func hashKey(prefix byte, hash Hash) []byte {
+	return append([]byte{prefix}, hash[:]...)
+}
@@ -0,0 +1,128 @@
+package bparser_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+/*
+writeBlockIndex function writes a block index LevelDB database with a record for the genesis block stored at dataPos in blk00000.dat.
+*/
+func writeBlockIndex(t *testing.T, dataPos uint64) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "index")
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		t.Fatalf("can not create block index\nerror: %v\n", err)
+	}
+	defer db.Close()
+
+	hash := bparser.MainNet.GenesisHash[:]
+
+	var value []byte
+	value = bparser.AppendVarInt(value, 280_000) // client version
+	value = bparser.AppendVarInt(value, 0)       // height
+	value = bparser.AppendVarInt(value, bparser.BlockValidScripts|bparser.BlockHaveData)
+	value = bparser.AppendVarInt(value, 1) // tx count
+	value = bparser.AppendVarInt(value, 0) // file number
+	value = bparser.AppendVarInt(value, dataPos)
+	value = append(value, geneisBlockDec[8:88]...)
+
+	if err := db.Put(append([]byte{'b'}, hash...), value, nil); err != nil {
+		t.Fatalf("can not write block index record\nerror: %v\n", err)
+	}
+	if err := db.Put([]byte{'l'}, []byte{0, 0, 0, 0}, nil); err != nil {
+		t.Fatalf("can not write last block file\nerror: %v\n", err)
+	}
+
+	return path
+}
+
+func TestBlockIndex(t *testing.T) {
+	// pad the start of the blk file so the block is not at offset 0
+	blocksPath := t.TempDir()
+	padding := make([]byte, 100)
+	file := append(padding, geneisBlockDec...)
+	if err := os.WriteFile(filepath.Join(blocksPath, "blk00000.dat"), file, 0o644); err != nil {
+		t.Fatalf("can not write blk00000.dat\nerror: %v\n", err)
+	}
+	blocksDir, err := bparser.OpenBlocksDir(blocksPath)
+	if err != nil {
+		t.Fatalf("OpenBlocksDir() returned error\nerror: %v\n", err)
+	}
+
+	index, err := bparser.OpenBlockIndex(writeBlockIndex(t, uint64(len(padding)+8)), &bparser.MainNet)
+	if err != nil {
+		t.Fatalf("OpenBlockIndex() returned error\nerror: %v\n", err)
+	}
+	defer index.Close()
+
+	entry, err := index.Get(bparser.MainNet.GenesisHash)
+	if err != nil {
+		t.Fatalf("Get() returned error\nerror: %v\n", err)
+	} else if entry.Height != 0 || entry.TxCount != 1 || entry.FileNum != 0 || entry.DataPos != 108 || entry.UndoPos != -1 {
+		t.Errorf("Get() got Height = %d, TxCount = %d, FileNum = %d, DataPos = %d, UndoPos = %d", entry.Height, entry.TxCount, entry.FileNum, entry.DataPos, entry.UndoPos)
+	} else if !entry.HaveData() || entry.HaveUndo() || entry.Failed() {
+		t.Errorf("Get() got Status = %d, want data without undo", entry.Status)
+	} else if entry.Header.BlockHash != bparser.MainNet.GenesisHash {
+		t.Errorf("Get() got header of %s, want %s", entry.Header.BlockHash, bparser.MainNet.GenesisHash)
+	}
+
+	count := 0
+	if err := index.ForEach(func(bparser.DiskBlockIndex) error { count++; return nil }); err != nil {
+		t.Errorf("ForEach() returned error\nerror: %v\n", err)
+	} else if count != 1 {
+		t.Errorf("ForEach() got %d records, want 1", count)
+	}
+
+	if last, err := index.LastFile(); err != nil || last != 0 {
+		t.Errorf("LastFile() got %d, want 0\nerror: %v\n", last, err)
+	}
+
+	byHeight, err := index.ByHeight(0)
+	if err != nil {
+		t.Fatalf("ByHeight() returned error\nerror: %v\n", err)
+	} else if byHeight.Header.BlockHash != bparser.MainNet.GenesisHash {
+		t.Errorf("ByHeight(0) got %s, want genesis", byHeight.Header.BlockHash)
+	}
+	if _, err := index.ByHeight(1); err == nil {
+		t.Errorf("ByHeight(1) expected an error for a height past the tip")
+	}
+
+	block, err := index.ReadBlock(blocksDir, byHeight)
+	if err != nil {
+		t.Fatalf("ReadBlock() returned error\nerror: %v\n", err)
+	} else if block.FileOffset != int64(len(padding)) || block.BlockNumber != 0 {
+		t.Errorf("ReadBlock() got FileOffset = %d and BlockNumber = %d, want %d and 0", block.FileOffset, block.BlockNumber, len(padding))
+	} else if block.Tx.Tx[0].TxId != block.Header.MerkleRoot {
+		t.Errorf("ReadBlock() got coinbase txid %s, want %s", block.Tx.Tx[0].TxId, block.Header.MerkleRoot)
+	}
+}
+
+func TestParseDiskBlockIndexErrors(t *testing.T) {
+	hash := bparser.MainNet.GenesisHash[:]
+
+	var value []byte
+	value = bparser.AppendVarInt(value, 280_000)
+	value = bparser.AppendVarInt(value, 0)
+	value = bparser.AppendVarInt(value, bparser.BlockHaveData)
+	value = bparser.AppendVarInt(value, 1)
+	value = bparser.AppendVarInt(value, 0)
+	value = bparser.AppendVarInt(value, 8)
+
+	if _, err := bparser.ParseDiskBlockIndex(hash, append(value, geneisBlockDec[8:40]...)); err == nil {
+		t.Errorf("ParseDiskBlockIndex() expected an error for a truncated header")
+	}
+
+	wrongHash := slices.Clone(hash)
+	wrongHash[0]++
+	if _, err := bparser.ParseDiskBlockIndex(wrongHash, append(value, geneisBlockDec[8:88]...)); err == nil {
+		t.Errorf("ParseDiskBlockIndex() expected an error when the header does not match the key")
+	}
+}
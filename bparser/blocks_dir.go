@@ -0,0 +1,227 @@
+package bparser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const (
+	// xorKeyFile is the file in the blocks directory holding the key used to obfuscate blk and rev files
+	xorKeyFile = "xor.dat"
+	// xorKeySize is the number of bytes in the obfuscation key
+	xorKeySize = 8
+)
+
+/*
+BlocksDir type is a bitcoin-core blocks directory, which holds the blk*.dat and rev*.dat files.
+
+Since v28 bitcoin-core obfuscates these files by xor-ing every byte with a key stored in blocks/xor.dat.
+XORKey is nil when there is no xor.dat file or the key is all zeros, in which case files are read as is.
+
+# Example
+
+	dir, _ := OpenBlocksDir("/home/user/.bitcoin/blocks")
+	file, _ := dir.OpenBlockFile(0)
+	defer file.Close()
+	ParseBlocksReader(file, &MainNet, 0, 10)
+*/
+type BlocksDir struct {
+	Path   string
+	XORKey []byte
+}
+
+/*
+OpenBlocksDir function returns a BlocksDir for the blocks directory at path, loading the xor key if there is one.
+*/
+func OpenBlocksDir(path string) (*BlocksDir, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		errMsg := fmt.Sprintf("can not open blocks directory in OpenBlocksDir() function.\nerror: %v\n", err)
+		return nil, errors.New(errMsg)
+	} else if !info.IsDir() {
+		errMsg := fmt.Sprintf("blocks directory %s is not a directory in OpenBlocksDir() function\n", path)
+		return nil, errors.New(errMsg)
+	}
+
+	key, err := LoadXORKey(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BlocksDir{Path: path, XORKey: key}, nil
+}
+
+/*
+LoadXORKey function reads the obfuscation key from xor.dat in the blocks directory.
+Returns a nil key, and no error, when xor.dat does not exist or the key is all zeros.
+*/
+func LoadXORKey(blocksDir string) ([]byte, error) {
+	key, err := os.ReadFile(filepath.Join(blocksDir, xorKeyFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		errMsg := fmt.Sprintf("can not read %s in LoadXORKey() function.\nerror: %v\n", xorKeyFile, err)
+		return nil, errors.New(errMsg)
+	}
+
+	if len(key) != xorKeySize {
+		errMsg := fmt.Sprintf("expected %s to have %d bytes but got %d bytes in LoadXORKey() function\n", xorKeyFile, xorKeySize, len(key))
+		return nil, errors.New(errMsg)
+	}
+
+	for _, b := range key {
+		if b != 0 {
+			return key, nil
+		}
+	}
+
+	return nil, nil
+}
+
+/*
+BlockFiles method returns the path of all blk*.dat files in the blocks directory, in file number order.
+*/
+func (d *BlocksDir) BlockFiles() ([]string, error) {
+	return d.glob("blk*.dat")
+}
+
+/*
+UndoFiles method returns the path of all rev*.dat files in the blocks directory, in file number order.
+*/
+func (d *BlocksDir) UndoFiles() ([]string, error) {
+	return d.glob("rev*.dat")
+}
+
+func (d *BlocksDir) glob(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(d.Path, pattern))
+	if err != nil {
+		return []string{}, errors.New(err.Error())
+	}
+
+	return matches, nil
+}
+
+/*
+Open method opens a file in the blocks directory, the returned DatFile removes the xor obfuscation while reading.
+*/
+func (d *BlocksDir) Open(name string) (*DatFile, error) {
+	path := name
+	if !filepath.IsAbs(name) && filepath.Dir(name) == "." {
+		path = filepath.Join(d.Path, name)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		errMsg := fmt.Sprintf("can not open %s in Open() method.\nerror: %v\n", name, err)
+		return nil, errors.New(errMsg)
+	}
+
+	return &DatFile{f: f, key: d.XORKey}, nil
+}
+
+/*
+OpenBlockFile method opens blkNNNNN.dat for file number num.
+*/
+func (d *BlocksDir) OpenBlockFile(num int) (*DatFile, error) {
+	return d.Open(fmt.Sprintf("blk%05d.dat", num))
+}
+
+/*
+datFileNum function returns the file number of a blk or rev file, e.g. 12 for blk00012.dat.
+*/
+func datFileNum(path string) (int, error) {
+	name := filepath.Base(path)
+	if len(name) != len("blk00000.dat") || filepath.Ext(name) != ".dat" {
+		errMsg := fmt.Sprintf("expected a file named like blk00000.dat or rev00000.dat but got %s in datFileNum() function\n", name)
+		return -1, errors.New(errMsg)
+	}
+
+	num, err := strconv.Atoi(name[3:8])
+	if err != nil {
+		errMsg := fmt.Sprintf("can not parse file number of %s in datFileNum() function.\nerror: %v\n", name, err)
+		return -1, errors.New(errMsg)
+	}
+
+	return num, nil
+}
+
+/*
+DatFile type is an open blk or rev file. Read, ReadAt and Seek return de-obfuscated bytes when the blocks directory has an xor key.
+*/
+type DatFile struct {
+	f   *os.File
+	key []byte
+	pos int64
+}
+
+func (d *DatFile) Read(p []byte) (int, error) {
+	n, err := d.f.Read(p)
+	xorBytes(p[:n], d.key, d.pos)
+	d.pos += int64(n)
+	return n, err
+}
+
+func (d *DatFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := d.f.ReadAt(p, off)
+	xorBytes(p[:n], d.key, off)
+	return n, err
+}
+
+func (d *DatFile) Seek(offset int64, whence int) (int64, error) {
+	pos, err := d.f.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	d.pos = pos
+	return pos, nil
+}
+
+func (d *DatFile) Stat() (os.FileInfo, error) {
+	return d.f.Stat()
+}
+
+func (d *DatFile) Close() error {
+	return d.f.Close()
+}
+
+/*
+NewXORReader function returns a reader which xors the bytes read from r with key.
+offset is the position of r within the obfuscated file, since the key is applied based on the file position.
+*/
+func NewXORReader(r io.Reader, key []byte, offset int64) io.Reader {
+	return &xorReader{r: r, key: key, pos: offset}
+}
+
+type xorReader struct {
+	r   io.Reader
+	key []byte
+	pos int64
+}
+
+func (x *xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	xorBytes(p[:n], x.key, x.pos)
+	x.pos += int64(n)
+	return n, err
+}
+
+/*
+xorBytes function xors b in place with key, where b starts at position pos in the file.
+*/
+func xorBytes(b []byte, key []byte, pos int64) {
+	if len(key) == 0 {
+		return
+	}
+	k := int(pos % int64(len(key)))
+	for i := range b {
+		b[i] ^= key[k]
+		k++
+		if k == len(key) {
+			k = 0
+		}
+	}
+}
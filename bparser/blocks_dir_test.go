@@ -0,0 +1,123 @@
+package bparser_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+/*
+writeBlocksDir function writes a blocks directory with blk00000.dat holding data, obfuscated with key when key is not nil.
+*/
+func writeBlocksDir(t *testing.T, data []byte, key []byte) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	obfuscated := bytes.Clone(data)
+	if key != nil {
+		for i := range obfuscated {
+			obfuscated[i] ^= key[i%len(key)]
+		}
+		if err := os.WriteFile(filepath.Join(dir, "xor.dat"), key, 0o644); err != nil {
+			t.Fatalf("can not write xor.dat\nerror: %v\n", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "blk00000.dat"), obfuscated, 0o644); err != nil {
+		t.Fatalf("can not write blk00000.dat\nerror: %v\n", err)
+	}
+
+	return dir
+}
+
+func TestBlocksDirXOR(t *testing.T) {
+	var data []byte
+	data = append(data, geneisBlockDec...)
+	data = append(data, geneisBlockDec...)
+	data = append(data, make([]byte, 64)...)
+
+	tests := []struct {
+		name string
+		key  []byte
+	}{
+		{name: "no xor.dat", key: nil},
+		{name: "zero key", key: make([]byte, 8)},
+		{name: "obfuscated", key: []byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := bparser.OpenBlocksDir(writeBlocksDir(t, data, tt.key))
+			if err != nil {
+				t.Fatalf("OpenBlocksDir() returned error\nerror: %v\n", err)
+			}
+
+			files, err := dir.BlockFiles()
+			if err != nil || len(files) != 1 {
+				t.Fatalf("BlockFiles() got %v, want one file\nerror: %v\n", files, err)
+			}
+
+			file, err := dir.Open(files[0])
+			if err != nil {
+				t.Fatalf("Open() returned error\nerror: %v\n", err)
+			}
+			defer file.Close()
+
+			n, err := bparser.ParseBlocksReader(file, &bparser.MainNet, 1, 10)
+			if err != nil {
+				t.Errorf("ParseBlocksReader() returned error\nerror: %v\n", err)
+			} else if n != 2 {
+				t.Errorf("ParseBlocksReader() got %d blocks, want 2", n)
+			}
+
+			// seek to the second block, which does not start at a multiple of the key size
+			offset := int64(len(geneisBlockDec))
+			if _, err := file.Seek(offset, io.SeekStart); err != nil {
+				t.Fatalf("Seek() returned error\nerror: %v\n", err)
+			}
+			blk, _, err := bparser.NewBlockReader(file, &bparser.MainNet).Next()
+			if err != nil {
+				t.Errorf("Next() after Seek() returned error\nerror: %v\n", err)
+			} else if !bytes.Equal(blk, geneisBlockDec) {
+				t.Errorf("Next() after Seek() did not return the genesis block")
+			}
+
+			header := make([]byte, 80)
+			if _, err := file.ReadAt(header, offset+8); err != nil {
+				t.Errorf("ReadAt() returned error\nerror: %v\n", err)
+			} else if !bytes.Equal(header, geneisBlockDec[8:88]) {
+				t.Errorf("ReadAt() did not return the genesis block header")
+			}
+		})
+	}
+}
+
+func TestNewXORReader(t *testing.T) {
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	plain := []byte("the times 03/jan/2009 chancellor on brink of second bailout for banks")
+	obfuscated := bytes.Clone(plain)
+	for i := range obfuscated {
+		obfuscated[i] ^= key[(i+3)%len(key)]
+	}
+
+	got, err := io.ReadAll(bparser.NewXORReader(bytes.NewReader(obfuscated), key, 3))
+	if err != nil {
+		t.Fatalf("NewXORReader() returned error\nerror: %v\n", err)
+	} else if !bytes.Equal(got, plain) {
+		t.Errorf("NewXORReader() got %q, want %q", got, plain)
+	}
+}
+
+func TestLoadXORKeyWrongSize(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "xor.dat"), []byte{1, 2, 3}, 0o644); err != nil {
+		t.Fatalf("can not write xor.dat\nerror: %v\n", err)
+	}
+
+	if _, err := bparser.LoadXORKey(dir); err == nil {
+		t.Errorf("LoadXORKey() expected an error for a key which is not 8 bytes")
+	}
+}
@@ -0,0 +1,338 @@
+package bparser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+)
+
+// medianTimeSpan is the number of blocks the median time past is taken over
+const medianTimeSpan = 11
+
+// ChainStatus is where a block sits relative to the most-work chain.
+type ChainStatus int
+
+const (
+	// StatusUnknown is used before HeaderChain.Build has been called
+	StatusUnknown ChainStatus = iota
+	// StatusMainChain blocks are part of the most-work chain
+	StatusMainChain
+	// StatusStale blocks link back to genesis but are not part of the most-work chain
+	StatusStale
+	// StatusOrphan blocks do not link back to genesis, their parent was never seen
+	StatusOrphan
+)
+
+func (s ChainStatus) String() string {
+	switch s {
+	case StatusMainChain:
+		return "main"
+	case StatusStale:
+		return "stale"
+	case StatusOrphan:
+		return "orphan"
+	}
+	return "unknown"
+}
+
+/*
+ChainEntry type is a single block header in a HeaderChain, along with where the block is stored.
+
+Bits is the compact target of the header, Target the target it decodes to and Work the expected number of hashes
+needed to reach it. Height and ChainWork, the total work of the block and all of its ancestors, are set by HeaderChain.Build,
+Height is -1 for orphaned blocks.
+*/
+type ChainEntry struct {
+	Header     BlockHeaderData
+	Height     int
+	Bits       uint32
+	Target     *big.Int
+	Work       *big.Int
+	ChainWork  *big.Int
+	Status     ChainStatus
+	FileNum    int
+	FileOffset int64
+
+	parent *ChainEntry
+	// order the header was added in, ties in chain work go to the header seen first
+	seq int
+	// bits of the last block in the retarget interval which is not a min difficulty block
+	periodBits uint32
+}
+
+/*
+HeaderChain type links block headers by their PrevBlock hash to find the most-work chain and the true height of every block.
+
+Blocks in blk*.dat files are stored in the order they were downloaded, not by height, so the position of a block
+in the files does not give its height.
+
+# Example
+
+	chain := NewHeaderChain(&MainNet)
+	chain.Add(block.Header, fileNum, block.FileOffset)
+	...
+	chain.Build()
+	tip := chain.Tip()
+*/
+type HeaderChain struct {
+	net     *Network
+	entries map[Hash]*ChainEntry
+	main    []*ChainEntry
+}
+
+/*
+NewHeaderChain function returns an empty HeaderChain for network net.
+*/
+func NewHeaderChain(net *Network) *HeaderChain {
+	return &HeaderChain{
+		net:     net,
+		entries: make(map[Hash]*ChainEntry),
+	}
+}
+
+/*
+Add method adds a block header to the chain, stored at fileOffset in blkNNNNN.dat with NNNNN being fileNum.
+Headers which were already added are ignored.
+*/
+func (c *HeaderChain) Add(header BlockHeaderData, fileNum int, fileOffset int64) error {
+	if _, ok := c.entries[header.BlockHash]; ok {
+		return nil
+	}
+
+	bits := header.Bits
+	c.entries[header.BlockHash] = &ChainEntry{
+		Header:     header,
+		Height:     -1,
+		Bits:       bits,
+		Target:     CompactToBig(bits),
+		Work:       CalcWork(bits),
+		FileNum:    fileNum,
+		FileOffset: fileOffset,
+		seq:        len(c.entries),
+	}
+	// adding a header invalidates the current main chain
+	c.main = nil
+
+	return nil
+}
+
+/*
+Build method links every header to its parent, starting from the genesis block of the network, assigns heights and chain work
+and selects the most-work chain. Blocks not on the most-work chain are flagged as stale, and blocks which do not link back
+to genesis are flagged as orphans.
+*/
+func (c *HeaderChain) Build() error {
+	genesis, ok := c.entries[c.net.GenesisHash]
+	if !ok {
+		errMsg := fmt.Sprintf("genesis block %s of network %s has not been added in Build() method\n", c.net.GenesisHash, c.net.Name)
+		return errors.New(errMsg)
+	}
+
+	children := make(map[Hash][]*ChainEntry, len(c.entries))
+	for _, entry := range c.entries {
+		entry.Height = -1
+		entry.ChainWork = nil
+		entry.Status = StatusOrphan
+		entry.parent = nil
+		if entry != genesis {
+			children[entry.Header.PrevBlock] = append(children[entry.Header.PrevBlock], entry)
+		}
+	}
+
+	// walk from genesis, breadth first so long chains do not recurse
+	genesis.Height = 0
+	genesis.ChainWork = new(big.Int).Set(genesis.Work)
+	genesis.Status = StatusStale
+	genesis.periodBits = genesis.Bits
+	tip := genesis
+	queue := []*ChainEntry{genesis}
+	for len(queue) > 0 {
+		entry := queue[0]
+		queue = queue[1:]
+
+		for _, child := range children[entry.Header.BlockHash] {
+			child.parent = entry
+			child.Height = entry.Height + 1
+			child.ChainWork = new(big.Int).Add(entry.ChainWork, child.Work)
+			child.Status = StatusStale
+			child.periodBits = child.Bits
+			if child.Height%c.net.RetargetInterval() != 0 && child.Bits == c.net.PowLimitBits {
+				child.periodBits = entry.periodBits
+			}
+			queue = append(queue, child)
+
+			cmp := child.ChainWork.Cmp(tip.ChainWork)
+			if cmp > 0 || (cmp == 0 && child.seq < tip.seq) {
+				tip = child
+			}
+		}
+	}
+
+	c.main = make([]*ChainEntry, tip.Height+1)
+	for entry := tip; entry != nil; entry = entry.parent {
+		entry.Status = StatusMainChain
+		c.main[entry.Height] = entry
+	}
+
+	return nil
+}
+
+/*
+ancestor method returns the ancestor of entry at height, using the main chain once the walk back reaches it.
+*/
+func (c *HeaderChain) ancestor(entry *ChainEntry, height int) *ChainEntry {
+	for entry.Height > height {
+		if entry.Status == StatusMainChain && height >= 0 {
+			return c.main[height]
+		}
+		entry = entry.parent
+	}
+	return entry
+}
+
+/*
+Get method returns the entry for the block with the given hash.
+*/
+func (c *HeaderChain) Get(hash Hash) (*ChainEntry, bool) {
+	entry, ok := c.entries[hash]
+	return entry, ok
+}
+
+/*
+MedianTimePast method returns the median timestamp of entry and up to 10 of its ancestors, as bitcoin-core's GetMedianTimePast.
+Build must be called first.
+*/
+func (c *HeaderChain) MedianTimePast(entry *ChainEntry) int64 {
+	times := make([]int64, 0, medianTimeSpan)
+	for ; entry != nil && len(times) < medianTimeSpan; entry = entry.parent {
+		times = append(times, entry.Header.TimestampUnix)
+	}
+	if len(times) == 0 {
+		return 0
+	}
+	slices.Sort(times)
+	return times[len(times)/2]
+}
+
+/*
+AtHeight method returns the main chain entry at height, Build must be called first.
+*/
+func (c *HeaderChain) AtHeight(height int) (*ChainEntry, bool) {
+	if height < 0 || height >= len(c.main) {
+		return nil, false
+	}
+	return c.main[height], true
+}
+
+/*
+Tip method returns the last entry of the main chain, or nil if Build has not been called.
+*/
+func (c *HeaderChain) Tip() *ChainEntry {
+	if len(c.main) == 0 {
+		return nil
+	}
+	return c.main[len(c.main)-1]
+}
+
+/*
+MainChain method returns the main chain entries ordered by height, Build must be called first.
+*/
+func (c *HeaderChain) MainChain() []*ChainEntry {
+	return c.main
+}
+
+/*
+Len method returns the number of headers in the chain, including stale and orphaned blocks.
+*/
+func (c *HeaderChain) Len() int {
+	return len(c.entries)
+}
+
+/*
+Counts method returns the number of main chain, stale and orphaned blocks.
+*/
+func (c *HeaderChain) Counts() (mainChain int, stale int, orphan int) {
+	for _, entry := range c.entries {
+		switch entry.Status {
+		case StatusMainChain:
+			mainChain++
+		case StatusStale:
+			stale++
+		case StatusOrphan:
+			orphan++
+		}
+	}
+	return mainChain, stale, orphan
+}
+
+/*
+AssignHeight method sets the BlockNumber of block to its true height and sets its chain Status.
+BlockNumber is set to -1 when the block is orphaned or not in the chain.
+*/
+func (c *HeaderChain) AssignHeight(block *BlockData) {
+	entry, ok := c.entries[block.Header.BlockHash]
+	if !ok {
+		block.BlockNumber = -1
+		block.Status = StatusUnknown
+		return
+	}
+	block.BlockNumber = entry.Height
+	block.Status = entry.Status
+}
+
+/*
+BuildHeaderChain function reads the header of every block in the blk*.dat files of the blocks directory and builds the HeaderChain.
+*/
+func BuildHeaderChain(dir *BlocksDir, net *Network) (*HeaderChain, error) {
+	files, err := dir.BlockFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	chain := NewHeaderChain(net)
+	for _, path := range files {
+		fileNum, err := datFileNum(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := addFileHeaders(chain, dir, path, fileNum); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := chain.Build(); err != nil {
+		return nil, err
+	}
+
+	return chain, nil
+}
+
+/*
+addFileHeaders function adds the header of every block in a single blk file to chain.
+*/
+func addFileHeaders(chain *HeaderChain, dir *BlocksDir, path string, fileNum int) error {
+	file, err := dir.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// only the headers are needed, so seek past the txs of every block
+	br := NewBlockReader(file, chain.net)
+	for {
+		header, err := br.NextHeader()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			errMsg := fmt.Sprintf("can not read block in %s in addFileHeaders() function.\nerror: %v\n", path, err)
+			return errors.New(errMsg)
+		}
+
+		if err := chain.Add(header.Header, fileNum, header.FileOffset); err != nil {
+			return err
+		}
+	}
+}
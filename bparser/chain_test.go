@@ -0,0 +1,143 @@
+package bparser_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+/*
+namedHash function returns a hash starting with the bytes of name, so tests can refer to blocks by name.
+An empty name is the zero hash.
+*/
+func namedHash(name string) bparser.Hash {
+	var h bparser.Hash
+	copy(h[:], name)
+	return h
+}
+
+func TestHeaderChain(t *testing.T) {
+	net := bparser.RegTest
+	net.GenesisHash = namedHash("G")
+
+	header := func(hash string, prev string, bits uint32) bparser.BlockHeaderData {
+		return bparser.BlockHeaderData{BlockHash: namedHash(hash), PrevBlock: namedHash(prev), Bits: bits}
+	}
+
+	// blocks are added out of height order, the way they are stored in blk files
+	headers := []bparser.BlockHeaderData{
+		header("A2", "A1", 0x1d00ffff),
+		header("B1", "G", 0x1d00ffff),
+		header("A1", "G", 0x1d00ffff),
+		header("O1", "X", 0x1d00ffff),
+		header("G", "", 0x1d00ffff),
+		header("A3", "A2", 0x1d00ffff),
+		header("B2", "B1", 0x1d00ffff),
+		header("A2", "A1", 0x1d00ffff),
+	}
+
+	chain := bparser.NewHeaderChain(&net)
+	for i, h := range headers {
+		if err := chain.Add(h, 0, int64(i)); err != nil {
+			t.Fatalf("Add() returned error\nerror: %v\n", err)
+		}
+	}
+	if err := chain.Build(); err != nil {
+		t.Fatalf("Build() returned error\nerror: %v\n", err)
+	}
+
+	tests := []struct {
+		hash   string
+		height int
+		status bparser.ChainStatus
+	}{
+		{hash: "G", height: 0, status: bparser.StatusMainChain},
+		{hash: "A1", height: 1, status: bparser.StatusMainChain},
+		{hash: "A2", height: 2, status: bparser.StatusMainChain},
+		{hash: "A3", height: 3, status: bparser.StatusMainChain},
+		{hash: "B1", height: 1, status: bparser.StatusStale},
+		{hash: "B2", height: 2, status: bparser.StatusStale},
+		{hash: "O1", height: -1, status: bparser.StatusOrphan},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hash, func(t *testing.T) {
+			entry, ok := chain.Get(namedHash(tt.hash))
+			if !ok {
+				t.Fatalf("Get(%s) did not find block", tt.hash)
+			} else if entry.Height != tt.height {
+				t.Errorf("Get(%s) got Height = %d, want Height = %d", tt.hash, entry.Height, tt.height)
+			} else if entry.Status != tt.status {
+				t.Errorf("Get(%s) got Status = %s, want Status = %s", tt.hash, entry.Status, tt.status)
+			}
+
+			block := bparser.BlockData{Header: entry.Header}
+			chain.AssignHeight(&block)
+			if block.BlockNumber != tt.height || block.Status != tt.status {
+				t.Errorf("AssignHeight() got BlockNumber = %d and Status = %s, want %d and %s", block.BlockNumber, block.Status, tt.height, tt.status)
+			}
+		})
+	}
+
+	if chain.Tip().Header.BlockHash != namedHash("A3") {
+		t.Errorf("Tip() got %s, want A3", chain.Tip().Header.BlockHash)
+	} else if entry, ok := chain.AtHeight(2); !ok || entry.Header.BlockHash != namedHash("A2") {
+		t.Errorf("AtHeight(2) did not return A2")
+	} else if chain.Len() != 7 {
+		t.Errorf("Len() got %d, want 7", chain.Len())
+	}
+
+	mainChain, stale, orphan := chain.Counts()
+	if mainChain != 4 || stale != 2 || orphan != 1 {
+		t.Errorf("Counts() got %d, %d, %d, want 4, 2, 1", mainChain, stale, orphan)
+	}
+
+	// a shorter chain with more work becomes the main chain
+	if err := chain.Add(header("C1", "G", 0x1b00ffff), 1, 0); err != nil {
+		t.Fatalf("Add() returned error\nerror: %v\n", err)
+	}
+	if err := chain.Build(); err != nil {
+		t.Fatalf("Build() returned error\nerror: %v\n", err)
+	}
+	if chain.Tip().Header.BlockHash != namedHash("C1") {
+		t.Errorf("Tip() got %s, want the most-work block C1", chain.Tip().Header.BlockHash)
+	} else if entry, _ := chain.Get(namedHash("A3")); entry.Status != bparser.StatusStale {
+		t.Errorf("Get(A3) got Status = %s, want Status = stale", entry.Status)
+	}
+}
+
+func TestHeaderChainNoGenesis(t *testing.T) {
+	chain := bparser.NewHeaderChain(&bparser.MainNet)
+	if err := chain.Add(bparser.BlockHeaderData{BlockHash: namedHash("A1"), PrevBlock: namedHash("G"), Bits: 0x1d00ffff}, 0, 0); err != nil {
+		t.Fatalf("Add() returned error\nerror: %v\n", err)
+	}
+	if err := chain.Build(); err == nil {
+		t.Errorf("Build() expected an error when the genesis block is missing")
+	}
+}
+
+func TestBuildHeaderChain(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "blk00000.dat"), geneisBlockDec, 0o644); err != nil {
+		t.Fatalf("can not write blk00000.dat\nerror: %v\n", err)
+	}
+
+	blocksDir, err := bparser.OpenBlocksDir(dir)
+	if err != nil {
+		t.Fatalf("OpenBlocksDir() returned error\nerror: %v\n", err)
+	}
+
+	chain, err := bparser.BuildHeaderChain(blocksDir, &bparser.MainNet)
+	if err != nil {
+		t.Fatalf("BuildHeaderChain() returned error\nerror: %v\n", err)
+	}
+
+	tip := chain.Tip()
+	if tip.Header.BlockHash != bparser.MainNet.GenesisHash || tip.Height != 0 {
+		t.Errorf("Tip() got %s at height %d, want genesis at height 0", tip.Header.BlockHash, tip.Height)
+	} else if tip.ChainWork.String() != "4295032833" {
+		t.Errorf("Tip() got ChainWork = %s, want 4295032833", tip.ChainWork)
+	}
+}
@@ -0,0 +1,239 @@
+package bparser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// prefix of coin records, followed by the txid and VARINT output index
+	coinPrefix = 'C'
+	// key holding the hash of the block the chainstate is valid for
+	bestBlockKey = 'B'
+)
+
+// obfuscateKeyKey is the key of the obfuscation key record, a 0x0e byte followed by the serialized string "obfuscate_key"
+var obfuscateKeyKey = append([]byte{0x0e, 0}, "obfuscate_key"...)
+
+// ErrCoinNotFound is returned by Chainstate.Get and UTXOSet.Get when the outpoint is not in the UTXO set.
+var ErrCoinNotFound = errors.New("coin not found in UTXO set")
+
+/*
+OutPoint type identifies a transaction output.
+*/
+type OutPoint struct {
+	TxId Hash
+	Vout uint32
+}
+
+/*
+String method returns the outpoint as txid:vout.
+*/
+func (o OutPoint) String() string {
+	return fmt.Sprintf("%s:%d", o.TxId, o.Vout)
+}
+
+/*
+ParseOutPoint function parses an outpoint written as txid:vout.
+*/
+func ParseOutPoint(s string) (OutPoint, error) {
+	txId, vout, ok := strings.Cut(s, ":")
+	if !ok {
+		errMsg := fmt.Sprintf("expected outpoint as txid:vout but got %q in ParseOutPoint() function\n", s)
+		return OutPoint{}, errors.New(errMsg)
+	}
+	hash, err := ParseHash(txId)
+	if err != nil {
+		return OutPoint{}, err
+	}
+	n, err := strconv.ParseUint(vout, 10, 32)
+	if err != nil {
+		errMsg := fmt.Sprintf("can not parse output index %q in ParseOutPoint() function.\nerror: %v\n", vout, err)
+		return OutPoint{}, errors.New(errMsg)
+	}
+
+	return OutPoint{TxId: hash, Vout: uint32(n)}, nil
+}
+
+/*
+Chainstate type reads bitcoin-core's UTXO set, the chainstate LevelDB database found in the data directory.
+
+Every value in the database is xor'ed with an obfuscation key, which is stored unobfuscated in the database itself.
+Like the block index, the database is locked while bitcoin-core is running.
+
+# Example
+
+	chainstate, _ := OpenChainstate("/home/user/.bitcoin/chainstate")
+	defer chainstate.Close()
+	coin, _ := chainstate.Get(OutPoint{TxId: txid, Vout: 0})
+*/
+type Chainstate struct {
+	db  *leveldb.DB
+	key []byte
+}
+
+/*
+OpenChainstate function opens the chainstate database at path read only and loads its obfuscation key.
+*/
+func OpenChainstate(path string) (*Chainstate, error) {
+	db, err := leveldb.OpenFile(path, &opt.Options{ReadOnly: true, ErrorIfMissing: true})
+	if err != nil {
+		errMsg := fmt.Sprintf("can not open chainstate at %s in OpenChainstate() function.\nerror: %v\n", path, err)
+		return nil, errors.New(errMsg)
+	}
+
+	cs := &Chainstate{db: db}
+	value, err := db.Get(obfuscateKeyKey, nil)
+	if err == nil {
+		// the key is serialized as a vector, a compact size length followed by the key
+		r := varIntReader{b: value}
+		cs.key = r.bytes(int(r.compactSize()))
+		if r.err != nil {
+			db.Close()
+			errMsg := fmt.Sprintf("can not decode obfuscation key in OpenChainstate() function.\nerror: %v\n", r.err)
+			return nil, errors.New(errMsg)
+		}
+		if bytes.Count(cs.key, []byte{0}) == len(cs.key) {
+			cs.key = nil
+		}
+	} else if err != leveldb.ErrNotFound {
+		db.Close()
+		errMsg := fmt.Sprintf("can not read obfuscation key in OpenChainstate() function.\nerror: %v\n", err)
+		return nil, errors.New(errMsg)
+	}
+
+	return cs, nil
+}
+
+/*
+Close method closes the chainstate database.
+*/
+func (cs *Chainstate) Close() error {
+	return cs.db.Close()
+}
+
+/*
+ObfuscationKey method returns the key values are xor'ed with, nil when values are not obfuscated.
+*/
+func (cs *Chainstate) ObfuscationKey() []byte {
+	return cs.key
+}
+
+/*
+BestBlock method returns the hash of the block the UTXO set is valid for.
+*/
+func (cs *Chainstate) BestBlock() (Hash, error) {
+	value, err := cs.get([]byte{bestBlockKey})
+	if err != nil {
+		errMsg := fmt.Sprintf("can not read best block from chainstate in BestBlock() method.\nerror: %v\n", err)
+		return Hash{}, errors.New(errMsg)
+	}
+
+	return NewHash(value)
+}
+
+/*
+Get method returns the unspent output at outpoint, or ErrCoinNotFound when it is spent or never existed.
+*/
+func (cs *Chainstate) Get(outpoint OutPoint) (Coin, error) {
+	value, err := cs.get(coinKey(outpoint))
+	if err == leveldb.ErrNotFound {
+		return Coin{}, ErrCoinNotFound
+	} else if err != nil {
+		errMsg := fmt.Sprintf("can not read coin %s from chainstate in Get() method.\nerror: %v\n", outpoint, err)
+		return Coin{}, errors.New(errMsg)
+	}
+
+	return ParseCoin(value)
+}
+
+/*
+ForEach method calls fn for every unspent output, ordered by txid in internal byte order then by output index.
+Iteration stops at the first error returned by fn.
+*/
+func (cs *Chainstate) ForEach(fn func(OutPoint, Coin) error) error {
+	iter := cs.db.NewIterator(util.BytesPrefix([]byte{coinPrefix}), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		outpoint, err := ParseCoinKey(iter.Key())
+		if err != nil {
+			return err
+		}
+
+		// the iterator owns its value, so copy it before removing the obfuscation
+		value := bytes.Clone(iter.Value())
+		xorBytes(value, cs.key, 0)
+		coin, err := ParseCoin(value)
+		if err != nil {
+			errMsg := fmt.Sprintf("can not decode coin %s in ForEach() method.\nerror: %v\n", outpoint, err)
+			return errors.New(errMsg)
+		}
+
+		if err := fn(outpoint, coin); err != nil {
+			return err
+		}
+	}
+
+	return iter.Error()
+}
+
+/*
+get method reads a value and removes its obfuscation.
+*/
+func (cs *Chainstate) get(key []byte) ([]byte, error) {
+	value, err := cs.db.Get(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	xorBytes(value, cs.key, 0)
+	return value, nil
+}
+
+/*
+ParseCoinKey function decodes the key of a coin record, 'C' followed by the txid in internal byte order and the VARINT output index.
+*/
+func ParseCoinKey(key []byte) (OutPoint, error) {
+	r := varIntReader{b: key}
+	prefix := r.bytes(1)
+	txId := r.bytes(32)
+	vout := r.varInt()
+	if r.err != nil {
+		errMsg := fmt.Sprintf("can not decode coin key %X in ParseCoinKey() function.\nerror: %v\n", key, r.err)
+		return OutPoint{}, errors.New(errMsg)
+	} else if prefix[0] != coinPrefix || r.pos != len(key) || vout > 0xffffffff {
+		errMsg := fmt.Sprintf("%X is not a coin key in ParseCoinKey() function\n", key)
+		return OutPoint{}, errors.New(errMsg)
+	}
+
+	return OutPoint{TxId: Hash(txId), Vout: uint32(vout)}, nil
+}
+
+/*
+ParseCoin function decodes a coin record value after its obfuscation has been removed.
+
+	VARINT height * 2 + coinbase
+	compressed amount and script, see compressedTxOut
+*/
+func ParseCoin(value []byte) (Coin, error) {
+	r := varIntReader{b: value}
+	code := r.varInt()
+	coin := Coin{Height: int(code >> 1), Coinbase: code&1 == 1}
+	coin.Amount, coin.ScriptPubKey = r.compressedTxOut()
+	if r.err != nil {
+		errMsg := fmt.Sprintf("can not decode coin in ParseCoin() function.\nerror: %v\n", r.err)
+		return Coin{}, errors.New(errMsg)
+	} else if r.pos != len(value) {
+		errMsg := fmt.Sprintf("coin has %d bytes left over in ParseCoin() function\n", len(value)-r.pos)
+		return Coin{}, errors.New(errMsg)
+	}
+
+	return coin, nil
+}
@@ -0,0 +1,144 @@
+package bparser_test
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+/*
+writeChainstate function writes a chainstate LevelDB database holding the genesis coinbase output and the first output of the tx
+from block height 672,119, with every value xor'ed with key.
+*/
+func writeChainstate(t *testing.T, key []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "chainstate")
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		t.Fatalf("can not create chainstate\nerror: %v\n", err)
+	}
+	defer db.Close()
+
+	put := func(k []byte, value []byte) {
+		for i := range value {
+			value[i] ^= key[i%len(key)]
+		}
+		if err := db.Put(k, value, nil); err != nil {
+			t.Fatalf("can not write chainstate record\nerror: %v\n", err)
+		}
+	}
+
+	if err := db.Put(append([]byte{0x0e, 0}, "obfuscate_key"...), append([]byte{byte(len(key))}, key...), nil); err != nil {
+		t.Fatalf("can not write obfuscation key\nerror: %v\n", err)
+	}
+
+	genesis := bparser.MainNet.GenesisHash
+	put([]byte{'B'}, genesis[:])
+
+	// genesis coinbase pays 50 BTC to an uncompressed public key
+	value := bparser.AppendVarInt(nil, 0*2+1)
+	value = bparser.AppendCompressedTxOut(value, 5_000_000_000, geneisBlockDec[len(geneisBlockDec)-71:len(geneisBlockDec)-4])
+	put(bparser.AppendVarInt(append([]byte{'C'}, genesisCoinbaseTxId[:]...), 0), value)
+
+	// output 200 of a made up txid, so the output index needs two VARINT bytes
+	value = bparser.AppendVarInt(nil, 672_119*2)
+	value = bparser.AppendCompressedTxOut(value, 206_295, secondTx[len(secondTx)-61:len(secondTx)-36])
+	put(bparser.AppendVarInt(append([]byte{'C'}, bytes.Repeat([]byte{0xab}, 32)...), 200), value)
+
+	return path
+}
+
+var genesisCoinbaseTxId = bparser.MustParseHash("4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b")
+
+func TestChainstate(t *testing.T) {
+	keys := []struct {
+		name string
+		key  []byte
+	}{
+		{name: "obfuscated", key: []byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0}},
+		{name: "not obfuscated", key: make([]byte, 8)},
+	}
+
+	for _, k := range keys {
+		t.Run(k.name, func(t *testing.T) {
+			chainstate, err := bparser.OpenChainstate(writeChainstate(t, k.key))
+			if err != nil {
+				t.Fatalf("OpenChainstate() returned error\nerror: %v\n", err)
+			}
+			defer chainstate.Close()
+
+			if best, err := chainstate.BestBlock(); err != nil || best != bparser.MainNet.GenesisHash {
+				t.Errorf("BestBlock() got %s, want %s\nerror: %v\n", best, bparser.MainNet.GenesisHash, err)
+			}
+
+			coin, err := chainstate.Get(bparser.OutPoint{TxId: genesisCoinbaseTxId, Vout: 0})
+			if err != nil {
+				t.Fatalf("Get() returned error\nerror: %v\n", err)
+			} else if coin.Height != 0 || !coin.Coinbase || coin.Amount != 5_000_000_000 {
+				t.Errorf("Get() got coin = %+v, want the genesis coinbase output", coin)
+			} else if !bytes.Equal(coin.ScriptPubKey, geneisBlockDec[len(geneisBlockDec)-71:len(geneisBlockDec)-4]) {
+				t.Errorf("Get() got ScriptPubKey = %X", coin.ScriptPubKey)
+			}
+
+			if _, err := chainstate.Get(bparser.OutPoint{TxId: genesisCoinbaseTxId, Vout: 1}); err != bparser.ErrCoinNotFound {
+				t.Errorf("Get() expected ErrCoinNotFound for a missing output but got %v", err)
+			}
+
+			var outpoints []bparser.OutPoint
+			var total bparser.Amount
+			err = chainstate.ForEach(func(outpoint bparser.OutPoint, coin bparser.Coin) error {
+				outpoints = append(outpoints, outpoint)
+				total += coin.Amount
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ForEach() returned error\nerror: %v\n", err)
+			}
+
+			// keys are ordered by txid in internal byte order, the genesis coinbase txid starts with 0x3B
+			want := []bparser.OutPoint{
+				{TxId: genesisCoinbaseTxId, Vout: 0},
+				{TxId: bparser.Hash(bytes.Repeat([]byte{0xab}, 32)), Vout: 200},
+			}
+			if !slices.Equal(outpoints, want) {
+				t.Errorf("ForEach() got outpoints %v, want %v", outpoints, want)
+			} else if total != 5_000_000_000+206_295 {
+				t.Errorf("ForEach() got total amount %d, want %d", total, 5_000_000_000+206_295)
+			}
+		})
+	}
+}
+
+func TestParseOutPoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    bparser.OutPoint
+		wantErr bool
+	}{
+		{name: "uppercase txid", s: "4A5E1E4BAAB89F3A32518A88C31BC87F618F76673E2CC77AB2127B7AFDEDA33B:0", want: bparser.OutPoint{TxId: genesisCoinbaseTxId, Vout: 0}},
+		{name: "large output index", s: genesisCoinbaseTxId.String() + ":4294967295", want: bparser.OutPoint{TxId: genesisCoinbaseTxId, Vout: 4294967295}},
+		{name: "missing output index", s: genesisCoinbaseTxId.String(), wantErr: true},
+		{name: "output index too large", s: genesisCoinbaseTxId.String() + ":4294967296", wantErr: true},
+		{name: "short txid", s: "4a5e1e4b:0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bparser.ParseOutPoint(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOutPoint() error = %v, wantErr %v", err, tt.wantErr)
+			} else if got != tt.want {
+				t.Errorf("ParseOutPoint() got = %v, want %v", got, tt.want)
+			} else if !tt.wantErr && got.String() != fmt.Sprintf("%s:%d", tt.want.TxId, tt.want.Vout) {
+				t.Errorf("String() got = %s", got.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package bparser
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// maxScriptSize is the largest script bitcoin-core will store in its undo files and chainstate
+	maxScriptSize = 10_000
+	// numSpecialScripts is the number of script types with a special compressed encoding
+	numSpecialScripts = 6
+)
+
+var (
+	// field prime of secp256k1, used to decompress public keys
+	secp256k1P, _ = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F", 16)
+	// (p + 1) / 4, since p = 3 mod 4 a square root of y is y^((p + 1) / 4)
+	secp256k1SqrtExp = new(big.Int).Rsh(new(big.Int).Add(secp256k1P, big.NewInt(1)), 2)
+)
+
+/*
+Coin type is a transaction output as stored by bitcoin-core in its undo files and chainstate database,
+along with the height of the block that created it and whether it came from a coinbase tx.
+*/
+type Coin struct {
+	Height       int
+	Coinbase     bool
+	Amount       Amount
+	ScriptPubKey []byte
+}
+
+/*
+CompressAmount function compresses an amount in satoshis the same way bitcoin-core does, by removing trailing zeros.
+*/
+func CompressAmount(n uint64) uint64 {
+	if n == 0 {
+		return 0
+	}
+	e := uint64(0)
+	for n%10 == 0 && e < 9 {
+		n /= 10
+		e++
+	}
+	if e < 9 {
+		d := n % 10
+		n /= 10
+		return 1 + (n*9+d-1)*10 + e
+	}
+	return 1 + (n-1)*10 + 9
+}
+
+/*
+DecompressAmount function reverses CompressAmount and returns the amount in satoshis.
+*/
+func DecompressAmount(x uint64) uint64 {
+	if x == 0 {
+		return 0
+	}
+	x--
+	e := x % 10
+	x /= 10
+	var n uint64
+	if e < 9 {
+		d := (x % 9) + 1
+		x /= 9
+		n = x*10 + d
+	} else {
+		n = x + 1
+	}
+	for ; e > 0; e-- {
+		n *= 10
+	}
+	return n
+}
+
+/*
+compressedTxOut method reads a TxOutCompression record, a compressed amount followed by a compressed script.
+
+	VARINT compressed amount
+	VARINT script type or script size + 6
+	20 bytes for types 0 and 1, 32 bytes for types 2 to 5, or the script itself
+*/
+func (r *varIntReader) compressedTxOut() (Amount, []byte) {
+	amount := DecompressAmount(r.varInt())
+	size := r.varInt()
+	if r.err != nil {
+		return 0, nil
+	}
+
+	if size < numSpecialScripts {
+		special := r.bytes(specialScriptSize(size))
+		if r.err != nil {
+			return 0, nil
+		}
+		script, err := decompressScript(size, special)
+		if err != nil {
+			r.err = err
+			return 0, nil
+		}
+		return Amount(amount), script
+	}
+
+	size -= numSpecialScripts
+	if size > maxScriptSize {
+		// bitcoin-core replaces oversized scripts with OP_RETURN since they can never be spent
+		r.bytes(int(size))
+		return Amount(amount), []byte{0x6a}
+	}
+	return Amount(amount), r.bytes(int(size))
+}
+
+func specialScriptSize(scriptType uint64) int {
+	if scriptType == 0 || scriptType == 1 {
+		return 20
+	}
+	return 32
+}
+
+/*
+decompressScript function rebuilds a script from its special compressed form.
+
+- 0 is P2PKH, followed by the 20 byte key hash
+
+- 1 is P2SH, followed by the 20 byte script hash
+
+- 2 and 3 are P2PK with a compressed public key, the type is the public key prefix
+
+- 4 and 5 are P2PK with an uncompressed public key, stored compressed with prefix type - 2
+*/
+func decompressScript(scriptType uint64, data []byte) ([]byte, error) {
+	switch scriptType {
+	case 0:
+		script := []byte{0x76, 0xa9, 20}
+		script = append(script, data...)
+		return append(script, 0x88, 0xac), nil
+	case 1:
+		script := []byte{0xa9, 20}
+		script = append(script, data...)
+		return append(script, 0x87), nil
+	case 2, 3:
+		script := []byte{33, byte(scriptType)}
+		script = append(script, data...)
+		return append(script, 0xac), nil
+	case 4, 5:
+		pubKey, err := decompressPubKey(byte(scriptType-2), data)
+		if err != nil {
+			return nil, err
+		}
+		script := []byte{65}
+		script = append(script, pubKey...)
+		return append(script, 0xac), nil
+	}
+
+	errMsg := fmt.Sprintf("unknown special script type %d in decompressScript() function\n", scriptType)
+	return nil, errors.New(errMsg)
+}
+
+/*
+compressScript function returns the special compressed form of a script, see decompressScript,
+or false when the script has no special form.
+*/
+func compressScript(script []byte) (uint64, []byte, bool) {
+	switch {
+	case len(script) == 25 && script[0] == 0x76 && script[1] == 0xa9 && script[2] == 20 && script[23] == 0x88 && script[24] == 0xac:
+		return 0, script[3:23], true
+	case len(script) == 23 && script[0] == 0xa9 && script[1] == 20 && script[22] == 0x87:
+		return 1, script[2:22], true
+	case len(script) == 35 && script[0] == 33 && script[34] == 0xac && (script[1] == 2 || script[1] == 3):
+		return uint64(script[1]), script[2:34], true
+	case len(script) == 67 && script[0] == 65 && script[66] == 0xac && script[1] == 4:
+		// only valid points can be rebuilt from the x coordinate
+		if !onCurve(script[2:34], script[34:66]) {
+			return 0, nil, false
+		}
+		return 4 + uint64(script[65]&1), script[2:34], true
+	}
+	return 0, nil, false
+}
+
+/*
+AppendCompressedTxOut function appends an amount and script using bitcoin-core's TxOutCompression, see compressedTxOut.
+*/
+func AppendCompressedTxOut(b []byte, amount Amount, script []byte) []byte {
+	b = AppendVarInt(b, CompressAmount(uint64(amount)))
+	if scriptType, data, ok := compressScript(script); ok {
+		b = AppendVarInt(b, scriptType)
+		return append(b, data...)
+	}
+	b = AppendVarInt(b, uint64(len(script))+numSpecialScripts)
+	return append(b, script...)
+}
+
+/*
+decompressPubKey function returns the 65 byte uncompressed public key for the x coordinate, prefix 2 for an even y and 3 for an odd y.
+*/
+func decompressPubKey(prefix byte, x []byte) ([]byte, error) {
+	bx := new(big.Int).SetBytes(x)
+	if bx.Cmp(secp256k1P) >= 0 {
+		return nil, errors.New("public key x coordinate is not in the field in decompressPubKey() function")
+	}
+
+	// y^2 = x^3 + 7
+	y2 := new(big.Int).Exp(bx, big.NewInt(3), secp256k1P)
+	y2.Add(y2, big.NewInt(7))
+	y2.Mod(y2, secp256k1P)
+	y := new(big.Int).Exp(y2, secp256k1SqrtExp, secp256k1P)
+	if new(big.Int).Exp(y, big.NewInt(2), secp256k1P).Cmp(y2) != 0 {
+		return nil, errors.New("public key x coordinate is not on the curve in decompressPubKey() function")
+	}
+	if y.Bit(0) != uint(prefix&1) {
+		y.Sub(secp256k1P, y)
+	}
+
+	pubKey := make([]byte, 65)
+	pubKey[0] = 4
+	bx.FillBytes(pubKey[1:33])
+	y.FillBytes(pubKey[33:])
+	return pubKey, nil
+}
+
+/*
+onCurve function returns true when the point x, y is on secp256k1.
+*/
+func onCurve(x []byte, y []byte) bool {
+	bx, by := new(big.Int).SetBytes(x), new(big.Int).SetBytes(y)
+	if bx.Cmp(secp256k1P) >= 0 || by.Cmp(secp256k1P) >= 0 {
+		return false
+	}
+	lhs := new(big.Int).Exp(by, big.NewInt(2), secp256k1P)
+	rhs := new(big.Int).Exp(bx, big.NewInt(3), secp256k1P)
+	rhs.Add(rhs, big.NewInt(7))
+	rhs.Mod(rhs, secp256k1P)
+	return lhs.Cmp(rhs) == 0
+}
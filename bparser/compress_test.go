@@ -0,0 +1,86 @@
+package bparser_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+/*
+test vectors from bitcoin-core's compress_tests.cpp
+*/
+func TestCompressAmount(t *testing.T) {
+	tests := []struct {
+		amount     uint64
+		compressed uint64
+	}{
+		{amount: 0, compressed: 0x0},
+		{amount: 1, compressed: 0x1},
+		{amount: 1_000_000, compressed: 0x7},
+		{amount: 100_000_000, compressed: 0x9},
+		{amount: 5_000_000_000, compressed: 0x32},
+		{amount: 2_100_000_000_000_000, compressed: 0x1406f40},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.amount), func(t *testing.T) {
+			if got := bparser.CompressAmount(tt.amount); got != tt.compressed {
+				t.Errorf("CompressAmount(%d) got = %#x, want %#x", tt.amount, got, tt.compressed)
+			}
+			if got := bparser.DecompressAmount(tt.compressed); got != tt.amount {
+				t.Errorf("DecompressAmount(%#x) got = %d, want %d", tt.compressed, got, tt.amount)
+			}
+		})
+	}
+
+	for i := uint64(0); i < 100_000; i++ {
+		if got := bparser.DecompressAmount(bparser.CompressAmount(i)); got != i {
+			t.Fatalf("DecompressAmount(CompressAmount(%d)) got = %d", i, got)
+		}
+	}
+}
+
+func TestCompressedTxOut(t *testing.T) {
+	p2pkh := []byte{118, 169, 20, 65, 160, 218, 69, 116, 194, 64, 156, 150, 113, 176, 36, 245, 207, 103, 118, 106, 249, 119, 134, 136, 172}
+	p2sh := []byte{169, 20, 203, 205, 60, 129, 136, 102, 212, 187, 36, 245, 189, 212, 99, 222, 23, 150, 52, 158, 121, 40, 135}
+	// genesis coinbase output pays to an uncompressed public key
+	p2pkUncompressed := geneisBlockDec[len(geneisBlockDec)-71 : len(geneisBlockDec)-4]
+	p2pkCompressed := append(append([]byte{33, 3}, p2pkUncompressed[2:34]...), 0xac)
+	other := []byte{0x6a, 4, 1, 2, 3, 4}
+
+	tests := []struct {
+		name   string
+		script []byte
+		size   int
+	}{
+		{name: "P2PKH", script: p2pkh, size: 1 + 1 + 20},
+		{name: "P2SH", script: p2sh, size: 1 + 1 + 20},
+		{name: "P2PK compressed", script: p2pkCompressed, size: 1 + 1 + 32},
+		{name: "P2PK uncompressed", script: p2pkUncompressed, size: 1 + 1 + 32},
+		{name: "other script", script: other, size: 1 + 1 + len(other)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// coin at height 0 has no version field, so an undo record of one tx with one input is the code followed by the tx out
+			record := []byte{1, 1, 0}
+			record = bparser.AppendCompressedTxOut(record, 5_000_000_000, tt.script)
+			if len(record) != 3+tt.size {
+				t.Errorf("AppendCompressedTxOut() got %d bytes, want %d", len(record)-3, tt.size)
+			}
+
+			undo, err := bparser.ParseBlockUndo(record)
+			if err != nil {
+				t.Fatalf("ParseBlockUndo() returned error\nerror: %v\n", err)
+			}
+			coin := undo.Txs[0].PrevOuts[0]
+			if coin.Amount != 5_000_000_000 {
+				t.Errorf("ParseBlockUndo() got Amount = %d, want %d", coin.Amount, 5_000_000_000)
+			} else if !bytes.Equal(coin.ScriptPubKey, tt.script) {
+				t.Errorf("ParseBlockUndo() got ScriptPubKey = %X, want %X", coin.ScriptPubKey, tt.script)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package export
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+/*
+PostgresDDL is the PostgreSQL schema the files of a CSVWriter are loaded into with COPY, along with the keys and indexes
+to add once they are loaded. It is written to schema.sql beside the files.
+*/
+//go:embed schema.sql
+var PostgresDDL string
+
+/*
+CSVOptions type configures a CSVWriter. Comma is the field delimiter, ',' when not set, and '\t' writes TSV files.
+Witnesses writes the witnesses table as well, see ParquetOptions.
+*/
+type CSVOptions struct {
+	Comma     rune
+	Witnesses bool
+}
+
+/*
+CSVWriter type writes blocks as one CSV file per table in dir, with the same columns as the Parquet tables, in a format
+PostgreSQL's COPY reads with FORMAT csv and HEADER true.
+
+Blocks are written as they are passed, so memory use does not grow with the chain. Null values are written as empty fields,
+binary columns as bytea hex, for example \x76a914, and timestamps in RFC 3339.
+
+# Example
+
+	w, _ := NewCSVWriter("export", CSVOptions{})
+	bparser.ParseMainChain(ctx, dir, chain, 0, 0, func(block bparser.BlockData) error {
+		return w.WriteBlock(&block)
+	})
+	w.Close()
+*/
+type CSVWriter struct {
+	rows Rows
+	// scratch record reused for every row
+	record []string
+
+	files     []*os.File
+	buffers   []*bufio.Writer
+	blocks    *csv.Writer
+	txs       *csv.Writer
+	inputs    *csv.Writer
+	outputs   *csv.Writer
+	witnesses *csv.Writer
+}
+
+/*
+NewCSVWriter function creates dir and a file with a header row for every table in it, along with schema.sql holding PostgresDDL.
+*/
+func NewCSVWriter(dir string, opts CSVOptions) (*CSVWriter, error) {
+	if opts.Comma == 0 {
+		opts.Comma = ','
+	}
+	ext := ".csv"
+	if opts.Comma == '\t' {
+		ext = ".tsv"
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		errMsg := fmt.Sprintf("can not create folder %s in NewCSVWriter() function.\nerror: %v\n", dir, err)
+		return nil, errors.New(errMsg)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "schema.sql"), []byte(PostgresDDL), 0o644); err != nil {
+		errMsg := fmt.Sprintf("can not write schema.sql in NewCSVWriter() function.\nerror: %v\n", err)
+		return nil, errors.New(errMsg)
+	}
+
+	w := &CSVWriter{}
+	create := func(table string, header []string) (*csv.Writer, error) {
+		path := filepath.Join(dir, table+ext)
+		file, err := os.Create(path)
+		if err != nil {
+			errMsg := fmt.Sprintf("can not create %s in NewCSVWriter() function.\nerror: %v\n", path, err)
+			return nil, errors.New(errMsg)
+		}
+		buffer := bufio.NewWriterSize(file, 1<<20)
+		w.files = append(w.files, file)
+		w.buffers = append(w.buffers, buffer)
+
+		writer := csv.NewWriter(buffer)
+		writer.Comma = opts.Comma
+		if err := writer.Write(header); err != nil {
+			errMsg := fmt.Sprintf("can not write header of %s in NewCSVWriter() function.\nerror: %v\n", path, err)
+			return nil, errors.New(errMsg)
+		}
+		return writer, nil
+	}
+
+	type tableFile struct {
+		name   string
+		header []string
+		writer **csv.Writer
+	}
+	tables := []tableFile{
+		{"blocks", blockColumns, &w.blocks},
+		{"transactions", txColumns, &w.txs},
+		{"inputs", inputColumns, &w.inputs},
+		{"outputs", outputColumns, &w.outputs},
+	}
+	if opts.Witnesses {
+		tables = append(tables, tableFile{"witnesses", witnessColumns, &w.witnesses})
+	}
+	for _, table := range tables {
+		writer, err := create(table.name, table.header)
+		if err != nil {
+			for _, file := range w.files {
+				file.Close()
+			}
+			return nil, err
+		}
+		*table.writer = writer
+	}
+
+	return w, nil
+}
+
+// column names of every table, in the order of the fields of the row types
+var (
+	blockColumns   = []string{"height", "hash", "prev_hash", "merkle_root", "version", "time", "bits", "nonce", "difficulty", "size", "stripped_size", "weight", "tx_count"}
+	txColumns      = []string{"height", "tx_index", "txid", "wtxid", "version", "locktime", "size", "vsize", "weight", "segwit", "coinbase", "input_count", "output_count", "fee"}
+	inputColumns   = []string{"height", "txid", "input_index", "prev_txid", "prev_vout", "script_sig", "sequence", "witness_items", "prev_value"}
+	outputColumns  = []string{"height", "txid", "output_index", "value", "script_pubkey", "type", "address"}
+	witnessColumns = []string{"height", "txid", "input_index", "item_index", "item"}
+)
+
+/*
+WriteBlock method writes the rows of block, BlockNumber of the block must be its height.
+*/
+func (w *CSVWriter) WriteBlock(block *bparser.BlockData) error {
+	w.rows.Fill(block, w.witnesses != nil)
+	height := int64(block.BlockNumber)
+
+	b := w.rows.Block
+	w.record = append(w.record[:0], itoa(b.Height), b.Hash, b.PrevHash, b.MerkleRoot, itoa(int64(b.Version)), b.Time.Format(time.RFC3339),
+		utoa(b.Bits), utoa(b.Nonce), strconv.FormatFloat(b.Difficulty, 'g', -1, 64), itoa(b.Size), itoa(b.StrippedSize), itoa(b.Weight), itoa(b.TxCount))
+	if err := w.blocks.Write(w.record); err != nil {
+		return w.writeErr("blocks", height, err)
+	}
+
+	for _, tx := range w.rows.Txs {
+		w.record = append(w.record[:0], itoa(tx.Height), itoa(int64(tx.TxIndex)), tx.TxId, tx.WTxId, utoa(tx.Version), utoa(tx.Locktime),
+			itoa(tx.Size), itoa(tx.VSize), itoa(tx.Weight), strconv.FormatBool(tx.SegWit), strconv.FormatBool(tx.Coinbase),
+			itoa(int64(tx.InputCount)), itoa(int64(tx.OutputCount)), nullableItoa(tx.Fee))
+		if err := w.txs.Write(w.record); err != nil {
+			return w.writeErr("transactions", height, err)
+		}
+	}
+
+	for _, in := range w.rows.Inputs {
+		w.record = append(w.record[:0], itoa(in.Height), in.TxId, itoa(int64(in.InputIndex)), in.PrevTxId, utoa(in.PrevVout), bytea(in.ScriptSig),
+			utoa(in.Sequence), itoa(int64(in.WitnessItems)), nullableItoa(in.PrevValue))
+		if err := w.inputs.Write(w.record); err != nil {
+			return w.writeErr("inputs", height, err)
+		}
+	}
+
+	for _, out := range w.rows.Outputs {
+		address := ""
+		if out.Address != nil {
+			address = *out.Address
+		}
+		w.record = append(w.record[:0], itoa(out.Height), out.TxId, itoa(int64(out.OutputIndex)), itoa(out.Value), bytea(out.ScriptPubKey), out.Type, address)
+		if err := w.outputs.Write(w.record); err != nil {
+			return w.writeErr("outputs", height, err)
+		}
+	}
+
+	for _, item := range w.rows.Witnesses {
+		w.record = append(w.record[:0], itoa(item.Height), item.TxId, itoa(int64(item.InputIndex)), itoa(int64(item.ItemIndex)), bytea(item.Item))
+		if err := w.witnesses.Write(w.record); err != nil {
+			return w.writeErr("witnesses", height, err)
+		}
+	}
+
+	return nil
+}
+
+/*
+Close method flushes and closes every file, a CSVWriter can not be used after Close.
+*/
+func (w *CSVWriter) Close() error {
+	var errs []error
+	for _, writer := range []*csv.Writer{w.blocks, w.txs, w.inputs, w.outputs, w.witnesses} {
+		if writer != nil {
+			writer.Flush()
+			errs = append(errs, writer.Error())
+		}
+	}
+	for i, file := range w.files {
+		errs = append(errs, w.buffers[i].Flush(), file.Close())
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		errMsg := fmt.Sprintf("can not close files in Close() method.\nerror: %v\n", err)
+		return errors.New(errMsg)
+	}
+	return nil
+}
+
+func (w *CSVWriter) writeErr(table string, height int64, err error) error {
+	errMsg := fmt.Sprintf("can not write %s of block at height %d in WriteBlock() method.\nerror: %v\n", table, height, err)
+	return errors.New(errMsg)
+}
+
+func itoa(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
+
+func utoa(u uint32) string {
+	return strconv.FormatUint(uint64(u), 10)
+}
+
+// nullableItoa returns an empty field for nil, which COPY reads as null
+func nullableItoa(i *int64) string {
+	if i == nil {
+		return ""
+	}
+	return itoa(*i)
+}
+
+// bytea returns b in the hex format of postgres' bytea type
+func bytea(b []byte) string {
+	return `\x` + hex.EncodeToString(b)
+}
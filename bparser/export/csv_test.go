@@ -0,0 +1,125 @@
+package export_test
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser/export"
+	"github.com/davidhintelmann/blockchain/bparser/internal/testchain"
+)
+
+/*
+readCSV function returns the header and rows of file in dir.
+*/
+func readCSV(t *testing.T, dir string, file string, comma rune) ([]string, [][]string) {
+	f, err := os.Open(filepath.Join(dir, file))
+	if err != nil {
+		t.Fatalf("can not open %s\nerror: %v\n", file, err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = comma
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("can not read %s\nerror: %v\n", file, err)
+	} else if len(records) == 0 {
+		t.Fatalf("%s has no header", file)
+	}
+	return records[0], records[1:]
+}
+
+func TestCSVWriter(t *testing.T) {
+	tests := []struct {
+		comma rune
+		ext   string
+	}{
+		{0, ".csv"},
+		{'\t', ".tsv"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		w, err := export.NewCSVWriter(dir, export.CSVOptions{Comma: test.comma, Witnesses: true})
+		if err != nil {
+			t.Fatalf("NewCSVWriter() returned error\nerror: %v\n", err)
+		}
+		for _, block := range testchain.Blocks(t) {
+			if err := w.WriteBlock(&block); err != nil {
+				t.Fatalf("WriteBlock() returned error\nerror: %v\n", err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close() returned error\nerror: %v\n", err)
+		}
+
+		comma := test.comma
+		if comma == 0 {
+			comma = ','
+		}
+		rowCounts := map[string]int{"blocks": 2, "transactions": 3, "inputs": 4, "outputs": 4, "witnesses": 2}
+		for table, want := range rowCounts {
+			if _, rows := readCSV(t, dir, table+test.ext, comma); len(rows) != want {
+				t.Errorf("%s%s got %d rows, want %d", table, test.ext, len(rows), want)
+			}
+		}
+
+		header, blocks := readCSV(t, dir, "blocks"+test.ext, comma)
+		genesis := strings.Join(blocks[0], "|")
+		want := "0|000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f|0000000000000000000000000000000000000000000000000000000000000000|4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b|1|2009-01-03T18:15:05Z|486604799|2083236893|1|285|285|1140|1"
+		if genesis != want {
+			t.Errorf("blocks%s got %s, want %s", test.ext, genesis, want)
+		} else if header[0] != "height" || header[len(header)-1] != "tx_count" {
+			t.Errorf("blocks%s got header %v", test.ext, header)
+		}
+
+		// the fee and spent value are not known, so they are empty which COPY reads as null
+		_, txs := readCSV(t, dir, "transactions"+test.ext, comma)
+		if fee := txs[2][13]; fee != "" {
+			t.Errorf("transactions%s got fee %q, want an empty field", test.ext, fee)
+		}
+		_, outputs := readCSV(t, dir, "outputs"+test.ext, comma)
+		if script, address := outputs[2][4], outputs[2][6]; script != `\x76a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac` || address == "" {
+			t.Errorf("outputs%s got script_pubkey %s and address %q", test.ext, script, address)
+		} else if address := outputs[0][6]; address != "" {
+			t.Errorf("outputs%s got address %q for a P2PK output, want an empty field", test.ext, address)
+		}
+
+		if ddl, _ := os.ReadFile(filepath.Join(dir, "schema.sql")); string(ddl) != export.PostgresDDL {
+			t.Errorf("NewCSVWriter() did not write PostgresDDL to schema.sql")
+		}
+	}
+}
+
+/*
+test the columns of every CREATE TABLE in PostgresDDL match the header of the file of the table, so COPY loads every column
+into the right place
+*/
+func TestPostgresDDLColumns(t *testing.T) {
+	dir := t.TempDir()
+	w, err := export.NewCSVWriter(dir, export.CSVOptions{Witnesses: true})
+	if err != nil {
+		t.Fatalf("NewCSVWriter() returned error\nerror: %v\n", err)
+	}
+	w.Close()
+
+	createTable := regexp.MustCompile(`(?s)CREATE TABLE (\w+) \((.*?)\n\);`)
+	matches := createTable.FindAllStringSubmatch(export.PostgresDDL, -1)
+	if len(matches) != 5 {
+		t.Fatalf("PostgresDDL got %d tables, want 5", len(matches))
+	}
+	for _, match := range matches {
+		var columns []string
+		for _, line := range strings.Split(strings.TrimSpace(match[2]), "\n") {
+			columns = append(columns, strings.Fields(line)[0])
+		}
+
+		header, _ := readCSV(t, dir, match[1]+".csv", ',')
+		if strings.Join(columns, ",") != strings.Join(header, ",") {
+			t.Errorf("PostgresDDL table %s got columns %v, want %v", match[1], columns, header)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+// DefaultPartitionSize is the number of heights in each file when ParquetOptions.PartitionSize is not set
+const DefaultPartitionSize = 10_000
+
+/*
+ParquetOptions type configures a ParquetWriter. Witness items are the largest table by far and most analysis does not
+need them, so the witnesses table is only written when Witnesses is true.
+*/
+type ParquetOptions struct {
+	PartitionSize int
+	Witnesses     bool
+}
+
+/*
+ParquetWriter type writes blocks as Parquet tables in dir, partitioned by height range.
+
+Each table has its own folder and each partition of PartitionSize heights its own zstd compressed file,
+for example blocks/blocks-000010000-000019999.parquet holds heights 10,000 to 19,999. Blocks must be written
+in height order, as ParseMainChain passes them, and a partition is closed as soon as a block of a later partition is written.
+
+# Example
+
+	w, _ := NewParquetWriter("export", ParquetOptions{Witnesses: true})
+	bparser.ParseMainChain(ctx, dir, chain, 0, 0, func(block bparser.BlockData) error {
+		return w.WriteBlock(&block)
+	})
+	w.Close()
+*/
+type ParquetWriter struct {
+	dir  string
+	opts ParquetOptions
+	rows Rows
+
+	// first height of the open partition, -1 before the first block
+	start     int64
+	last      int64
+	files     []*os.File
+	writers   []io.Closer
+	blocks    *parquet.GenericWriter[BlockRow]
+	txs       *parquet.GenericWriter[TxRow]
+	inputs    *parquet.GenericWriter[InputRow]
+	outputs   *parquet.GenericWriter[OutputRow]
+	witnesses *parquet.GenericWriter[WitnessRow]
+}
+
+/*
+NewParquetWriter function returns a ParquetWriter writing to dir, the table folders are created when the first block is written.
+*/
+func NewParquetWriter(dir string, opts ParquetOptions) (*ParquetWriter, error) {
+	if opts.PartitionSize < 0 {
+		errMsg := fmt.Sprintf("partition size %d is negative in NewParquetWriter() function\n", opts.PartitionSize)
+		return nil, errors.New(errMsg)
+	} else if opts.PartitionSize == 0 {
+		opts.PartitionSize = DefaultPartitionSize
+	}
+
+	return &ParquetWriter{dir: dir, opts: opts, start: -1, last: -1}, nil
+}
+
+/*
+WriteBlock method writes the rows of block, BlockNumber of the block must be its height.
+*/
+func (w *ParquetWriter) WriteBlock(block *bparser.BlockData) error {
+	height := int64(block.BlockNumber)
+	if height <= w.last {
+		errMsg := fmt.Sprintf("block %s at height %d is not after height %d in WriteBlock() method\n", block.Header.BlockHash, height, w.last)
+		return errors.New(errMsg)
+	}
+
+	if start := height - height%int64(w.opts.PartitionSize); start != w.start {
+		if err := w.closePartition(); err != nil {
+			return err
+		}
+		if err := w.openPartition(start); err != nil {
+			return err
+		}
+	}
+
+	w.rows.Fill(block, w.opts.Witnesses)
+	if _, err := w.blocks.Write([]BlockRow{w.rows.Block}); err != nil {
+		return w.writeErr("blocks", height, err)
+	}
+	if _, err := w.txs.Write(w.rows.Txs); err != nil {
+		return w.writeErr("transactions", height, err)
+	}
+	if _, err := w.inputs.Write(w.rows.Inputs); err != nil {
+		return w.writeErr("inputs", height, err)
+	}
+	if _, err := w.outputs.Write(w.rows.Outputs); err != nil {
+		return w.writeErr("outputs", height, err)
+	}
+	if w.witnesses != nil {
+		if _, err := w.witnesses.Write(w.rows.Witnesses); err != nil {
+			return w.writeErr("witnesses", height, err)
+		}
+	}
+
+	w.last = height
+	return nil
+}
+
+/*
+Close method finishes the open partition, a ParquetWriter can not be used after Close.
+*/
+func (w *ParquetWriter) Close() error {
+	return w.closePartition()
+}
+
+/*
+openPartition method creates the files of every table for the partition starting at height start.
+*/
+func (w *ParquetWriter) openPartition(start int64) error {
+	end := start + int64(w.opts.PartitionSize) - 1
+	create := func(table string) (*os.File, error) {
+		if err := os.MkdirAll(filepath.Join(w.dir, table), 0o755); err != nil {
+			errMsg := fmt.Sprintf("can not create folder for table %s in openPartition() method.\nerror: %v\n", table, err)
+			return nil, errors.New(errMsg)
+		}
+		path := filepath.Join(w.dir, table, fmt.Sprintf("%s-%09d-%09d.parquet", table, start, end))
+		file, err := os.Create(path)
+		if err != nil {
+			errMsg := fmt.Sprintf("can not create %s in openPartition() method.\nerror: %v\n", path, err)
+			return nil, errors.New(errMsg)
+		}
+		w.files = append(w.files, file)
+		return file, nil
+	}
+
+	options := []parquet.WriterOption{
+		parquet.Compression(&parquet.Zstd),
+		parquet.KeyValueMetadata("bparser.schema_version", SchemaVersion),
+	}
+	file, err := create("blocks")
+	if err != nil {
+		return err
+	}
+	w.blocks = parquet.NewGenericWriter[BlockRow](file, options...)
+	w.writers = append(w.writers, w.blocks)
+	if file, err = create("transactions"); err != nil {
+		return err
+	}
+	w.txs = parquet.NewGenericWriter[TxRow](file, options...)
+	w.writers = append(w.writers, w.txs)
+	if file, err = create("inputs"); err != nil {
+		return err
+	}
+	w.inputs = parquet.NewGenericWriter[InputRow](file, options...)
+	w.writers = append(w.writers, w.inputs)
+	if file, err = create("outputs"); err != nil {
+		return err
+	}
+	w.outputs = parquet.NewGenericWriter[OutputRow](file, options...)
+	w.writers = append(w.writers, w.outputs)
+	if w.opts.Witnesses {
+		if file, err = create("witnesses"); err != nil {
+			return err
+		}
+		w.witnesses = parquet.NewGenericWriter[WitnessRow](file, options...)
+		w.writers = append(w.writers, w.witnesses)
+	}
+
+	w.start = start
+	return nil
+}
+
+/*
+closePartition method writes the footer of every table of the open partition and closes its files.
+*/
+func (w *ParquetWriter) closePartition() error {
+	// the parquet writers write their footer to the files, so they are closed first
+	var errs []error
+	for _, writer := range w.writers {
+		errs = append(errs, writer.Close())
+	}
+	for _, file := range w.files {
+		errs = append(errs, file.Close())
+	}
+
+	w.blocks, w.txs, w.inputs, w.outputs, w.witnesses = nil, nil, nil, nil, nil
+	w.writers, w.files = w.writers[:0], w.files[:0]
+	if err := errors.Join(errs...); err != nil {
+		errMsg := fmt.Sprintf("can not close partition starting at height %d in closePartition() method.\nerror: %v\n", w.start, err)
+		return errors.New(errMsg)
+	}
+	return nil
+}
+
+func (w *ParquetWriter) writeErr(table string, height int64, err error) error {
+	errMsg := fmt.Sprintf("can not write %s of block at height %d in WriteBlock() method.\nerror: %v\n", table, height, err)
+	return errors.New(errMsg)
+}
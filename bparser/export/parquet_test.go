@@ -0,0 +1,109 @@
+package export_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/davidhintelmann/blockchain/bparser/export"
+	"github.com/davidhintelmann/blockchain/bparser/internal/testchain"
+)
+
+func TestParquetWriter(t *testing.T) {
+	dir := t.TempDir()
+	w, err := export.NewParquetWriter(dir, export.ParquetOptions{PartitionSize: 1, Witnesses: true})
+	if err != nil {
+		t.Fatalf("NewParquetWriter() returned error\nerror: %v\n", err)
+	}
+	for _, block := range testchain.Blocks(t) {
+		if err := w.WriteBlock(&block); err != nil {
+			t.Fatalf("WriteBlock() returned error\nerror: %v\n", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() returned error\nerror: %v\n", err)
+	}
+
+	blocks, err := parquet.ReadFile[export.BlockRow](filepath.Join(dir, "blocks", "blocks-000000000-000000000.parquet"))
+	if err != nil {
+		t.Fatalf("parquet.ReadFile() returned error\nerror: %v\n", err)
+	} else if len(blocks) != 1 {
+		t.Fatalf("blocks partition 0 got %d rows, want 1", len(blocks))
+	}
+	genesis := blocks[0]
+	if genesis.Hash != "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f" || genesis.Bits != 0x1d00ffff || genesis.Time.Unix() != 1231006505 {
+		t.Errorf("blocks partition 0 got %+v", genesis)
+	} else if genesis.Size != 285 || genesis.Weight != 1140 || genesis.TxCount != 1 {
+		t.Errorf("blocks partition 0 got size = %d, weight = %d, tx count = %d", genesis.Size, genesis.Weight, genesis.TxCount)
+	}
+
+	tests := []struct {
+		table string
+		rows  int
+	}{
+		{"blocks", 1},
+		{"transactions", 2},
+		{"inputs", 3},
+		{"outputs", 3},
+		{"witnesses", 2},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.table, test.table+"-000000001-000000001.parquet")
+		file, err := parquet.OpenFile(openFile(t, path))
+		if err != nil {
+			t.Errorf("parquet.OpenFile() of %s returned error\nerror: %v\n", path, err)
+			continue
+		}
+		if rows := file.NumRows(); rows != int64(test.rows) {
+			t.Errorf("%s partition 1 got %d rows, want %d", test.table, rows, test.rows)
+		}
+		if version, _ := file.Lookup("bparser.schema_version"); version != export.SchemaVersion {
+			t.Errorf("%s partition 1 got schema version %q, want %q", test.table, version, export.SchemaVersion)
+		}
+	}
+
+	txs, _ := parquet.ReadFile[export.TxRow](filepath.Join(dir, "transactions", "transactions-000000001-000000001.parquet"))
+	if len(txs) == 2 && (!txs[0].Coinbase || txs[1].Coinbase || !txs[1].SegWit || txs[1].Locktime != 17 || txs[1].Fee != nil) {
+		t.Errorf("transactions partition 1 got %+v", txs)
+	}
+	inputs, _ := parquet.ReadFile[export.InputRow](filepath.Join(dir, "inputs", "inputs-000000001-000000001.parquet"))
+	if len(inputs) == 3 && (inputs[2].PrevTxId != "8ac60eb9575db5b2d987e29f301b5b819ea83a5c6579d282d189cc04b8e151ef" || inputs[2].PrevVout != 1 || inputs[2].WitnessItems != 2) {
+		t.Errorf("inputs partition 1 got %+v", inputs[2])
+	}
+	outputs, _ := parquet.ReadFile[export.OutputRow](filepath.Join(dir, "outputs", "outputs-000000001-000000001.parquet"))
+	if len(outputs) == 3 && (outputs[1].Value != 112_340_000 || outputs[1].Type != "pubkeyhash" || outputs[1].Address == nil || outputs[0].Address != nil) {
+		t.Errorf("outputs partition 1 got %+v", outputs)
+	}
+}
+
+func TestParquetWriterHeightOrder(t *testing.T) {
+	blocks := testchain.Blocks(t)
+	w, err := export.NewParquetWriter(t.TempDir(), export.ParquetOptions{})
+	if err != nil {
+		t.Fatalf("NewParquetWriter() returned error\nerror: %v\n", err)
+	}
+	defer w.Close()
+
+	if err := w.WriteBlock(&blocks[1]); err != nil {
+		t.Fatalf("WriteBlock() returned error\nerror: %v\n", err)
+	}
+	if err := w.WriteBlock(&blocks[0]); err == nil {
+		t.Errorf("WriteBlock() of height 0 after height 1 expected an error")
+	}
+
+	if _, err := export.NewParquetWriter(t.TempDir(), export.ParquetOptions{PartitionSize: -1}); err == nil {
+		t.Errorf("NewParquetWriter() with a negative partition size expected an error")
+	}
+}
+
+func openFile(t *testing.T, path string) (*os.File, int64) {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("can not open %s\nerror: %v\n", path, err)
+	}
+	t.Cleanup(func() { file.Close() })
+	info, _ := file.Stat()
+	return file, info.Size()
+}
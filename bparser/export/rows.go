@@ -0,0 +1,202 @@
+/*
+package export writes parsed blocks as normalized tables, one row per block, tx, input, output and witness item,
+for loading into dataframes and databases. The tables and their columns are documented in SCHEMA.md.
+*/
+package export
+
+import (
+	"time"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+// SchemaVersion is bumped whenever a column is added, removed or changes type, it is written to the metadata of every file
+const SchemaVersion = "1"
+
+/*
+BlockRow type is a row of the blocks table.
+*/
+type BlockRow struct {
+	Height       int64     `parquet:"height"`
+	Hash         string    `parquet:"hash"`
+	PrevHash     string    `parquet:"prev_hash"`
+	MerkleRoot   string    `parquet:"merkle_root"`
+	Version      int32     `parquet:"version"`
+	Time         time.Time `parquet:"time,timestamp(millisecond)"`
+	Bits         uint32    `parquet:"bits"`
+	Nonce        uint32    `parquet:"nonce"`
+	Difficulty   float64   `parquet:"difficulty"`
+	Size         int64     `parquet:"size"`
+	StrippedSize int64     `parquet:"stripped_size"`
+	Weight       int64     `parquet:"weight"`
+	TxCount      int64     `parquet:"tx_count"`
+}
+
+/*
+TxRow type is a row of the transactions table, Fee is null unless the spent outputs of the tx were attached.
+*/
+type TxRow struct {
+	Height      int64  `parquet:"height"`
+	TxIndex     int32  `parquet:"tx_index"`
+	TxId        string `parquet:"txid"`
+	WTxId       string `parquet:"wtxid"`
+	Version     uint32 `parquet:"version"`
+	Locktime    uint32 `parquet:"locktime"`
+	Size        int64  `parquet:"size"`
+	VSize       int64  `parquet:"vsize"`
+	Weight      int64  `parquet:"weight"`
+	SegWit      bool   `parquet:"segwit"`
+	Coinbase    bool   `parquet:"coinbase"`
+	InputCount  int32  `parquet:"input_count"`
+	OutputCount int32  `parquet:"output_count"`
+	Fee         *int64 `parquet:"fee,optional"`
+}
+
+/*
+InputRow type is a row of the inputs table, PrevValue is null unless the spent output was attached.
+*/
+type InputRow struct {
+	Height       int64  `parquet:"height"`
+	TxId         string `parquet:"txid"`
+	InputIndex   int32  `parquet:"input_index"`
+	PrevTxId     string `parquet:"prev_txid"`
+	PrevVout     uint32 `parquet:"prev_vout"`
+	ScriptSig    []byte `parquet:"script_sig"`
+	Sequence     uint32 `parquet:"sequence"`
+	WitnessItems int32  `parquet:"witness_items"`
+	PrevValue    *int64 `parquet:"prev_value,optional"`
+}
+
+/*
+OutputRow type is a row of the outputs table, Address is null for outputs without an address.
+*/
+type OutputRow struct {
+	Height       int64   `parquet:"height"`
+	TxId         string  `parquet:"txid"`
+	OutputIndex  int32   `parquet:"output_index"`
+	Value        int64   `parquet:"value"`
+	ScriptPubKey []byte  `parquet:"script_pubkey"`
+	Type         string  `parquet:"type,dict"`
+	Address      *string `parquet:"address,optional"`
+}
+
+/*
+WitnessRow type is a row of the witnesses table, a single item of the witness stack of an input.
+*/
+type WitnessRow struct {
+	Height     int64  `parquet:"height"`
+	TxId       string `parquet:"txid"`
+	InputIndex int32  `parquet:"input_index"`
+	ItemIndex  int32  `parquet:"item_index"`
+	Item       []byte `parquet:"item"`
+}
+
+/*
+Writer interface is implemented by ParquetWriter and CSVWriter, blocks are passed to WriteBlock in height order.
+*/
+type Writer interface {
+	WriteBlock(block *bparser.BlockData) error
+	Close() error
+}
+
+/*
+Rows type holds the rows of every table for a single block. The slices are reused by Fill, so rows must be written
+before the next block is filled.
+*/
+type Rows struct {
+	Block     BlockRow
+	Txs       []TxRow
+	Inputs    []InputRow
+	Outputs   []OutputRow
+	Witnesses []WitnessRow
+}
+
+/*
+Fill method replaces the rows with the rows of block, BlockNumber of the block is used as its height.
+Witness rows are only filled when witnesses is true.
+*/
+func (r *Rows) Fill(block *bparser.BlockData, witnesses bool) {
+	height := int64(block.BlockNumber)
+	header := block.Header
+	r.Block = BlockRow{
+		Height:       height,
+		Hash:         header.BlockHash.String(),
+		PrevHash:     header.PrevBlock.String(),
+		MerkleRoot:   header.MerkleRoot.String(),
+		Version:      int32(header.Version),
+		Time:         header.Timestamp.UTC(),
+		Nonce:        uint32(header.Nonce),
+		Bits:         header.Bits,
+		Difficulty:   header.Difficulty,
+		Size:         block.Size,
+		StrippedSize: block.StrippedSize,
+		Weight:       block.Weight,
+		TxCount:      int64(len(block.Tx.Tx)),
+	}
+
+	r.Txs, r.Inputs, r.Outputs, r.Witnesses = r.Txs[:0], r.Inputs[:0], r.Outputs[:0], r.Witnesses[:0]
+	for i, tx := range block.Tx.Tx {
+		txId := tx.TxId.String()
+		txRow := TxRow{
+			Height:      height,
+			TxIndex:     int32(i),
+			TxId:        txId,
+			WTxId:       tx.WTxId.String(),
+			Version:     uint32(tx.Version),
+			Locktime:    tx.LocktimeNumber(),
+			Size:        tx.Size,
+			VSize:       tx.VSize,
+			Weight:      tx.Weight,
+			SegWit:      tx.SegWit,
+			Coinbase:    tx.IsCoinbase(),
+			InputCount:  int32(len(tx.Inputs)),
+			OutputCount: int32(len(tx.Outputs)),
+		}
+		if tx.FeeKnown() {
+			fee := int64(tx.Fee)
+			txRow.Fee = &fee
+		}
+		r.Txs = append(r.Txs, txRow)
+
+		for j, in := range tx.Inputs {
+			inputRow := InputRow{
+				Height:       height,
+				TxId:         txId,
+				InputIndex:   int32(j),
+				PrevTxId:     in.TxId.String(),
+				PrevVout:     in.Vout,
+				ScriptSig:    in.ScriptSig,
+				Sequence:     in.Sequence,
+				WitnessItems: int32(len(in.Witness)),
+			}
+			if in.PrevOut != nil {
+				prevValue := int64(in.PrevOut.Amount)
+				inputRow.PrevValue = &prevValue
+			}
+			r.Inputs = append(r.Inputs, inputRow)
+
+			if !witnesses {
+				continue
+			}
+			for k, item := range in.Witness {
+				r.Witnesses = append(r.Witnesses, WitnessRow{Height: height, TxId: txId, InputIndex: int32(j), ItemIndex: int32(k), Item: item})
+			}
+		}
+
+		for j, out := range tx.Outputs {
+			outputRow := OutputRow{
+				Height:       height,
+				TxId:         txId,
+				OutputIndex:  int32(j),
+				Value:        int64(out.Amount),
+				ScriptPubKey: out.ScriptPubKey,
+				Type:         out.Type.String(),
+			}
+			if out.Address != "" {
+				address := out.Address
+				outputRow.Address = &address
+			}
+			r.Outputs = append(r.Outputs, outputRow)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+-- PostgreSQL tables for the files written by export.CSVWriter, schema version 1, see SCHEMA.md for the columns.
+--
+-- Load the files in order, from the folder they were written to:
+--
+--   \copy blocks FROM 'blocks.csv' WITH (FORMAT csv, HEADER true)
+--   \copy transactions FROM 'transactions.csv' WITH (FORMAT csv, HEADER true)
+--   \copy inputs FROM 'inputs.csv' WITH (FORMAT csv, HEADER true)
+--   \copy outputs FROM 'outputs.csv' WITH (FORMAT csv, HEADER true)
+--   \copy witnesses FROM 'witnesses.csv' WITH (FORMAT csv, HEADER true)
+--
+-- TSV files end in .tsv and are loaded the same way with DELIMITER E'\t' added to the options.
+-- Unsigned 32 bit columns are bigint, since postgres has no unsigned types.
+--
+-- Txids are not unique across the chain, the coinbase txs of blocks 91,842 and 91,880 repeat those
+-- of blocks 91,812 and 91,722, so txs, inputs and outputs are keyed by height as well.
+
+CREATE TABLE blocks (
+    height        bigint           NOT NULL,
+    hash          char(64)         NOT NULL,
+    prev_hash     char(64)         NOT NULL,
+    merkle_root   char(64)         NOT NULL,
+    version       integer          NOT NULL,
+    time          timestamptz      NOT NULL,
+    bits          bigint           NOT NULL,
+    nonce         bigint           NOT NULL,
+    difficulty    double precision NOT NULL,
+    size          bigint           NOT NULL,
+    stripped_size bigint           NOT NULL,
+    weight        bigint           NOT NULL,
+    tx_count      bigint           NOT NULL
+);
+
+CREATE TABLE transactions (
+    height       bigint   NOT NULL,
+    tx_index     integer  NOT NULL,
+    txid         char(64) NOT NULL,
+    wtxid        char(64) NOT NULL,
+    version      bigint   NOT NULL,
+    locktime     bigint   NOT NULL,
+    size         bigint   NOT NULL,
+    vsize        bigint   NOT NULL,
+    weight       bigint   NOT NULL,
+    segwit       boolean  NOT NULL,
+    coinbase     boolean  NOT NULL,
+    input_count  integer  NOT NULL,
+    output_count integer  NOT NULL,
+    fee          bigint
+);
+
+CREATE TABLE inputs (
+    height        bigint   NOT NULL,
+    txid          char(64) NOT NULL,
+    input_index   integer  NOT NULL,
+    prev_txid     char(64) NOT NULL,
+    prev_vout     bigint   NOT NULL,
+    script_sig    bytea    NOT NULL,
+    sequence      bigint   NOT NULL,
+    witness_items integer  NOT NULL,
+    prev_value    bigint
+);
+
+CREATE TABLE outputs (
+    height        bigint   NOT NULL,
+    txid          char(64) NOT NULL,
+    output_index  integer  NOT NULL,
+    value         bigint   NOT NULL,
+    script_pubkey bytea    NOT NULL,
+    type          text     NOT NULL,
+    address       text
+);
+
+CREATE TABLE witnesses (
+    height      bigint   NOT NULL,
+    txid        char(64) NOT NULL,
+    input_index integer  NOT NULL,
+    item_index  integer  NOT NULL,
+    item        bytea    NOT NULL
+);
+
+-- Keys and indexes are added after loading, COPY is many times faster into tables without them.
+
+ALTER TABLE blocks ADD PRIMARY KEY (height);
+ALTER TABLE blocks ADD UNIQUE (hash);
+
+ALTER TABLE transactions ADD PRIMARY KEY (height, tx_index);
+ALTER TABLE transactions ADD UNIQUE (height, txid);
+ALTER TABLE transactions ADD FOREIGN KEY (height) REFERENCES blocks (height);
+CREATE INDEX transactions_txid_idx ON transactions (txid);
+
+ALTER TABLE inputs ADD PRIMARY KEY (height, txid, input_index);
+ALTER TABLE inputs ADD FOREIGN KEY (height, txid) REFERENCES transactions (height, txid);
+CREATE INDEX inputs_prev_idx ON inputs (prev_txid, prev_vout);
+
+ALTER TABLE outputs ADD PRIMARY KEY (height, txid, output_index);
+ALTER TABLE outputs ADD FOREIGN KEY (height, txid) REFERENCES transactions (height, txid);
+CREATE INDEX outputs_txid_idx ON outputs (txid, output_index);
+CREATE INDEX outputs_address_idx ON outputs (address) WHERE address IS NOT NULL;
+
+ALTER TABLE witnesses ADD PRIMARY KEY (height, txid, input_index, item_index);
+ALTER TABLE witnesses ADD FOREIGN KEY (height, txid, input_index) REFERENCES inputs (height, txid, input_index);
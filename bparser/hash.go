@@ -0,0 +1,106 @@
+package bparser
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+/*
+Hash type is a double sha256 hash, such as a block hash, txid or merkle root, in the internal byte order it is serialized
+and hashed in. String returns the conventional display order used by bitcoin-core and block explorers, which is reversed.
+
+# Example
+
+	genesis := MustParseHash("000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f")
+	genesis[31] // 0x00, the leading zeros of the display order are the last bytes of the hash
+*/
+type Hash [32]byte
+
+/*
+NewHash function returns the hash of b, which is 32 bytes in internal byte order.
+*/
+func NewHash(b []byte) (Hash, error) {
+	if len(b) != len(Hash{}) {
+		errMsg := fmt.Sprintf("expected a 32 byte hash but got %d bytes in NewHash() function\n", len(b))
+		return Hash{}, errors.New(errMsg)
+	}
+	return Hash(b), nil
+}
+
+/*
+ParseHash function parses a 64 character hex hash written in display order, upper or lower case.
+*/
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	if len(s) != 2*len(h) {
+		errMsg := fmt.Sprintf("expected a 64 character hex hash but got %q in ParseHash() function\n", s)
+		return Hash{}, errors.New(errMsg)
+	}
+	if _, err := hex.Decode(h[:], []byte(s)); err != nil {
+		errMsg := fmt.Sprintf("can not decode hash %q in ParseHash() function.\nerror: %v\n", s, err)
+		return Hash{}, errors.New(errMsg)
+	}
+	h.reverse()
+	return h, nil
+}
+
+/*
+MustParseHash function is ParseHash for hashes known to be valid, such as constants, it panics when s can not be parsed.
+*/
+func MustParseHash(s string) Hash {
+	h, err := ParseHash(s)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+/*
+String method returns the hash in display order as lower case hex.
+*/
+func (h Hash) String() string {
+	var b [64]byte
+	h.appendHex(b[:0])
+	return string(b[:])
+}
+
+/*
+IsZero method reports whether every byte of the hash is zero, such as the previous block of the genesis block.
+*/
+func (h Hash) IsZero() bool {
+	return h == Hash{}
+}
+
+/*
+MarshalText method writes the hash as String does, so hashes are written as hex strings in JSON.
+*/
+func (h Hash) MarshalText() ([]byte, error) {
+	return h.appendHex(make([]byte, 0, 64)), nil
+}
+
+/*
+UnmarshalText method parses a hash written in display order, see ParseHash.
+*/
+func (h *Hash) UnmarshalText(text []byte) error {
+	parsed, err := ParseHash(string(text))
+	if err != nil {
+		return err
+	}
+	*h = parsed
+	return nil
+}
+
+func (h Hash) appendHex(b []byte) []byte {
+	const digits = "0123456789abcdef"
+	for i := len(h) - 1; i >= 0; i-- {
+		b = append(b, digits[h[i]>>4], digits[h[i]&0x0f])
+	}
+	return b
+}
+
+func (h *Hash) reverse() {
+	for i, j := 0, len(h)-1; i < j; i, j = i+1, j-1 {
+		h[i], h[j] = h[j], h[i]
+	}
+}
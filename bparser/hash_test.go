@@ -0,0 +1,90 @@
+package bparser_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+const genesisHashStr = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+
+func TestParseHash(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{genesisHashStr, genesisHashStr, false},
+		{"000000000019D6689C085AE165831E934FF763AE46A2A6C172B3F1B60A8CE26F", genesisHashStr, false},
+		{"0000000000000000000000000000000000000000000000000000000000000000", "0000000000000000000000000000000000000000000000000000000000000000", false},
+		{genesisHashStr[2:], "", true},
+		{genesisHashStr + "00", "", true},
+		{"zz" + genesisHashStr[2:], "", true},
+		{"", "", true},
+	}
+
+	for _, test := range tests {
+		got, err := bparser.ParseHash(test.s)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseHash(%q) error = %v, wantErr %v", test.s, err, test.wantErr)
+		} else if err == nil && got.String() != test.want {
+			t.Errorf("ParseHash(%q) got = %s, want %s", test.s, got, test.want)
+		}
+	}
+
+	// the display order is the reverse of the internal order
+	genesis := bparser.MustParseHash(genesisHashStr)
+	if genesis[0] != 0x6f || genesis[31] != 0x00 {
+		t.Errorf("MustParseHash() got internal order %x, want reversed display order", genesis[:])
+	} else if genesis != bparser.MainNet.GenesisHash {
+		t.Errorf("MustParseHash() got %s, want %s", genesis, bparser.MainNet.GenesisHash)
+	}
+	if genesis.IsZero() || !(bparser.Hash{}).IsZero() {
+		t.Errorf("IsZero() got %v for the genesis hash and %v for the zero hash", genesis.IsZero(), (bparser.Hash{}).IsZero())
+	}
+}
+
+func TestNewHash(t *testing.T) {
+	if _, err := bparser.NewHash(bparser.MainNet.GenesisHash[:31]); err == nil {
+		t.Errorf("NewHash() expected an error for a 31 byte hash")
+	}
+
+	hash, err := bparser.NewHash(bparser.MainNet.GenesisHash[:])
+	if err != nil {
+		t.Fatalf("NewHash() returned error\nerror: %v\n", err)
+	} else if hash.String() != genesisHashStr {
+		t.Errorf("NewHash() got = %s, want %s", hash, genesisHashStr)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("MustParseHash() expected a panic for an invalid hash")
+		}
+	}()
+	bparser.MustParseHash("not a hash")
+}
+
+func TestHashJSON(t *testing.T) {
+	type record struct {
+		Hash bparser.Hash `json:"hash"`
+	}
+
+	b, err := json.Marshal(record{Hash: bparser.MainNet.GenesisHash})
+	if err != nil {
+		t.Fatalf("json.Marshal() returned error\nerror: %v\n", err)
+	} else if want := `{"hash":"` + genesisHashStr + `"}`; string(b) != want {
+		t.Errorf("json.Marshal() got = %s, want %s", b, want)
+	}
+
+	var got record
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal() returned error\nerror: %v\n", err)
+	} else if got.Hash != bparser.MainNet.GenesisHash {
+		t.Errorf("json.Unmarshal() got = %s, want %s", got.Hash, bparser.MainNet.GenesisHash)
+	}
+
+	if err := json.Unmarshal([]byte(`{"hash":"00"}`), &got); err == nil {
+		t.Errorf("json.Unmarshal() expected an error for a short hash")
+	}
+}
@@ -0,0 +1,76 @@
+package bparser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+/*
+HeaderData type is a block header read without decoding the transactions of the block.
+
+FileOffset is the offset of the magic number in the dat file, Size is the size of the block as in BlockData.Size
+and TxCount the number of transactions in the block.
+*/
+type HeaderData struct {
+	FileOffset int64
+	Size       int64
+	TxCount    int64
+	Header     BlockHeaderData
+}
+
+/*
+NextHeader method reads the header and tx count of the next block and skips past its transactions,
+seeking over them when the underlying reader is an io.Seeker.
+
+io.EOF is returned once there are no more blocks. Since the transactions are not read, a block cut short at the end
+of the file may not be detected.
+*/
+func (br *BlockReader) NextHeader() (HeaderData, error) {
+	size, offset, err := br.nextPrefix(blockHeaderSize+1, maxBlockSize)
+	if err != nil {
+		return HeaderData{}, err
+	}
+
+	// header followed by the compact size tx count, which is at most 9 bytes
+	peek, err := br.r.Peek(min(int(size), blockHeaderSize+9))
+	if err != nil {
+		errMsg := fmt.Sprintf("can not read header of block at offset %d in NextHeader() method.\nerror: %v\n", offset, err)
+		return HeaderData{}, errors.New(errMsg)
+	}
+
+	header, err := parseBlockHeader(peek[:blockHeaderSize])
+	if err != nil {
+		return HeaderData{}, err
+	}
+	txCount, _, err := ParseTransactionBlockSize(peek[blockHeaderSize:])
+	if err != nil {
+		errMsg := fmt.Sprintf("can not parse tx count of block at offset %d in NextHeader() method.\nerror: %v\n", offset, err)
+		return HeaderData{}, errors.New(errMsg)
+	}
+
+	if err := br.skip(int64(size)); err != nil {
+		errMsg := fmt.Sprintf("can not skip block of %d bytes at offset %d in NextHeader() method.\nerror: %v\n", size, offset, err)
+		return HeaderData{}, errors.New(errMsg)
+	}
+
+	return HeaderData{FileOffset: offset, Size: int64(size), TxCount: txCount, Header: header}, nil
+}
+
+/*
+ParseHeaders function reads the header of every block of network net from r, in the order they are stored,
+without decoding any transactions. This is much faster than parsing whole blocks when only header fields are needed.
+*/
+func ParseHeaders(r io.Reader, net *Network) ([]HeaderData, error) {
+	br := NewBlockReader(r, net)
+	var headers []HeaderData
+	for {
+		header, err := br.NextHeader()
+		if err == io.EOF {
+			return headers, nil
+		} else if err != nil {
+			return nil, err
+		}
+		headers = append(headers, header)
+	}
+}
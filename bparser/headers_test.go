@@ -0,0 +1,91 @@
+package bparser_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+// onlyReader hides the Seek method of a reader so NextHeader has to read past the txs
+type onlyReader struct {
+	io.Reader
+}
+
+func TestParseHeaders(t *testing.T) {
+	var file []byte
+	for _, blk := range testChain(t) {
+		file = append(file, blk...)
+	}
+	file = append(file, make([]byte, 100)...)
+
+	// headers must match the fully parsed blocks
+	var want []bparser.HeaderData
+	br := bparser.NewBlockReader(bytes.NewReader(file), &bparser.MainNet)
+	for i := 0; ; i++ {
+		block, err := br.NextBlock(i)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("NextBlock() returned error\nerror: %v\n", err)
+		}
+		want = append(want, bparser.HeaderData{FileOffset: block.FileOffset, Size: block.Size, TxCount: block.Tx.TxCount, Header: block.Header})
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "blk00000.dat"), file, 0o644); err != nil {
+		t.Fatalf("can not write blk00000.dat\nerror: %v\n", err)
+	}
+	osFile, err := os.Open(filepath.Join(dir, "blk00000.dat"))
+	if err != nil {
+		t.Fatalf("can not open blk00000.dat\nerror: %v\n", err)
+	}
+	defer osFile.Close()
+
+	tests := []struct {
+		name string
+		r    io.Reader
+	}{
+		{name: "seeker", r: bytes.NewReader(file)},
+		{name: "file", r: osFile},
+		{name: "not a seeker", r: onlyReader{bytes.NewReader(file)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers, err := bparser.ParseHeaders(tt.r, &bparser.MainNet)
+			if err != nil {
+				t.Fatalf("ParseHeaders() returned error\nerror: %v\n", err)
+			} else if len(headers) != len(want) {
+				t.Fatalf("ParseHeaders() got %d headers, want %d", len(headers), len(want))
+			}
+			for i := range want {
+				if headers[i] != want[i] {
+					t.Errorf("ParseHeaders() got header %d = %+v, want %+v", i, headers[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseHeadersErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "truncated header", input: geneisBlockDec[:50]},
+		{name: "truncated block", input: geneisBlockDec[:200]},
+		{name: "wrong magic number", input: append([]byte{1, 2, 3, 4}, geneisBlockDec[4:]...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := bparser.ParseHeaders(onlyReader{bytes.NewReader(tt.input)}, &bparser.MainNet); err == nil {
+				t.Errorf("ParseHeaders() expected an error")
+			}
+		})
+	}
+}
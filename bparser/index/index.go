@@ -0,0 +1,384 @@
+/*
+package index keeps a SQLite database of the main chain blocks, txs and outputs parsed from blk files, so blocks can be found
+by hash or height, txs by txid and outputs by address without scanning the files again.
+*/
+package index
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+// DefaultCommitEvery is the number of blocks Update adds between commits when commitEvery is 0 or less
+const DefaultCommitEvery = 1_000
+
+// ErrNotFound is returned by the lookups of Index when nothing matches.
+var ErrNotFound = errors.New("not found in index")
+
+/*
+schema creates the tables and indexes of a new database. Hashes and txids are 32 byte blobs in display order,
+so lower(hex(txid)) in the sqlite3 shell gives the txid as block explorers show it.
+
+txids are not unique across the chain, so txs and outputs are keyed by height and position in the block.
+*/
+const schema = `
+CREATE TABLE IF NOT EXISTS blocks (
+	height      INTEGER PRIMARY KEY,
+	hash        BLOB    NOT NULL UNIQUE,
+	prev_hash   BLOB    NOT NULL,
+	time        INTEGER NOT NULL,
+	tx_count    INTEGER NOT NULL,
+	size        INTEGER NOT NULL,
+	file_num    INTEGER NOT NULL,
+	file_offset INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS txs (
+	height   INTEGER NOT NULL,
+	tx_index INTEGER NOT NULL,
+	txid     BLOB    NOT NULL,
+	PRIMARY KEY (height, tx_index)
+) WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS txs_txid ON txs (txid);
+
+CREATE TABLE IF NOT EXISTS outputs (
+	height   INTEGER NOT NULL,
+	tx_index INTEGER NOT NULL,
+	vout     INTEGER NOT NULL,
+	value    INTEGER NOT NULL,
+	type     TEXT    NOT NULL,
+	address  TEXT,
+	PRIMARY KEY (height, tx_index, vout)
+) WITHOUT ROWID;
+
+CREATE INDEX IF NOT EXISTS outputs_address ON outputs (address) WHERE address IS NOT NULL;
+`
+
+/*
+Index type is a SQLite database of the main chain, holding a row for every block, tx and output.
+
+Blocks are added in height order, the same as UTXOSet, and are written in a single transaction until Commit is called,
+so the database always holds the chain up to the last commit and opening it again resumes from there. Lookups only see
+committed blocks.
+
+When new blk files appear, build the HeaderChain again and call Update, it rewinds blocks which are no longer on the
+main chain and adds the new ones.
+
+# Example
+
+	ix, _ := Open("index.sqlite")
+	defer ix.Close()
+	chain, _ := bparser.BuildHeaderChain(dir, &bparser.MainNet)
+	ix.Update(ctx, dir, chain, 0, nil)
+	locations, _ := ix.FindTx(txid)
+*/
+type Index struct {
+	db  *sql.DB
+	tip Block
+	// tip as of the last commit, the tip goes back to it when adding a block fails
+	committed Block
+
+	// open transaction of the blocks added since the last commit, nil when there are none
+	tx        *sql.Tx
+	addBlock  *sql.Stmt
+	addTx     *sql.Stmt
+	addOutput *sql.Stmt
+}
+
+/*
+Open function opens the index database at path, creating it when it does not exist.
+*/
+func Open(path string) (*Index, error) {
+	// WAL lets lookups read the last commit while blocks are being added
+	dsn := "file:" + path + "?" + url.Values{"_pragma": {"journal_mode(WAL)", "synchronous(NORMAL)", "busy_timeout(5000)"}}.Encode()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		errMsg := fmt.Sprintf("can not open index at %s in Open() function.\nerror: %v\n", path, err)
+		return nil, errors.New(errMsg)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		errMsg := fmt.Sprintf("can not create tables of index at %s in Open() function.\nerror: %v\n", path, err)
+		return nil, errors.New(errMsg)
+	}
+
+	ix := &Index{db: db, tip: Block{Height: -1}}
+	ix.tip, err = ix.lastBlock()
+	if err == ErrNotFound {
+		ix.tip = Block{Height: -1}
+	} else if err != nil {
+		db.Close()
+		return nil, err
+	}
+	ix.committed = ix.tip
+
+	return ix, nil
+}
+
+/*
+Close method commits the blocks added since the last commit and closes the database.
+*/
+func (ix *Index) Close() error {
+	if err := ix.Commit(); err != nil {
+		ix.db.Close()
+		return err
+	}
+	return ix.db.Close()
+}
+
+/*
+Tip method returns the last added block, Height is -1 for an empty index.
+*/
+func (ix *Index) Tip() Block {
+	return ix.tip
+}
+
+/*
+AddBlock method adds block, stored in blkNNNNN.dat with NNNNN being fileNum, along with its txs and outputs.
+BlockNumber of the block must be its height and the block must be the child of the last added block.
+When an error is returned every block added since the last commit is dropped, and the tip goes back to the last commit.
+*/
+func (ix *Index) AddBlock(block *bparser.BlockData, fileNum int) error {
+	header := block.Header
+	height := block.BlockNumber
+	if height != ix.tip.Height+1 || (height > 0 && header.PrevBlock != ix.tip.Hash) {
+		errMsg := fmt.Sprintf("block %s at height %d is not the child of the tip %s at height %d in AddBlock() method\n", header.BlockHash, height, ix.tip.Hash, ix.tip.Height)
+		return errors.New(errMsg)
+	}
+
+	if err := ix.begin(); err != nil {
+		return err
+	}
+
+	added := Block{
+		Height:     height,
+		Hash:       header.BlockHash,
+		PrevHash:   header.PrevBlock,
+		Time:       header.TimestampUnix,
+		TxCount:    len(block.Tx.Tx),
+		Size:       block.Size,
+		FileNum:    fileNum,
+		FileOffset: block.FileOffset,
+	}
+	_, err := ix.addBlock.Exec(added.Height, hashBytes(added.Hash), hashBytes(added.PrevHash), added.Time, added.TxCount, added.Size, added.FileNum, added.FileOffset)
+	if err != nil {
+		errMsg := fmt.Sprintf("can not add block %s at height %d in AddBlock() method.\nerror: %v\n", header.BlockHash, height, err)
+		ix.rollback()
+		return errors.New(errMsg)
+	}
+
+	for i, tx := range block.Tx.Tx {
+		if _, err := ix.addTx.Exec(height, i, hashBytes(tx.TxId)); err != nil {
+			errMsg := fmt.Sprintf("can not add tx %s of block at height %d in AddBlock() method.\nerror: %v\n", tx.TxId, height, err)
+			ix.rollback()
+			return errors.New(errMsg)
+		}
+		for vout, out := range tx.Outputs {
+			var address any
+			if out.Address != "" {
+				address = out.Address
+			}
+			if _, err := ix.addOutput.Exec(height, i, vout, int64(out.Amount), out.Type.String(), address); err != nil {
+				errMsg := fmt.Sprintf("can not add output %s:%d of block at height %d in AddBlock() method.\nerror: %v\n", tx.TxId, vout, height, err)
+				ix.rollback()
+				return errors.New(errMsg)
+			}
+		}
+	}
+
+	ix.tip = added
+	return nil
+}
+
+/*
+Commit method writes the blocks added since the last commit to the database.
+*/
+func (ix *Index) Commit() error {
+	if ix.tx == nil {
+		return nil
+	}
+
+	err := ix.tx.Commit()
+	ix.tx, ix.addBlock, ix.addTx, ix.addOutput = nil, nil, nil, nil
+	if err != nil {
+		errMsg := fmt.Sprintf("can not commit blocks up to height %d in Commit() method.\nerror: %v\n", ix.tip.Height, err)
+		ix.tip = ix.committed
+		return errors.New(errMsg)
+	}
+	ix.committed = ix.tip
+	return nil
+}
+
+/*
+rollback method drops the blocks added since the last commit.
+*/
+func (ix *Index) rollback() {
+	if ix.tx != nil {
+		ix.tx.Rollback()
+	}
+	ix.tx, ix.addBlock, ix.addTx, ix.addOutput = nil, nil, nil, nil
+	ix.tip = ix.committed
+}
+
+/*
+Rewind method removes the blocks which are no longer on the main chain of chain, after a reorg, and returns the new tip.
+Build must have been called on chain.
+*/
+func (ix *Index) Rewind(chain *bparser.HeaderChain) (Block, error) {
+	if err := ix.Commit(); err != nil {
+		return Block{}, err
+	}
+
+	// walk back from the tip to the last block both agree on, reorgs are rarely more than a few blocks deep
+	height := ix.tip.Height
+	hash := ix.tip.Hash
+	for height >= 0 {
+		if entry, ok := chain.AtHeight(height); ok && entry.Header.BlockHash == hash {
+			break
+		}
+		height--
+		if height >= 0 {
+			block, err := ix.BlockAtHeight(height)
+			if err != nil {
+				return Block{}, err
+			}
+			hash = block.Hash
+		}
+	}
+	if height == ix.tip.Height {
+		return ix.tip, nil
+	}
+
+	tx, err := ix.db.Begin()
+	if err != nil {
+		errMsg := fmt.Sprintf("can not begin rewind to height %d in Rewind() method.\nerror: %v\n", height, err)
+		return Block{}, errors.New(errMsg)
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"outputs", "txs", "blocks"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE height > ?", height); err != nil {
+			errMsg := fmt.Sprintf("can not remove %s above height %d in Rewind() method.\nerror: %v\n", table, height, err)
+			return Block{}, errors.New(errMsg)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		errMsg := fmt.Sprintf("can not commit rewind to height %d in Rewind() method.\nerror: %v\n", height, err)
+		return Block{}, errors.New(errMsg)
+	}
+
+	tip := Block{Height: -1}
+	if height >= 0 {
+		if tip, err = ix.BlockAtHeight(height); err != nil {
+			return Block{}, err
+		}
+	}
+	ix.tip, ix.committed = tip, tip
+	return tip, nil
+}
+
+/*
+Update method brings the index up to the tip of chain. Blocks which are no longer on the main chain are removed with Rewind,
+then every main chain block after the tip of the index is read from dir, in parallel with ParseMainChain, and added.
+
+The added blocks are committed every commitEvery blocks, DefaultCommitEvery when 0 or less, and after the last block.
+fn is called with every added block when it is not nil.
+*/
+func (ix *Index) Update(ctx context.Context, dir *bparser.BlocksDir, chain *bparser.HeaderChain, commitEvery int, fn func(Block) error) (bparser.ParseStats, error) {
+	if commitEvery <= 0 {
+		commitEvery = DefaultCommitEvery
+	}
+	if _, err := ix.Rewind(chain); err != nil {
+		return bparser.ParseStats{}, err
+	}
+
+	stats, err := bparser.ParseMainChain(ctx, dir, chain, ix.tip.Height+1, 0, func(block bparser.BlockData) error {
+		entry, ok := chain.AtHeight(block.BlockNumber)
+		if !ok {
+			errMsg := fmt.Sprintf("block %s at height %d is not in the main chain in Update() method\n", block.Header.BlockHash, block.BlockNumber)
+			return errors.New(errMsg)
+		}
+		if err := ix.AddBlock(&block, entry.FileNum); err != nil {
+			return err
+		}
+		if fn != nil {
+			if err := fn(ix.tip); err != nil {
+				return err
+			}
+		}
+
+		if (block.BlockNumber+1)%commitEvery == 0 {
+			return ix.Commit()
+		}
+		return nil
+	})
+	if err != nil {
+		// keep the blocks added before the error, they were all on the main chain
+		return stats, errors.Join(err, ix.Commit())
+	}
+
+	return stats, ix.Commit()
+}
+
+/*
+begin method opens the transaction the added blocks are written in, if it is not open yet.
+*/
+func (ix *Index) begin() error {
+	if ix.tx != nil {
+		return nil
+	}
+
+	tx, err := ix.db.Begin()
+	if err != nil {
+		errMsg := fmt.Sprintf("can not begin transaction in begin() method.\nerror: %v\n", err)
+		return errors.New(errMsg)
+	}
+
+	statements := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&ix.addBlock, "INSERT INTO blocks (height, hash, prev_hash, time, tx_count, size, file_num, file_offset) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"},
+		{&ix.addTx, "INSERT INTO txs (height, tx_index, txid) VALUES (?, ?, ?)"},
+		{&ix.addOutput, "INSERT INTO outputs (height, tx_index, vout, value, type, address) VALUES (?, ?, ?, ?, ?, ?)"},
+	}
+	for _, s := range statements {
+		if *s.stmt, err = tx.Prepare(s.query); err != nil {
+			tx.Rollback()
+			errMsg := fmt.Sprintf("can not prepare %q in begin() method.\nerror: %v\n", s.query, err)
+			return errors.New(errMsg)
+		}
+	}
+
+	ix.tx = tx
+	return nil
+}
+
+/*
+hashBytes function returns h in display order, the order hashes are stored in.
+*/
+func hashBytes(h bparser.Hash) []byte {
+	b := make([]byte, len(h))
+	for i := range h {
+		b[len(h)-1-i] = h[i]
+	}
+	return b
+}
+
+/*
+parseHashBytes function returns the hash stored as b, see hashBytes.
+*/
+func parseHashBytes(b []byte) (bparser.Hash, error) {
+	h, err := bparser.NewHash(b)
+	if err != nil {
+		return bparser.Hash{}, err
+	}
+	return bparser.Hash(hashBytes(h)), nil
+}
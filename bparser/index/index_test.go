@@ -0,0 +1,182 @@
+package index_test
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+	"github.com/davidhintelmann/blockchain/bparser/index"
+	"github.com/davidhintelmann/blockchain/bparser/internal/testchain"
+)
+
+func TestIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.sqlite")
+	ix, err := index.Open(path)
+	if err != nil {
+		t.Fatalf("Open() returned error\nerror: %v\n", err)
+	} else if tip := ix.Tip(); tip.Height != -1 {
+		t.Fatalf("Open() of a new index got tip height %d, want -1", tip.Height)
+	}
+
+	blocks := testchain.Blocks(t)
+	if err := ix.AddBlock(&blocks[1], 0); err == nil {
+		t.Errorf("AddBlock() of height 1 to an empty index expected an error")
+	}
+	for _, block := range blocks {
+		if err := ix.AddBlock(&block, 0); err != nil {
+			t.Fatalf("AddBlock() returned error\nerror: %v\n", err)
+		}
+	}
+	// lookups only see committed blocks
+	if _, err := ix.BlockAtHeight(0); err != index.ErrNotFound {
+		t.Errorf("BlockAtHeight() before Commit() got error %v, want ErrNotFound", err)
+	}
+	if err := ix.Close(); err != nil {
+		t.Fatalf("Close() returned error\nerror: %v\n", err)
+	}
+
+	// opening again resumes from the committed tip
+	ix, err = index.Open(path)
+	if err != nil {
+		t.Fatalf("Open() returned error\nerror: %v\n", err)
+	}
+	defer ix.Close()
+	if tip := ix.Tip(); tip.Height != 1 || tip.Hash != testchain.SecondHash || tip.PrevHash != testchain.GenesisHash || tip.FileOffset != 293 || tip.TxCount != 2 {
+		t.Errorf("Tip() after Open() got %+v", tip)
+	}
+
+	block, err := ix.BlockByHash(testchain.GenesisHash)
+	if err != nil {
+		t.Errorf("BlockByHash() returned error\nerror: %v\n", err)
+	} else if block.Height != 0 || block.Time != 1231006505 || block.Size != 285 {
+		t.Errorf("BlockByHash() got %+v", block)
+	}
+	if _, err := ix.BlockAtHeight(2); err != index.ErrNotFound {
+		t.Errorf("BlockAtHeight() above the tip got error %v, want ErrNotFound", err)
+	}
+
+	tests := []struct {
+		name    string
+		txid    bparser.Hash
+		blocks  []bparser.Hash
+		heights []int
+		err     error
+	}{
+		{"segwit tx", testchain.SegWitTxId, []bparser.Hash{testchain.SecondHash}, []int{1}, nil},
+		// the second block repeats the genesis coinbase, as blocks 91,842 and 91,880 do
+		{"repeated coinbase", blocks[0].Tx.Tx[0].TxId, []bparser.Hash{testchain.GenesisHash, testchain.SecondHash}, []int{0, 1}, nil},
+		{"unknown tx", bparser.Hash{1}, nil, nil, index.ErrNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			locations, err := ix.FindTx(test.txid)
+			if err != test.err {
+				t.Fatalf("FindTx() got error %v, want %v", err, test.err)
+			} else if len(locations) != len(test.blocks) {
+				t.Fatalf("FindTx() got %d locations, want %d", len(locations), len(test.blocks))
+			}
+			for i, location := range locations {
+				if location.BlockHash != test.blocks[i] || location.Height != test.heights[i] || location.TxId != test.txid {
+					t.Errorf("FindTx() got location %d = %+v, want block %s at height %d", i, location, test.blocks[i], test.heights[i])
+				}
+			}
+		})
+	}
+
+	address := bparser.MainNet.Address(blocks[1].Tx.Tx[1].Outputs[1].ScriptPubKey)
+	outputs, err := ix.AddressOutputs(address)
+	if err != nil {
+		t.Fatalf("AddressOutputs() returned error\nerror: %v\n", err)
+	} else if len(outputs) != 1 {
+		t.Fatalf("AddressOutputs() got %d outputs, want 1", len(outputs))
+	}
+	want := bparser.OutPoint{TxId: testchain.SegWitTxId, Vout: 1}
+	if output := outputs[0]; output.OutPoint != want || output.Value != 223_450_000 || output.Type != "pubkeyhash" || output.Height != 1 {
+		t.Errorf("AddressOutputs() got %+v, want outpoint %s", output, want)
+	}
+}
+
+func TestIndexRewind(t *testing.T) {
+	ix, err := index.Open(filepath.Join(t.TempDir(), "index.sqlite"))
+	if err != nil {
+		t.Fatalf("Open() returned error\nerror: %v\n", err)
+	}
+	defer ix.Close()
+
+	blocks := testchain.Blocks(t)
+	for _, block := range blocks {
+		if err := ix.AddBlock(&block, 0); err != nil {
+			t.Fatalf("AddBlock() returned error\nerror: %v\n", err)
+		}
+	}
+
+	// a chain holding only genesis has lost the block at height 1, as after a reorg
+	chain := bparser.NewHeaderChain(&bparser.MainNet)
+	chain.Add(blocks[0].Header, 0, 0)
+	if err := chain.Build(); err != nil {
+		t.Fatalf("Build() returned error\nerror: %v\n", err)
+	}
+	tip, err := ix.Rewind(chain)
+	if err != nil {
+		t.Fatalf("Rewind() returned error\nerror: %v\n", err)
+	} else if tip.Height != 0 || tip.Hash != testchain.GenesisHash || ix.Tip() != tip {
+		t.Errorf("Rewind() got tip %+v, want genesis", tip)
+	}
+	if _, err := ix.FindTx(testchain.SegWitTxId); err != index.ErrNotFound {
+		t.Errorf("FindTx() of a rewound tx got error %v, want ErrNotFound", err)
+	}
+	if locations, _ := ix.FindTx(blocks[0].Tx.Tx[0].TxId); len(locations) != 1 {
+		t.Errorf("FindTx() of the genesis coinbase after Rewind() got %d locations, want 1", len(locations))
+	}
+
+	// a block which fails to be added leaves the tip at the last commit
+	blocks[1].Header.BlockHash = testchain.GenesisHash
+	if err := ix.AddBlock(&blocks[1], 0); err == nil {
+		t.Errorf("AddBlock() of a block with a duplicate hash expected an error")
+	} else if ix.Tip() != tip {
+		t.Errorf("Tip() after a failed AddBlock() got %+v, want %+v", ix.Tip(), tip)
+	}
+}
+
+func TestIndexUpdate(t *testing.T) {
+	blocksPath := t.TempDir()
+	raw, _ := hex.DecodeString(testchain.GenesisBlockHex)
+	if err := os.WriteFile(filepath.Join(blocksPath, "blk00000.dat"), raw, 0o644); err != nil {
+		t.Fatalf("can not write blk00000.dat\nerror: %v\n", err)
+	}
+	dir, err := bparser.OpenBlocksDir(blocksPath)
+	if err != nil {
+		t.Fatalf("OpenBlocksDir() returned error\nerror: %v\n", err)
+	}
+	chain, err := bparser.BuildHeaderChain(dir, &bparser.MainNet)
+	if err != nil {
+		t.Fatalf("BuildHeaderChain() returned error\nerror: %v\n", err)
+	}
+
+	ix, err := index.Open(filepath.Join(t.TempDir(), "index.sqlite"))
+	if err != nil {
+		t.Fatalf("Open() returned error\nerror: %v\n", err)
+	}
+	defer ix.Close()
+
+	var added []int
+	for range 2 {
+		_, err := ix.Update(context.Background(), dir, chain, 0, func(block index.Block) error {
+			added = append(added, block.Height)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Update() returned error\nerror: %v\n", err)
+		}
+	}
+	// the second update has nothing to add
+	if len(added) != 1 || added[0] != 0 {
+		t.Errorf("Update() added heights %v, want [0]", added)
+	}
+	if block, err := ix.BlockAtHeight(0); err != nil || block.Hash != testchain.GenesisHash || block.FileNum != 0 || block.FileOffset != 0 {
+		t.Errorf("BlockAtHeight() after Update() got %+v with error %v", block, err)
+	}
+}
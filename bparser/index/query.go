@@ -0,0 +1,160 @@
+package index
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+/*
+Block type is a row of the blocks table. Time is the unix timestamp of the header, and FileNum and FileOffset locate the
+block in blkNNNNN.dat, the same as ChainEntry.
+*/
+type Block struct {
+	Height     int
+	Hash       bparser.Hash
+	PrevHash   bparser.Hash
+	Time       int64
+	TxCount    int
+	Size       int64
+	FileNum    int
+	FileOffset int64
+}
+
+/*
+TxLocation type is a block holding a tx, TxIndex is the position of the tx in the block.
+*/
+type TxLocation struct {
+	TxId      bparser.Hash
+	Height    int
+	BlockHash bparser.Hash
+	TxIndex   int
+}
+
+/*
+Output type is a row of the outputs table along with the txid of its tx.
+*/
+type Output struct {
+	OutPoint bparser.OutPoint
+	Height   int
+	Value    bparser.Amount
+	Type     string
+	Address  string
+}
+
+const blockColumns = "height, hash, prev_hash, time, tx_count, size, file_num, file_offset"
+
+/*
+BlockByHash method returns the block with the given hash, or ErrNotFound when it is not on the main chain of the index.
+*/
+func (ix *Index) BlockByHash(hash bparser.Hash) (Block, error) {
+	return ix.queryBlock("SELECT "+blockColumns+" FROM blocks WHERE hash = ?", hashBytes(hash))
+}
+
+/*
+BlockAtHeight method returns the block at height, or ErrNotFound when the index does not reach height.
+*/
+func (ix *Index) BlockAtHeight(height int) (Block, error) {
+	return ix.queryBlock("SELECT "+blockColumns+" FROM blocks WHERE height = ?", height)
+}
+
+/*
+lastBlock method returns the committed block with the greatest height.
+*/
+func (ix *Index) lastBlock() (Block, error) {
+	return ix.queryBlock("SELECT " + blockColumns + " FROM blocks ORDER BY height DESC LIMIT 1")
+}
+
+func (ix *Index) queryBlock(query string, args ...any) (Block, error) {
+	var block Block
+	var hash, prevHash []byte
+	err := ix.db.QueryRow(query, args...).Scan(&block.Height, &hash, &prevHash, &block.Time, &block.TxCount, &block.Size, &block.FileNum, &block.FileOffset)
+	if err == sql.ErrNoRows {
+		return Block{}, ErrNotFound
+	} else if err != nil {
+		errMsg := fmt.Sprintf("can not read block in queryBlock() method.\nerror: %v\n", err)
+		return Block{}, errors.New(errMsg)
+	}
+
+	if block.Hash, err = parseHashBytes(hash); err != nil {
+		return Block{}, err
+	}
+	if block.PrevHash, err = parseHashBytes(prevHash); err != nil {
+		return Block{}, err
+	}
+	return block, nil
+}
+
+/*
+FindTx method returns the blocks holding the tx with txid, ordered by height, or ErrNotFound when no block holds it.
+Almost every txid is in a single block, the exceptions are two coinbase txs which were repeated before BIP 30.
+*/
+func (ix *Index) FindTx(txid bparser.Hash) ([]TxLocation, error) {
+	rows, err := ix.db.Query("SELECT t.height, b.hash, t.tx_index FROM txs t JOIN blocks b ON b.height = t.height WHERE t.txid = ? ORDER BY t.height", hashBytes(txid))
+	if err != nil {
+		errMsg := fmt.Sprintf("can not look up tx %s in FindTx() method.\nerror: %v\n", txid, err)
+		return nil, errors.New(errMsg)
+	}
+	defer rows.Close()
+
+	var locations []TxLocation
+	for rows.Next() {
+		location := TxLocation{TxId: txid}
+		var hash []byte
+		if err := rows.Scan(&location.Height, &hash, &location.TxIndex); err != nil {
+			errMsg := fmt.Sprintf("can not read location of tx %s in FindTx() method.\nerror: %v\n", txid, err)
+			return nil, errors.New(errMsg)
+		}
+		if location.BlockHash, err = parseHashBytes(hash); err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("can not read locations of tx %s in FindTx() method.\nerror: %v\n", txid, err)
+		return nil, errors.New(errMsg)
+	}
+
+	if len(locations) == 0 {
+		return nil, ErrNotFound
+	}
+	return locations, nil
+}
+
+/*
+AddressOutputs method returns every output paying address, spent or not, ordered by height and position in the block.
+*/
+func (ix *Index) AddressOutputs(address string) ([]Output, error) {
+	rows, err := ix.db.Query(`SELECT t.txid, o.vout, o.height, o.value, o.type FROM outputs o
+		JOIN txs t ON t.height = o.height AND t.tx_index = o.tx_index
+		WHERE o.address = ? ORDER BY o.height, o.tx_index, o.vout`, address)
+	if err != nil {
+		errMsg := fmt.Sprintf("can not look up outputs of %s in AddressOutputs() method.\nerror: %v\n", address, err)
+		return nil, errors.New(errMsg)
+	}
+	defer rows.Close()
+
+	var outputs []Output
+	for rows.Next() {
+		output := Output{Address: address}
+		var txid []byte
+		var value int64
+		if err := rows.Scan(&txid, &output.OutPoint.Vout, &output.Height, &value, &output.Type); err != nil {
+			errMsg := fmt.Sprintf("can not read output of %s in AddressOutputs() method.\nerror: %v\n", address, err)
+			return nil, errors.New(errMsg)
+		}
+		if output.OutPoint.TxId, err = parseHashBytes(txid); err != nil {
+			return nil, err
+		}
+		output.Value = bparser.Amount(value)
+		outputs = append(outputs, output)
+	}
+	if err := rows.Err(); err != nil {
+		errMsg := fmt.Sprintf("can not read outputs of %s in AddressOutputs() method.\nerror: %v\n", address, err)
+		return nil, errors.New(errMsg)
+	}
+
+	return outputs, nil
+}
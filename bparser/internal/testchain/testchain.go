@@ -0,0 +1,55 @@
+/*
+package testchain holds block fixtures shared by the tests of the packages built on bparser. It is only imported by tests.
+*/
+package testchain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+// GenesisBlockHex is the genesis block as stored in blk00000.dat, starting with the network magic
+const GenesisBlockHex = "f9beb4d91d0100000100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c0101000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+
+// SegWitTxHex is the native P2WPKH example of BIP 143 with one legacy and one segwit input
+const SegWitTxHex = "01000000000102fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f00000000494830450221008b9d1dc26ba6a9cb62127b02742fa9d754cd3bebf337f7a55d114c8e5cdd30be022040529b194ba3f9281a99f2b1c0a19c0489bc22ede944ccf4ecbab4cc618ef3ed01eeffffffef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a0100000000ffffffff02202cb206000000001976a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac9093510d000000001976a9143bde42dbee7e4dbe6a21b2d50ce2f0167faa815988ac000247304402203609e17b84f6a7d30c80bfa610b5b4542f32a8a0d5447a12fb1366d7f01cc44a0220573a954c4518331561406f90300e8f3358f51928d43c212a8caed02de67eebee0121025476c2e83188368da1ff3e292e7acafcdb3566bb0ad253f62fc70f07aeee635711000000"
+
+var (
+	GenesisHash = bparser.MustParseHash("000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f")
+	SegWitTxId  = bparser.MustParseHash("e8151a2af31c368a35053ddd4bdb285a8595c769a3ad83e0fa02314a602d4609")
+	// hash given to the second block of Blocks, which is not a real block
+	SecondHash = bparser.MustParseHash("00000000000000000000000000000000000000000000000000000000000000aa")
+)
+
+/*
+Blocks function returns the genesis block at height 0 and a block at height 1 holding the genesis coinbase and a segwit tx,
+stored after the genesis block in blk00000.dat. The second block is not valid, only its rows are used.
+*/
+func Blocks(t testing.TB) []bparser.BlockData {
+	t.Helper()
+	raw, _ := hex.DecodeString(GenesisBlockHex)
+	genesis, err := bparser.ParseBlock(raw, 0)
+	if err != nil {
+		t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+	}
+
+	raw, _ = hex.DecodeString(SegWitTxHex)
+	tx, err := bparser.ParseBlockTx(raw, 0)
+	if err != nil {
+		t.Fatalf("ParseBlockTx() returned error\nerror: %v\n", err)
+	}
+	// a tx on its own does not know its network, ParseBlock sets the addresses from the magic number
+	for i := range tx.Outputs {
+		tx.Outputs[i].Address = bparser.MainNet.Address(tx.Outputs[i].ScriptPubKey)
+	}
+	second := genesis
+	second.BlockNumber = 1
+	second.FileOffset = int64(len(GenesisBlockHex) / 2)
+	second.Header.BlockHash = SecondHash
+	second.Header.PrevBlock = GenesisHash
+	second.Tx.Tx = []bparser.TxData{genesis.Tx.Tx[0], tx}
+
+	return []bparser.BlockData{genesis, second}
+}
@@ -0,0 +1,207 @@
+package bparser
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+/*
+BlockJSON type is a block in the shape of bitcoin-core's getblock RPC with verbosity 2, fields are written in the same order
+and with the same names, so tooling reading the RPC output can read blocks parsed from dat files instead.
+
+confirmations is left out since it depends on the tip of the node at the time of the call, and so is the desc of every
+scriptPubKey. MedianTime, ChainWork and NextBlockHash are only set when a HeaderChain is passed to NewBlockJSON, and fee is
+only set on txs whose spent outputs have been attached with ApplyUndo or UTXOSet.ConnectBlock, as bitcoin-core only writes
+it when the undo data of the block is available.
+
+# Example
+
+	block, _ := index.ReadBlock(dir, entry)
+	b, _ := json.Marshal(NewBlockJSON(block, chain))
+*/
+type BlockJSON struct {
+	Hash              Hash        `json:"hash"`
+	Height            int         `json:"height"`
+	Version           int32       `json:"version"`
+	VersionHex        string      `json:"versionHex"`
+	MerkleRoot        Hash        `json:"merkleroot"`
+	Time              int64       `json:"time"`
+	MedianTime        int64       `json:"mediantime,omitempty"`
+	Nonce             uint32      `json:"nonce"`
+	Bits              string      `json:"bits"`
+	Difficulty        json.Number `json:"difficulty"`
+	ChainWork         string      `json:"chainwork,omitempty"`
+	NTx               int         `json:"nTx"`
+	PreviousBlockHash *Hash       `json:"previousblockhash,omitempty"`
+	NextBlockHash     *Hash       `json:"nextblockhash,omitempty"`
+	StrippedSize      int64       `json:"strippedsize"`
+	Size              int64       `json:"size"`
+	Weight            int64       `json:"weight"`
+	Tx                []TxJSON    `json:"tx"`
+}
+
+/*
+TxJSON type is a single tx of BlockJSON, see bitcoin-core's TxToUniv.
+*/
+type TxJSON struct {
+	TxId     Hash         `json:"txid"`
+	Hash     Hash         `json:"hash"`
+	Version  uint32       `json:"version"`
+	Size     int64        `json:"size"`
+	VSize    int64        `json:"vsize"`
+	Weight   int64        `json:"weight"`
+	Locktime uint32       `json:"locktime"`
+	Vin      []VinJSON    `json:"vin"`
+	Vout     []VoutJSON   `json:"vout"`
+	Fee      *amountValue `json:"fee,omitempty"`
+	Hex      string       `json:"hex"`
+}
+
+/*
+VinJSON type is a single input of TxJSON, the input of a coinbase tx only has Coinbase, TxInWitness and Sequence set.
+*/
+type VinJSON struct {
+	Coinbase    string         `json:"coinbase,omitempty"`
+	TxId        *Hash          `json:"txid,omitempty"`
+	Vout        *uint32        `json:"vout,omitempty"`
+	ScriptSig   *ScriptSigJSON `json:"scriptSig,omitempty"`
+	TxInWitness []string       `json:"txinwitness,omitempty"`
+	Sequence    uint32         `json:"sequence"`
+}
+
+/*
+ScriptSigJSON type is the scriptSig of VinJSON, with signature sighash types decoded in Asm as bitcoin-core does.
+*/
+type ScriptSigJSON struct {
+	Asm string `json:"asm"`
+	Hex string `json:"hex"`
+}
+
+/*
+VoutJSON type is a single output of TxJSON, Value is written in bitcoin as a number with 8 decimals.
+*/
+type VoutJSON struct {
+	Value        amountValue      `json:"value"`
+	N            int              `json:"n"`
+	ScriptPubKey ScriptPubKeyJSON `json:"scriptPubKey"`
+}
+
+/*
+ScriptPubKeyJSON type is the scriptPubKey of VoutJSON, Address is left out for outputs without an address.
+*/
+type ScriptPubKeyJSON struct {
+	Asm     string `json:"asm"`
+	Hex     string `json:"hex"`
+	Address string `json:"address,omitempty"`
+	Type    string `json:"type"`
+}
+
+// amountValue writes an Amount as a JSON number in bitcoin, the same as bitcoin-core's ValueFromAmount
+type amountValue Amount
+
+func (a amountValue) MarshalJSON() ([]byte, error) {
+	return []byte(Amount(a).FormatBTC()), nil
+}
+
+/*
+NewBlockJSON function returns block in the shape of getblock with verbosity 2. chain may be nil, otherwise the height,
+median time past, chain work and next main chain block are taken from it, see BlockJSON.
+*/
+func NewBlockJSON(block BlockData, chain *HeaderChain) BlockJSON {
+	header := block.Header
+	blockJSON := BlockJSON{
+		Hash:         header.BlockHash,
+		Height:       block.BlockNumber,
+		Version:      int32(header.Version),
+		VersionHex:   fmt.Sprintf("%08x", uint32(header.Version)),
+		MerkleRoot:   header.MerkleRoot,
+		Time:         header.TimestampUnix,
+		Nonce:        uint32(header.Nonce),
+		Bits:         fmt.Sprintf("%08x", header.Bits),
+		Difficulty:   json.Number(strconv.FormatFloat(header.Difficulty, 'g', 16, 64)),
+		NTx:          len(block.Tx.Tx),
+		StrippedSize: block.StrippedSize,
+		Size:         block.Size,
+		Weight:       block.Weight,
+		Tx:           make([]TxJSON, 0, len(block.Tx.Tx)),
+	}
+	if !header.PrevBlock.IsZero() {
+		prevBlock := header.PrevBlock
+		blockJSON.PreviousBlockHash = &prevBlock
+	}
+
+	if chain != nil {
+		if entry, ok := chain.Get(header.BlockHash); ok && entry.Height >= 0 {
+			blockJSON.Height = entry.Height
+			blockJSON.MedianTime = chain.MedianTimePast(entry)
+			blockJSON.ChainWork = fmt.Sprintf("%064x", entry.ChainWork)
+			if next, ok := chain.AtHeight(entry.Height + 1); ok && entry.Status == StatusMainChain {
+				nextBlock := next.Header.BlockHash
+				blockJSON.NextBlockHash = &nextBlock
+			}
+		}
+	}
+
+	for _, tx := range block.Tx.Tx {
+		blockJSON.Tx = append(blockJSON.Tx, NewTxJSON(tx))
+	}
+
+	return blockJSON
+}
+
+/*
+NewTxJSON function returns tx in the shape of a tx of getblock with verbosity 2, see TxJSON.
+*/
+func NewTxJSON(tx TxData) TxJSON {
+	txJSON := TxJSON{
+		TxId:     tx.TxId,
+		Hash:     tx.WTxId,
+		Version:  uint32(tx.Version),
+		Size:     tx.Size,
+		VSize:    tx.VSize,
+		Weight:   tx.Weight,
+		Locktime: tx.LocktimeNumber(),
+		Vin:      make([]VinJSON, 0, len(tx.Inputs)),
+		Vout:     make([]VoutJSON, 0, len(tx.Outputs)),
+		Hex:      hex.EncodeToString(tx.Raw),
+	}
+
+	coinbase := tx.IsCoinbase()
+	for _, in := range tx.Inputs {
+		vin := VinJSON{Sequence: in.Sequence}
+		if coinbase {
+			vin.Coinbase = hex.EncodeToString(in.ScriptSig)
+		} else {
+			txId, vout := in.TxId, in.Vout
+			vin.TxId = &txId
+			vin.Vout = &vout
+			vin.ScriptSig = &ScriptSigJSON{Asm: in.ScriptSigASM(), Hex: hex.EncodeToString(in.ScriptSig)}
+		}
+		for _, item := range in.Witness {
+			vin.TxInWitness = append(vin.TxInWitness, hex.EncodeToString(item))
+		}
+		txJSON.Vin = append(txJSON.Vin, vin)
+	}
+
+	for n, out := range tx.Outputs {
+		txJSON.Vout = append(txJSON.Vout, VoutJSON{
+			Value: amountValue(out.Amount),
+			N:     n,
+			ScriptPubKey: ScriptPubKeyJSON{
+				Asm:     out.ScriptPubKeyASM(),
+				Hex:     hex.EncodeToString(out.ScriptPubKey),
+				Address: out.Address,
+				Type:    out.Type.String(),
+			},
+		})
+	}
+
+	if tx.FeeKnown() {
+		fee := amountValue(tx.Fee)
+		txJSON.Fee = &fee
+	}
+
+	return txJSON
+}
@@ -0,0 +1,144 @@
+package bparser_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+// genesisGetBlock is the output of bitcoin-cli getblock for the genesis block with verbosity 2, without confirmations,
+// nextblockhash and desc
+const genesisGetBlock = `{
+  "hash": "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+  "height": 0,
+  "version": 1,
+  "versionHex": "00000001",
+  "merkleroot": "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+  "time": 1231006505,
+  "mediantime": 1231006505,
+  "nonce": 2083236893,
+  "bits": "1d00ffff",
+  "difficulty": 1,
+  "chainwork": "0000000000000000000000000000000000000000000000000000000100010001",
+  "nTx": 1,
+  "strippedsize": 285,
+  "size": 285,
+  "weight": 1140,
+  "tx": [
+    {
+      "txid": "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+      "hash": "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+      "version": 1,
+      "size": 204,
+      "vsize": 204,
+      "weight": 816,
+      "locktime": 0,
+      "vin": [
+        {
+          "coinbase": "04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73",
+          "sequence": 4294967295
+        }
+      ],
+      "vout": [
+        {
+          "value": 50.00000000,
+          "n": 0,
+          "scriptPubKey": {
+            "asm": "04678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5f OP_CHECKSIG",
+            "hex": "4104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac",
+            "type": "pubkey"
+          }
+        }
+      ],
+      "hex": "01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+    }
+  ]
+}`
+
+func TestGenesisBlockJSON(t *testing.T) {
+	block, err := bparser.ParseBlock(geneisBlockDec, 0)
+	if err != nil {
+		t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+	}
+
+	chain := bparser.NewHeaderChain(&bparser.MainNet)
+	chain.Add(block.Header, 0, 0)
+	if err := chain.Build(); err != nil {
+		t.Fatalf("Build() returned error\nerror: %v\n", err)
+	}
+
+	got, err := json.MarshalIndent(bparser.NewBlockJSON(block, chain), "", "  ")
+	if err != nil {
+		t.Fatalf("json.MarshalIndent() returned error\nerror: %v\n", err)
+	} else if string(got) != genesisGetBlock {
+		t.Errorf("NewBlockJSON() got\n%s\nwant\n%s", got, genesisGetBlock)
+	}
+
+	// without a chain the fields which need the other blocks are left out
+	got, _ = json.Marshal(bparser.NewBlockJSON(block, nil))
+	for _, field := range []string{"mediantime", "chainwork", "nextblockhash", "previousblockhash", "fee"} {
+		if bytes.Contains(got, []byte(`"`+field+`"`)) {
+			t.Errorf("NewBlockJSON() without a chain got field %s", field)
+		}
+	}
+}
+
+func TestSegWitTxJSON(t *testing.T) {
+	raw, err := hex.DecodeString(segWitTxHex)
+	if err != nil {
+		t.Fatalf("can not decode segwit tx hex\nerror: %v\n", err)
+	}
+	tx, err := bparser.ParseBlockTx(raw, 0)
+	if err != nil {
+		t.Fatalf("ParseBlockTx() returned error\nerror: %v\n", err)
+	}
+
+	txJSON := bparser.NewTxJSON(tx)
+	if txJSON.Hex != segWitTxHex {
+		t.Errorf("NewTxJSON() got hex = %s, want %s", txJSON.Hex, segWitTxHex)
+	} else if txJSON.Locktime != 17 || txJSON.Version != 1 || txJSON.Fee != nil {
+		t.Errorf("NewTxJSON() got locktime = %d, version = %d, fee = %v", txJSON.Locktime, txJSON.Version, txJSON.Fee)
+	}
+
+	tests := []struct {
+		txId     string
+		vout     uint32
+		sequence uint32
+		witness  int
+	}{
+		{"9f96ade4b41d5433f4eda31e1738ec2b36f6e7d1420d94a6af99801a88f7f7ff", 0, 4294967278, 0},
+		{"8ac60eb9575db5b2d987e29f301b5b819ea83a5c6579d282d189cc04b8e151ef", 1, 4294967295, 2},
+	}
+	if len(txJSON.Vin) != len(tests) {
+		t.Fatalf("NewTxJSON() got %d inputs, want %d", len(txJSON.Vin), len(tests))
+	}
+	for i, test := range tests {
+		vin := txJSON.Vin[i]
+		if vin.Coinbase != "" || vin.TxId == nil || vin.TxId.String() != test.txId || *vin.Vout != test.vout {
+			t.Errorf("NewTxJSON() got input %d = %+v, want txid %s vout %d", i, vin, test.txId, test.vout)
+		} else if vin.Sequence != test.sequence || len(vin.TxInWitness) != test.witness {
+			t.Errorf("NewTxJSON() got input %d sequence = %d with %d witness items, want %d with %d", i, vin.Sequence, len(vin.TxInWitness), test.sequence, test.witness)
+		}
+	}
+	if asm := txJSON.Vin[0].ScriptSig.Asm; !bytes.HasSuffix([]byte(asm), []byte("[ALL]")) {
+		t.Errorf("NewTxJSON() got scriptSig asm = %s, want a signature with a decoded sighash type", asm)
+	}
+
+	// fee is only written once every spent output is known
+	for i := range tx.Inputs {
+		tx.Inputs[i].PrevOut = &bparser.Coin{}
+	}
+	tx.Fee = 1_000
+	if got, _ := json.Marshal(bparser.NewTxJSON(tx)); !bytes.Contains(got, []byte(`"fee":0.00001000,"hex"`)) {
+		t.Errorf("NewTxJSON() with spent outputs got %s, want a fee of 0.00001000", got)
+	}
+
+	vout, _ := json.Marshal(txJSON.Vout[0])
+	want := `{"value":1.12340000,"n":0,"scriptPubKey":{"asm":"OP_DUP OP_HASH160 8280b37df378db99f66f85c95a783a76ac7a6d59 OP_EQUALVERIFY OP_CHECKSIG","hex":"76a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac","type":"pubkeyhash"}}`
+	if string(vout) != want {
+		t.Errorf("json.Marshal() of vout got = %s, want %s", vout, want)
+	}
+}
@@ -0,0 +1,157 @@
+package bparser
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// witnessCommitmentHeader is OP_RETURN, a 36 byte push and the 0xaa21a9ed tag which start a witness commitment output, see BIP141
+var witnessCommitmentHeader = []byte{0x6a, 0x24, 0xaa, 0x21, 0xa9, 0xed}
+
+/*
+MerkleError type is returned when the txs of a block do not match its header, which means the blk file is corrupt.
+
+Witness is false when the merkle root of the txids does not match BlockHeaderData.MerkleRoot, and true when the
+witness commitment in the coinbase tx does not match the wtxids. Want is zero when a block has witness data
+but no witness commitment, and Got is zero when the coinbase has no witness reserved value.
+
+Mutated is true when the merkle root matches but the txs contain a duplicate which leaves the root unchanged,
+see CVE-2012-2459.
+*/
+type MerkleError struct {
+	BlockHash Hash
+	Witness   bool
+	Mutated   bool
+	Want      Hash
+	Got       Hash
+}
+
+func (e *MerkleError) Error() string {
+	if e.Mutated {
+		return fmt.Sprintf("block %s has merkle root %s but its txs are mutated with duplicate txids", e.BlockHash, e.Want)
+	} else if e.Witness && e.Want.IsZero() {
+		return fmt.Sprintf("block %s has witness data but no witness commitment", e.BlockHash)
+	} else if e.Witness && e.Got.IsZero() {
+		return fmt.Sprintf("block %s has witness commitment %s but no witness reserved value", e.BlockHash, e.Want)
+	} else if e.Witness {
+		return fmt.Sprintf("block %s has witness commitment %s but its wtxids commit to %s", e.BlockHash, e.Want, e.Got)
+	}
+	return fmt.Sprintf("block %s has merkle root %s but its txids hash to %s", e.BlockHash, e.Want, e.Got)
+}
+
+/*
+MerkleRoot function returns the merkle root of hashes, or a zero hash when there are no hashes.
+
+Each level of the tree hashes pairs of hashes with double sha256, duplicating the last hash when a level has an odd number of hashes.
+*/
+func MerkleRoot(hashes []Hash) Hash {
+	root, _ := computeMerkleRoot(hashes)
+	return root
+}
+
+/*
+computeMerkleRoot function returns the merkle root of hashes like MerkleRoot, and whether the hashes are mutated.
+
+Since the last hash of a level with an odd number of hashes is duplicated, a list of txs ending in a duplicate
+has the same merkle root as the list without it. The hashes are mutated when two hashes being paired are equal,
+which is how bitcoin-core detects this, see CVE-2012-2459.
+*/
+func computeMerkleRoot(hashes []Hash) (Hash, bool) {
+	if len(hashes) == 0 {
+		return Hash{}, false
+	}
+
+	mutated := false
+	level := make([]Hash, len(hashes))
+	copy(level, hashes)
+	for len(level) > 1 {
+		for i := 0; i+1 < len(level); i += 2 {
+			if level[i] == level[i+1] {
+				mutated = true
+			}
+		}
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		next := level[:0]
+		for i := 0; i < len(level); i += 2 {
+			next = append(next, doubleSha256(level[i][:], level[i+1][:]))
+		}
+		level = next
+	}
+
+	return level[0], mutated
+}
+
+/*
+VerifyMerkleRoot method computes the merkle root of the txids of the block and compares it with the header,
+a *MerkleError is returned when they do not match or when the txids are mutated with a duplicate.
+*/
+func (b BlockData) VerifyMerkleRoot() error {
+	txIds := make([]Hash, 0, len(b.Tx.Tx))
+	for _, tx := range b.Tx.Tx {
+		txIds = append(txIds, tx.TxId)
+	}
+
+	got, mutated := computeMerkleRoot(txIds)
+	if got != b.Header.MerkleRoot {
+		return &MerkleError{BlockHash: b.Header.BlockHash, Want: b.Header.MerkleRoot, Got: got}
+	} else if mutated {
+		return &MerkleError{BlockHash: b.Header.BlockHash, Mutated: true, Want: b.Header.MerkleRoot, Got: got}
+	}
+
+	return nil
+}
+
+/*
+VerifyWitnessCommitment method checks the witness commitment of a segwit block, a *MerkleError is returned when it does not match.
+
+The commitment is the last output of the coinbase tx starting with witnessCommitmentHeader, followed by the double sha256
+of the merkle root of the wtxids, with the coinbase wtxid replaced by zeros, and the 32 byte witness reserved value
+found in the coinbase witness. Blocks without a commitment must not have any witness data.
+
+The commitment is only a consensus rule from Network.SegWitHeight, so ParseBlock does not check it,
+ParseMainChain checks it for the blocks at or above that height.
+*/
+func (b BlockData) VerifyWitnessCommitment() error {
+	if len(b.Tx.Tx) == 0 {
+		return nil
+	}
+
+	coinbase := b.Tx.Tx[0]
+	var commitment []byte
+	for _, output := range coinbase.Outputs {
+		if len(output.ScriptPubKey) >= 38 && bytes.HasPrefix(output.ScriptPubKey, witnessCommitmentHeader) {
+			commitment = output.ScriptPubKey[6:38]
+		}
+	}
+
+	if commitment == nil {
+		for _, tx := range b.Tx.Tx {
+			if tx.SegWit {
+				return &MerkleError{BlockHash: b.Header.BlockHash, Witness: true}
+			}
+		}
+		return nil
+	}
+
+	want := Hash(commitment)
+	if len(coinbase.Inputs) != 1 || len(coinbase.Inputs[0].Witness) != 1 || len(coinbase.Inputs[0].Witness[0]) != 32 {
+		// without a witness reserved value the commitment can not be computed
+		return &MerkleError{BlockHash: b.Header.BlockHash, Witness: true, Want: want}
+	}
+
+	wTxIds := make([]Hash, 0, len(b.Tx.Tx))
+	wTxIds = append(wTxIds, Hash{})
+	for _, tx := range b.Tx.Tx[1:] {
+		wTxIds = append(wTxIds, tx.WTxId)
+	}
+
+	root := MerkleRoot(wTxIds)
+	got := doubleSha256(root[:], coinbase.Inputs[0].Witness[0])
+	if got != want {
+		return &MerkleError{BlockHash: b.Header.BlockHash, Witness: true, Want: want, Got: got}
+	}
+
+	return nil
+}
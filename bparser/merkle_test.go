@@ -0,0 +1,165 @@
+package bparser_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+func doubleSha256(b []byte) []byte {
+	first := sha256.Sum256(b)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+/*
+test MerkleRoot function with the 4 txs of block height 100,000 and with an odd number of hashes
+*/
+func TestMerkleRoot(t *testing.T) {
+	txIds := []bparser.Hash{
+		bparser.MustParseHash("8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87"),
+		bparser.MustParseHash("fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4"),
+		bparser.MustParseHash("6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4"),
+		bparser.MustParseHash("e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d"),
+	}
+	want := bparser.MustParseHash("f3e94742aca4b5ef85488dc37c06c3282295ffec960994b2c0d5ac2a25a95766")
+	if got := bparser.MerkleRoot(txIds); got != want {
+		t.Errorf("MerkleRoot() got = %s, want %s", got, want)
+	}
+
+	// the last hash is duplicated on levels with an odd number of hashes
+	odd := bparser.MerkleRoot(txIds[:3])
+	if dup := bparser.MerkleRoot(append(txIds[:3:3], txIds[2])); odd != dup {
+		t.Errorf("MerkleRoot() of 3 hashes got = %s, want %s", odd, dup)
+	}
+
+	if got := bparser.MerkleRoot(txIds[:1]); got != txIds[0] {
+		t.Errorf("MerkleRoot() of a single hash got = %s, want %s", got, txIds[0])
+	}
+	if got := bparser.MerkleRoot(nil); !got.IsZero() {
+		t.Errorf("MerkleRoot() of no hashes got = %s, want a zero hash", got)
+	}
+}
+
+func TestParseBlockMerkleError(t *testing.T) {
+	// change the coinbase message so the txid no longer matches the merkle root
+	blk := bytes.Clone(geneisBlockDec)
+	blk[150] ^= 1
+
+	block, err := bparser.ParseBlock(blk, 0)
+	var merkleErr *bparser.MerkleError
+	if !errors.As(err, &merkleErr) {
+		t.Fatalf("ParseBlock() expected a *MerkleError but got %v", err)
+	} else if merkleErr.Witness || merkleErr.Want != block.Header.MerkleRoot || merkleErr.Got != block.Tx.Tx[0].TxId {
+		t.Errorf("ParseBlock() got %+v", merkleErr)
+	}
+
+	// the block reader returns the same error unwrapped
+	br := bparser.NewBlockReader(bytes.NewReader(blk), &bparser.MainNet)
+	if _, err := br.NextBlock(0); !errors.As(err, &merkleErr) {
+		t.Errorf("NextBlock() expected a *MerkleError but got %v", err)
+	}
+}
+
+/*
+test VerifyMerkleRoot method with a block whose txs end in a duplicate, which has the merkle root of the block without it
+*/
+func TestVerifyMerkleRootMutated(t *testing.T) {
+	coinbase := testTx(bparser.Hash{}, 0, 1, testOutput(5_000_000_000, p2pkhScript))
+	spend := testTx(bparser.Hash{1}, 0, 0, testOutput(1_000, p2pkhScript))
+	dup := testTx(bparser.Hash{2}, 0, 0, testOutput(2_000, p2pkhScript))
+
+	block, err := bparser.ParseBlock(buildBlock(geneisBlockDec[8:88], coinbase, spend, dup), 0)
+	if err != nil {
+		t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+	}
+
+	mutated, err := bparser.ParseBlock(buildBlock(geneisBlockDec[8:88], coinbase, spend, dup, dup), 0)
+	var merkleErr *bparser.MerkleError
+	if !errors.As(err, &merkleErr) {
+		t.Fatalf("ParseBlock() expected a *MerkleError but got %v", err)
+	} else if !merkleErr.Mutated || merkleErr.Witness || merkleErr.Got != block.Header.MerkleRoot {
+		t.Errorf("ParseBlock() got %+v", merkleErr)
+	} else if mutated.Header.MerkleRoot != block.Header.MerkleRoot {
+		t.Errorf("ParseBlock() got merkle root %s for the mutated block, want %s", mutated.Header.MerkleRoot, block.Header.MerkleRoot)
+	}
+}
+
+/*
+segWitBlock function returns a block with a segwit coinbase and the BIP143 segwit tx, the coinbase commits to commitment.
+A nil commitment leaves out the witness commitment output.
+*/
+func segWitBlock(commitment []byte) []byte {
+	segWitTx, _ := hex.DecodeString(segWitTxHex)
+	return buildBlock(geneisBlockDec[8:88], segWitCoinbase(commitment), segWitTx)
+}
+
+/*
+segWitCoinbase function returns a segwit coinbase paying 50 BTC which commits to commitment, see segWitBlock.
+*/
+func segWitCoinbase(commitment []byte) []byte {
+	coinbase := binary.LittleEndian.AppendUint32(nil, 1)
+	coinbase = append(coinbase, 0, 1, 1)
+	coinbase = append(coinbase, make([]byte, 32)...)
+	coinbase = append(coinbase, 0xff, 0xff, 0xff, 0xff, 2, 0x51, 0x51, 0xff, 0xff, 0xff, 0xff)
+	if commitment == nil {
+		coinbase = append(coinbase, 1)
+	} else {
+		coinbase = append(coinbase, 2)
+		coinbase = append(coinbase, testOutput(0, append([]byte{0x6a, 0x24, 0xaa, 0x21, 0xa9, 0xed}, commitment...))...)
+	}
+	coinbase = append(coinbase, testOutput(5_000_000_000, p2pkhScript)...)
+	// witness reserved value
+	coinbase = append(coinbase, 1, 32)
+	coinbase = append(coinbase, make([]byte, 32)...)
+	return binary.LittleEndian.AppendUint32(coinbase, 0)
+}
+
+func TestVerifyWitnessCommitment(t *testing.T) {
+	// the coinbase wtxid is replaced by zeros
+	wTxIds := []bparser.Hash{{}, bparser.MustParseHash("c36c38370907df2324d9ce9d149d191192f338b37665a82e78e76a12c909b762")}
+	root := bparser.MerkleRoot(wTxIds)
+	commitment := doubleSha256(append(root[:], make([]byte, 32)...))
+
+	block, err := bparser.ParseBlock(segWitBlock(commitment), 0)
+	if err != nil {
+		t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+	} else if !block.Tx.Tx[0].SegWit || !block.Tx.Tx[1].SegWit {
+		t.Fatalf("ParseBlock() expected both txs to be segwit")
+	} else if err := block.VerifyWitnessCommitment(); err != nil {
+		t.Fatalf("VerifyWitnessCommitment() returned error\nerror: %v\n", err)
+	}
+
+	tests := []struct {
+		name       string
+		commitment []byte
+		wantWant   bool
+		wantGot    bool
+	}{
+		{name: "wrong commitment", commitment: bytes.Repeat([]byte{1}, 32), wantWant: true, wantGot: true},
+		{name: "no commitment", commitment: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the commitment depends on the height, so ParseBlock does not check it
+			block, err := bparser.ParseBlock(segWitBlock(tt.commitment), 0)
+			if err != nil {
+				t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+			}
+
+			err = block.VerifyWitnessCommitment()
+			var merkleErr *bparser.MerkleError
+			if !errors.As(err, &merkleErr) {
+				t.Fatalf("VerifyWitnessCommitment() expected a *MerkleError but got %v", err)
+			} else if !merkleErr.Witness || merkleErr.Want.IsZero() == tt.wantWant || merkleErr.Got.IsZero() == tt.wantGot {
+				t.Errorf("VerifyWitnessCommitment() got %+v", merkleErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package bparser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+Network type describes a bitcoin network; the magic number written before every block in its dat files, the genesis block,
+where bitcoin-core keeps its data, the address prefixes and the consensus parameters used to validate its blocks.
+
+DataDirSubfolder is the folder within the bitcoin-core data directory for this network, empty for mainnet.
+*/
+type Network struct {
+	Name             string
+	Magic            [4]byte
+	GenesisHash      Hash
+	DataDirSubfolder string
+
+	// address prefixes
+	PubKeyHashAddrID byte
+	ScriptHashAddrID byte
+	Bech32HRP        string
+
+	// consensus parameters
+	PowLimitBits                uint32
+	PowTargetTimespan           int64
+	PowTargetSpacing            int64
+	PowAllowMinDifficultyBlocks bool
+	PowNoRetargeting            bool
+	EnforceBIP94                bool
+	SubsidyHalvingInterval      int
+	SegWitHeight                int
+}
+
+var (
+	MainNet = Network{
+		Name:                   "main",
+		Magic:                  [4]byte{0xf9, 0xbe, 0xb4, 0xd9},
+		GenesisHash:            MustParseHash("000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"),
+		DataDirSubfolder:       "",
+		PubKeyHashAddrID:       0x00,
+		ScriptHashAddrID:       0x05,
+		Bech32HRP:              "bc",
+		PowLimitBits:           0x1d00ffff,
+		PowTargetTimespan:      14 * 24 * 60 * 60,
+		PowTargetSpacing:       10 * 60,
+		SubsidyHalvingInterval: 210_000,
+		SegWitHeight:           481_824,
+	}
+
+	TestNet3 = Network{
+		Name:                        "testnet3",
+		Magic:                       [4]byte{0x0b, 0x11, 0x09, 0x07},
+		GenesisHash:                 MustParseHash("000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943"),
+		DataDirSubfolder:            "testnet3",
+		PubKeyHashAddrID:            0x6f,
+		ScriptHashAddrID:            0xc4,
+		Bech32HRP:                   "tb",
+		PowLimitBits:                0x1d00ffff,
+		PowTargetTimespan:           14 * 24 * 60 * 60,
+		PowTargetSpacing:            10 * 60,
+		PowAllowMinDifficultyBlocks: true,
+		SubsidyHalvingInterval:      210_000,
+		SegWitHeight:                834_624,
+	}
+
+	TestNet4 = Network{
+		Name:                        "testnet4",
+		Magic:                       [4]byte{0x1c, 0x16, 0x3f, 0x28},
+		GenesisHash:                 MustParseHash("00000000da84f2bafbbc53dee25a72ae507ff4914b867c565be350b0da8bf043"),
+		DataDirSubfolder:            "testnet4",
+		PubKeyHashAddrID:            0x6f,
+		ScriptHashAddrID:            0xc4,
+		Bech32HRP:                   "tb",
+		PowLimitBits:                0x1d00ffff,
+		PowTargetTimespan:           14 * 24 * 60 * 60,
+		PowTargetSpacing:            10 * 60,
+		PowAllowMinDifficultyBlocks: true,
+		EnforceBIP94:                true,
+		SubsidyHalvingInterval:      210_000,
+		SegWitHeight:                1,
+	}
+
+	SigNet = Network{
+		Name:                   "signet",
+		Magic:                  [4]byte{0x0a, 0x03, 0xcf, 0x40},
+		GenesisHash:            MustParseHash("00000008819873e925422c1ff0f99f7cc9bbb232af63a077a480a3633bee1ef6"),
+		DataDirSubfolder:       "signet",
+		PubKeyHashAddrID:       0x6f,
+		ScriptHashAddrID:       0xc4,
+		Bech32HRP:              "tb",
+		PowLimitBits:           0x1e0377ae,
+		PowTargetTimespan:      14 * 24 * 60 * 60,
+		PowTargetSpacing:       10 * 60,
+		SubsidyHalvingInterval: 210_000,
+		SegWitHeight:           1,
+	}
+
+	RegTest = Network{
+		Name:                        "regtest",
+		Magic:                       [4]byte{0xfa, 0xbf, 0xb5, 0xda},
+		GenesisHash:                 MustParseHash("0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206"),
+		DataDirSubfolder:            "regtest",
+		PubKeyHashAddrID:            0x6f,
+		ScriptHashAddrID:            0xc4,
+		Bech32HRP:                   "bcrt",
+		PowLimitBits:                0x207fffff,
+		PowTargetTimespan:           14 * 24 * 60 * 60,
+		PowTargetSpacing:            10 * 60,
+		PowAllowMinDifficultyBlocks: true,
+		PowNoRetargeting:            true,
+		SubsidyHalvingInterval:      150,
+		SegWitHeight:                0,
+	}
+
+	// Networks is every network known to bparser
+	Networks = []*Network{&MainNet, &TestNet3, &TestNet4, &SigNet, &RegTest}
+)
+
+/*
+RetargetInterval method returns the number of blocks between difficulty adjustments, 2016 for all networks.
+*/
+func (n *Network) RetargetInterval() int {
+	return int(n.PowTargetTimespan / n.PowTargetSpacing)
+}
+
+/*
+NetworkByName function returns the network with the given name. Names used by bitcoin-core's -chain option are accepted,
+as well as "mainnet" and "testnet".
+*/
+func NetworkByName(name string) (*Network, error) {
+	switch strings.ToLower(name) {
+	case "main", "mainnet", "bitcoin":
+		return &MainNet, nil
+	case "test", "testnet", "testnet3":
+		return &TestNet3, nil
+	case "testnet4":
+		return &TestNet4, nil
+	case "signet":
+		return &SigNet, nil
+	case "regtest":
+		return &RegTest, nil
+	}
+
+	errMsg := fmt.Sprintf("unknown network %q in NetworkByName() function, expected one of main, testnet3, testnet4, signet or regtest\n", name)
+	return nil, errors.New(errMsg)
+}
+
+/*
+NetworkByMagic function returns the network whose dat files use the given magic number.
+*/
+func NetworkByMagic(magic []byte) (*Network, error) {
+	for _, n := range Networks {
+		if bytes.Equal(n.Magic[:], magic) {
+			return n, nil
+		}
+	}
+
+	errMsg := fmt.Sprintf("unknown magic number %X in NetworkByMagic() function\n", magic)
+	return nil, errors.New(errMsg)
+}
@@ -0,0 +1,60 @@
+package bparser_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+func TestNetworkByName(t *testing.T) {
+	tests := []struct {
+		name  string
+		want  *bparser.Network
+		magic string
+	}{
+		{name: "main", want: &bparser.MainNet, magic: "F9BEB4D9"},
+		{name: "mainnet", want: &bparser.MainNet, magic: "F9BEB4D9"},
+		{name: "testnet", want: &bparser.TestNet3, magic: "0B110907"},
+		{name: "testnet4", want: &bparser.TestNet4, magic: "1C163F28"},
+		{name: "signet", want: &bparser.SigNet, magic: "0A03CF40"},
+		{name: "RegTest", want: &bparser.RegTest, magic: "FABFB5DA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bparser.NetworkByName(tt.name)
+			if err != nil {
+				t.Fatalf("NetworkByName() returned error\nerror: %v\n", err)
+			} else if got != tt.want {
+				t.Errorf("NetworkByName(%q) got network %s, want network %s", tt.name, got.Name, tt.want.Name)
+			}
+
+			byMagic, err := bparser.NetworkByMagic(got.Magic[:])
+			if err != nil {
+				t.Errorf("NetworkByMagic() returned error\nerror: %v\n", err)
+			} else if byMagic != tt.want {
+				t.Errorf("NetworkByMagic(%X) got network %s, want network %s", got.Magic, byMagic.Name, tt.want.Name)
+			} else if fmt.Sprintf("%X", got.Magic) != tt.magic {
+				t.Errorf("got magic %X, want %s", got.Magic, tt.magic)
+			}
+		})
+	}
+
+	if _, err := bparser.NetworkByName("litecoin"); err == nil {
+		t.Errorf("NetworkByName() expected an error for an unknown network")
+	}
+}
+
+func TestMainNetGenesis(t *testing.T) {
+	block, err := bparser.ParseBlock(geneisBlockDec, 0)
+	if err != nil {
+		t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+	}
+
+	if block.Header.BlockHash != bparser.MainNet.GenesisHash {
+		t.Errorf("got genesis hash %s, want %s", block.Header.BlockHash, bparser.MainNet.GenesisHash)
+	} else if bparser.MainNet.RetargetInterval() != 2016 {
+		t.Errorf("got retarget interval %d, want 2016", bparser.MainNet.RetargetInterval())
+	}
+}
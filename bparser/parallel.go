@@ -0,0 +1,337 @@
+package bparser
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	// blocksPerJob is the number of main chain blocks ParseMainChain gives a worker at a time
+	blocksPerJob = 500
+	// jobBuffer is the number of parsed blocks a worker may get ahead of the caller for each job
+	jobBuffer = 64
+)
+
+/*
+ParseStats type reports how much was parsed by ParseBlocksDir or ParseMainChain and how long it took.
+Bytes is the total size of the parsed blocks.
+*/
+type ParseStats struct {
+	Files   int
+	Blocks  int64
+	Txs     int64
+	Bytes   int64
+	Elapsed time.Duration
+}
+
+/*
+BlocksPerSecond method returns the number of blocks parsed per second.
+*/
+func (s ParseStats) BlocksPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Blocks) / s.Elapsed.Seconds()
+}
+
+/*
+MBPerSecond method returns the number of megabytes of blocks parsed per second.
+*/
+func (s ParseStats) MBPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / 1e6 / s.Elapsed.Seconds()
+}
+
+/*
+add method counts a block which was passed to the caller.
+*/
+func (s *ParseStats) add(block BlockData) {
+	s.Blocks++
+	s.Txs += block.Tx.TxCount
+	s.Bytes += block.Size
+}
+
+/*
+parseJob type is a unit of work for a worker, run parses blocks in order and passes each one to emit.
+*/
+type parseJob struct {
+	run    func(ctx context.Context, emit func(BlockData) error) error
+	blocks chan BlockData
+	err    error
+}
+
+/*
+runJobs function runs jobs on up to workers goroutines and calls fn with every block, in job order and then in the order
+each job emitted them, so the output does not depend on the number of workers.
+
+A job only starts once a worker is free, and up to jobBuffer blocks of a job are buffered until the jobs before it are done,
+so memory use is bounded by the number of workers. The first error from a job or from fn cancels the remaining jobs and is returned.
+*/
+func runJobs(ctx context.Context, workers int, jobs []*parseJob, fn func(BlockData) error) (ParseStats, error) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	// cancel runs before waiting, so workers blocked on a full channel return once the caller stops reading
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	// jobs are started in order, the earliest unfinished job always holds a worker so the caller never waits on a job which can not start
+	started := make(chan *parseJob, workers)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(started)
+		sem := make(chan struct{}, workers)
+		for _, job := range jobs {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			wg.Add(1)
+			go func(job *parseJob) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				defer close(job.blocks)
+				job.err = job.run(ctx, func(block BlockData) error {
+					select {
+					case job.blocks <- block:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				})
+			}(job)
+
+			select {
+			case started <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var stats ParseStats
+	for job := range started {
+		for block := range job.blocks {
+			stats.add(block)
+			if err := fn(block); err != nil {
+				stats.Elapsed = time.Since(start)
+				return stats, err
+			}
+		}
+		// the job has finished once its channel is closed
+		if job.err != nil {
+			stats.Elapsed = time.Since(start)
+			return stats, job.err
+		}
+	}
+
+	stats.Elapsed = time.Since(start)
+	return stats, ctx.Err()
+}
+
+/*
+ParseBlocksDir function parses every blk*.dat file of the blocks directory, using up to workers goroutines, and calls fn with
+every block ordered by file and then by offset within the file. BlockNumber of each block is its position in its file, use
+HeaderChain.AssignHeight in fn to set the true height. When workers is 0 or less, runtime.NumCPU() workers are used.
+
+Parsing stops at the first error, including errors returned by fn, and when ctx is cancelled.
+*/
+func ParseBlocksDir(ctx context.Context, dir *BlocksDir, net *Network, workers int, fn func(BlockData) error) (ParseStats, error) {
+	files, err := dir.BlockFiles()
+	if err != nil {
+		return ParseStats{}, err
+	}
+
+	jobs := make([]*parseJob, 0, len(files))
+	for _, path := range files {
+		jobs = append(jobs, &parseJob{
+			blocks: make(chan BlockData, jobBuffer),
+			run: func(ctx context.Context, emit func(BlockData) error) error {
+				return parseFile(ctx, dir, net, path, emit)
+			},
+		})
+	}
+
+	stats, err := runJobs(ctx, workers, jobs, fn)
+	stats.Files = len(files)
+	return stats, err
+}
+
+/*
+parseFile function parses every block of a single blk file and passes each one to emit.
+*/
+func parseFile(ctx context.Context, dir *BlocksDir, net *Network, path string, emit func(BlockData) error) error {
+	file, err := dir.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	br := NewBlockReader(file, net)
+	for i := 0; ; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		block, err := br.NextBlock(i)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			errMsg := fmt.Sprintf("can not parse block %d of %s in parseFile() function.\nerror: %v\n", i, path, err)
+			return errors.New(errMsg)
+		}
+		if err := emit(block); err != nil {
+			return err
+		}
+	}
+}
+
+/*
+ParseMainChain function parses the main chain blocks of chain from height start to the tip, using up to workers goroutines,
+and calls fn with every block in height order. BlockNumber and Status of each block are set from the chain.
+The witness commitment of the blocks from the segwit height of the network is verified, see VerifyWitnessCommitment.
+When workers is 0 or less, runtime.NumCPU() workers are used.
+
+Parsing stops at the first error, including errors returned by fn, and when ctx is cancelled.
+*/
+func ParseMainChain(ctx context.Context, dir *BlocksDir, chain *HeaderChain, start int, workers int, fn func(BlockData) error) (ParseStats, error) {
+	mainChain := chain.MainChain()
+	if start < 0 || start > len(mainChain) {
+		errMsg := fmt.Sprintf("start height %d is not in the main chain, which has %d blocks, in ParseMainChain() function\n", start, len(mainChain))
+		return ParseStats{}, errors.New(errMsg)
+	}
+
+	files := make(map[int]bool)
+	var jobs []*parseJob
+	for i := start; i < len(mainChain); i += blocksPerJob {
+		entries := mainChain[i:min(i+blocksPerJob, len(mainChain))]
+		for _, entry := range entries {
+			files[entry.FileNum] = true
+		}
+		jobs = append(jobs, &parseJob{
+			blocks: make(chan BlockData, jobBuffer),
+			run: func(ctx context.Context, emit func(BlockData) error) error {
+				cr := chainReader{dir: dir, net: chain.net}
+				defer cr.close()
+				for _, entry := range entries {
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+					block, err := cr.read(entry)
+					if err != nil {
+						return err
+					}
+					if err := emit(block); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		})
+	}
+
+	stats, err := runJobs(ctx, workers, jobs, fn)
+	stats.Files = len(files)
+	return stats, err
+}
+
+/*
+chainReader type reads the blocks of chain entries, keeping the last blk file open since consecutive heights
+are mostly stored in the same file.
+*/
+type chainReader struct {
+	dir     *BlocksDir
+	net     *Network
+	file    *DatFile
+	fileNum int
+}
+
+/*
+read method reads the block of entry from its blk file with ReadAt and parses it. Only the bytes of the block are read,
+since the next main chain block is often not the next block in the file.
+*/
+func (cr *chainReader) read(entry *ChainEntry) (BlockData, error) {
+	if entry.FileOffset < 0 {
+		errMsg := fmt.Sprintf("block %s at height %d is not stored in a blk file in read() method\n", entry.Header.BlockHash, entry.Height)
+		return BlockData{}, errors.New(errMsg)
+	}
+
+	if cr.file == nil || entry.FileNum != cr.fileNum {
+		cr.close()
+		file, err := cr.dir.OpenBlockFile(entry.FileNum)
+		if err != nil {
+			return BlockData{}, err
+		}
+		cr.file = file
+		cr.fileNum = entry.FileNum
+	}
+
+	// magic number and block size
+	var prefix [8]byte
+	if _, err := cr.file.ReadAt(prefix[:], entry.FileOffset); err != nil {
+		errMsg := fmt.Sprintf("can not read magic number and size at offset %d in blk%05d.dat in read() method.\nerror: %v\n", entry.FileOffset, entry.FileNum, err)
+		return BlockData{}, errors.New(errMsg)
+	}
+	if !bytes.Equal(prefix[:4], cr.net.Magic[:]) {
+		errMsg := fmt.Sprintf("expected magic number %X at offset %d in blk%05d.dat but got %X in read() method\n", cr.net.Magic, entry.FileOffset, entry.FileNum, prefix[:4])
+		return BlockData{}, errors.New(errMsg)
+	}
+	size := binary.LittleEndian.Uint32(prefix[4:])
+	if size < blockHeaderSize || size > maxBlockSize {
+		errMsg := fmt.Sprintf("block size %d at offset %d in blk%05d.dat is out of range in read() method\n", size, entry.FileOffset, entry.FileNum)
+		return BlockData{}, errors.New(errMsg)
+	}
+
+	blk := make([]byte, 8+int(size))
+	copy(blk, prefix[:])
+	if _, err := cr.file.ReadAt(blk[8:], entry.FileOffset+8); err != nil {
+		errMsg := fmt.Sprintf("can not read block of %d bytes at offset %d in blk%05d.dat in read() method.\nerror: %v\n", size, entry.FileOffset, entry.FileNum, err)
+		return BlockData{}, errors.New(errMsg)
+	}
+
+	block, err := ParseBlock(blk, entry.Height)
+	if err != nil {
+		errMsg := fmt.Sprintf("can not parse block at offset %d in blk%05d.dat in read() method.\nerror: %v\n", entry.FileOffset, entry.FileNum, err)
+		return BlockData{}, errors.New(errMsg)
+	}
+	block.FileOffset = entry.FileOffset
+	if block.Header.BlockHash != entry.Header.BlockHash {
+		errMsg := fmt.Sprintf("expected block %s at offset %d in blk%05d.dat but got %s in read() method\n", entry.Header.BlockHash, entry.FileOffset, entry.FileNum, block.Header.BlockHash)
+		return BlockData{}, errors.New(errMsg)
+	}
+	block.Status = entry.Status
+	// the witness commitment is only enforced once segwit is active
+	if entry.Height >= cr.net.SegWitHeight {
+		if err := block.VerifyWitnessCommitment(); err != nil {
+			errMsg := fmt.Sprintf("can not verify block at height %d in blk%05d.dat in read() method.\nerror: %v\n", entry.Height, entry.FileNum, err)
+			return BlockData{}, errors.New(errMsg)
+		}
+	}
+
+	return block, nil
+}
+
+/*
+close method closes the open blk file, if any.
+*/
+func (cr *chainReader) close() {
+	if cr.file != nil {
+		cr.file.Close()
+		cr.file = nil
+	}
+}
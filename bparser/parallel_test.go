@@ -0,0 +1,152 @@
+package bparser_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+/*
+writeChainFiles function writes the blocks of testChain copies times to blk files in a new blocks directory.
+Blocks are stored two per file, with the later block of each pair first.
+*/
+func writeChainFiles(t *testing.T, copies int) *bparser.BlocksDir {
+	t.Helper()
+	chain := testChain(t)
+	dir := t.TempDir()
+	for i := 0; i < copies; i++ {
+		for j := 0; j < len(chain); j += 2 {
+			file := append(slices.Clone(chain[j+1]), chain[j]...)
+			name := fmt.Sprintf("blk%05d.dat", i*len(chain)/2+j/2)
+			if err := os.WriteFile(filepath.Join(dir, name), file, 0o644); err != nil {
+				t.Fatalf("can not write %s\nerror: %v\n", name, err)
+			}
+		}
+	}
+
+	blocksDir, err := bparser.OpenBlocksDir(dir)
+	if err != nil {
+		t.Fatalf("OpenBlocksDir() returned error\nerror: %v\n", err)
+	}
+	return blocksDir
+}
+
+func TestParseBlocksDir(t *testing.T) {
+	blocksDir := writeChainFiles(t, 10)
+	chain := testChain(t)
+
+	for _, workers := range []int{1, 3, 0} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			var hashes []bparser.Hash
+			stats, err := bparser.ParseBlocksDir(context.Background(), blocksDir, &bparser.MainNet, workers, func(block bparser.BlockData) error {
+				hashes = append(hashes, block.Header.BlockHash)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ParseBlocksDir() returned error\nerror: %v\n", err)
+			} else if stats.Files != 20 || stats.Blocks != 40 || stats.Txs != 50 {
+				t.Errorf("ParseBlocksDir() got stats %+v, want 20 files, 40 blocks and 50 txs", stats)
+			}
+
+			// every file holds block j + 1 before block j
+			for i, hash := range hashes {
+				want := i%4 ^ 1
+				block, _ := bparser.ParseBlock(chain[want], 0)
+				if hash != block.Header.BlockHash {
+					t.Fatalf("ParseBlocksDir() got block %s at position %d, want %s", hash, i, block.Header.BlockHash)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBlocksDirStops(t *testing.T) {
+	blocksDir := writeChainFiles(t, 10)
+
+	stop := errors.New("stop")
+	count := 0
+	stats, err := bparser.ParseBlocksDir(context.Background(), blocksDir, &bparser.MainNet, 4, func(block bparser.BlockData) error {
+		count++
+		if count == 5 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("ParseBlocksDir() expected the error returned by fn but got %v", err)
+	} else if count != 5 || stats.Blocks != 5 {
+		t.Errorf("ParseBlocksDir() called fn %d times and counted %d blocks after fn returned an error, want 5", count, stats.Blocks)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = bparser.ParseBlocksDir(ctx, blocksDir, &bparser.MainNet, 4, func(block bparser.BlockData) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ParseBlocksDir() expected context.Canceled but got %v", err)
+	}
+}
+
+func TestParseMainChain(t *testing.T) {
+	blocksDir := writeChainFiles(t, 1)
+	chain, err := bparser.BuildHeaderChain(blocksDir, &bparser.MainNet)
+	if err != nil {
+		t.Fatalf("BuildHeaderChain() returned error\nerror: %v\n", err)
+	}
+
+	var heights []int
+	stats, err := bparser.ParseMainChain(context.Background(), blocksDir, chain, 1, 2, func(block bparser.BlockData) error {
+		if block.Status != bparser.StatusMainChain {
+			t.Errorf("ParseMainChain() got Status = %s, want main", block.Status)
+		} else if entry, _ := chain.AtHeight(block.BlockNumber); entry.Header.BlockHash != block.Header.BlockHash {
+			t.Errorf("ParseMainChain() got block %s at height %d", block.Header.BlockHash, block.BlockNumber)
+		}
+		heights = append(heights, block.BlockNumber)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseMainChain() returned error\nerror: %v\n", err)
+	} else if !slices.Equal(heights, []int{1, 2, 3}) {
+		t.Errorf("ParseMainChain() got heights %v, want [1 2 3]", heights)
+	} else if stats.Files != 2 || stats.Blocks != 3 {
+		t.Errorf("ParseMainChain() got stats %+v, want 2 files and 3 blocks", stats)
+	}
+
+	if _, err := bparser.ParseMainChain(context.Background(), blocksDir, chain, 5, 2, nil); err == nil {
+		t.Errorf("ParseMainChain() expected an error for a start height past the tip")
+	}
+}
+
+/*
+test ParseMainChain function only checks the witness commitment from the segwit height of the network
+*/
+func TestParseMainChainWitnessCommitment(t *testing.T) {
+	segWitTx := hexBytes(segWitTxHex)
+	// block 1 has witness data but no witness commitment
+	block1 := buildBlock(testHeader(bparser.MainNet.GenesisHash, 1), segWitCoinbase(nil), segWitTx)
+	blocksDir, err := bparser.OpenBlocksDir(writeBlocksDir(t, append(slices.Clone(geneisBlockDec), block1...), nil))
+	if err != nil {
+		t.Fatalf("OpenBlocksDir() returned error\nerror: %v\n", err)
+	}
+
+	for _, segWitHeight := range []int{2, 1} {
+		net := bparser.MainNet
+		net.SegWitHeight = segWitHeight
+		chain, err := bparser.BuildHeaderChain(blocksDir, &net)
+		if err != nil {
+			t.Fatalf("BuildHeaderChain() returned error\nerror: %v\n", err)
+		}
+
+		_, err = bparser.ParseMainChain(context.Background(), blocksDir, chain, 0, 1, func(block bparser.BlockData) error { return nil })
+		if segWitHeight > 1 && err != nil {
+			t.Errorf("ParseMainChain() with segwit height %d returned error\nerror: %v\n", segWitHeight, err)
+		} else if segWitHeight <= 1 && err == nil {
+			t.Errorf("ParseMainChain() with segwit height %d expected an error for the missing witness commitment", segWitHeight)
+		}
+	}
+}
@@ -6,21 +6,21 @@ package bparser
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"slices"
-	"strconv"
 	"strings"
 	"text/template"
 	"time"
-)
 
-// const (
-// 	segWitHeight = 481_824
-// )
+	"github.com/davidhintelmann/blockchain/bparser/script"
+)
 
 /*
 ByteSwapArray function will take a 64 character hexidecimal string and swap the bytes and return as a slice.
@@ -107,17 +107,21 @@ func ParseBlocks(blks []byte, block_height_start int, block_height_end int, inpu
 		blks = append(input_remainder, blks...)
 	}
 
-	// split bytes on magic number 'f9beb4d9'
-	blocks := bytes.Split(blks, []byte{249, 190, 180, 217})[1:]
-
-	// var block BlockData
-	for i, b := range blocks {
-		// fmt.Printf("parsing block number: %d\n", i)
-		blk := append([]byte{249, 190, 180, 217}, b...)
+	return ParseBlocksReader(bytes.NewReader(blks), &MainNet, block_height_start, block_height_end)
+}
 
+/*
+ParseBlocksReader function will parse an entire .dat bitcoin-core file of network net one block at a time from r, and output a text file.
+Returns the number of blocks read once block_height_end is reached or there are no more blocks.
+*/
+func ParseBlocksReader(r io.Reader, net *Network, block_height_start int, block_height_end int) (int, error) {
+	br := NewBlockReader(r, net)
+	for i := 0; ; i++ {
 		// parse block
-		block, err := ParseBlock(blk, i)
-		if err != nil {
+		block, err := br.NextBlock(i)
+		if err == io.EOF {
+			return i, nil
+		} else if err != nil {
 			errMsg := fmt.Sprintf("could not parse block, error: %v\n", err)
 			return -1, errors.New(errMsg)
 		}
@@ -136,8 +140,6 @@ func ParseBlocks(blks []byte, block_height_start int, block_height_end int, inpu
 			return i, nil
 		}
 	}
-
-	return -1, errors.New("did no expect to exit loop, ParseBlocks() function")
 }
 
 /*
@@ -186,52 +188,174 @@ type BlockHeaderString struct {
 	Nonce      string
 }
 
+const (
+	// blockHeaderSize is the number of bytes in a serialized block header
+	blockHeaderSize = 80
+)
+
 // structs for block strings/int/time data in big-endian format
+//
+// StrippedSize is the size of the block without witness data and Weight is
+// StrippedSize * 3 + Size as defined in BIP141. FileOffset is the offset of
+// the magic number in the dat file when the block was read with a BlockReader.
+//
+// BlockNumber is the position of the block in its dat file until
+// HeaderChain.AssignHeight sets it to the true height along with Status.
 type BlockData struct {
-	BlockNumber int
-	Magic       string
-	Size        int64
-	Header      BlockHeaderData
-	Tx          BlockTransactionsData
+	BlockNumber  int
+	Status       ChainStatus
+	FileOffset   int64
+	Magic        string
+	Size         int64
+	StrippedSize int64
+	Weight       int64
+	Header       BlockHeaderData
+	Tx           BlockTransactionsData
 }
 
+// BlockHeaderData is the 80 byte block header. Difficulty is decoded from
+// Bits, see CalcDifficulty.
 type BlockHeaderData struct {
 	Version       int64
-	BlockHash     string
-	PrevBlock     string
-	MerkleRoot    string
+	BlockHash     Hash
+	PrevBlock     Hash
+	MerkleRoot    Hash
 	TimestampUnix int64
 	Timestamp     time.Time
-	Bits          string
+	Bits          uint32
+	Difficulty    float64
 	Nonce         int64
 }
 
 type BlockTransactionsData struct {
 	TxCount int64
-	Tx      TxData
+	Tx      []TxData
 }
 
+// TxData is a single transaction from a block. Offset and Length locate
+// the raw transaction bytes within the block, where offset 0 is the first
+// byte of the 80 byte block header.
+//
+// SegWit is true when the tx uses the BIP144 serialization with a marker,
+// flag and witness data. Raw holds the full serialization as it appears in
+// the block.
+//
+// TxId is the double sha256 of the legacy serialization and WTxId the double
+// sha256 of the full serialization, both in the byte swapped order used by
+// block explorers. Size is the full size, StrippedSize the legacy size,
+// Weight is StrippedSize * 3 + Size and VSize is Weight / 4 rounded up.
+//
+// Fee, in satoshis, and FeeRate, in satoshis per vbyte, are only known once
+// the spent outputs have been attached with ApplyUndo or
+// UTXOSet.ConnectBlock, they are zero until then and always zero for the
+// coinbase tx.
 type TxData struct {
-	Version     int64
-	InputCount  int64
-	Inputs      []TxInputs
-	OutputCount int64
-	Outputs     []TxOutputs
-	Locktime    []byte
+	TxId         Hash
+	WTxId        Hash
+	Version      int64
+	SegWit       bool
+	InputCount   int64
+	Inputs       []TxInputs
+	OutputCount  int64
+	Outputs      []TxOutputs
+	Locktime     []byte
+	Offset       int64
+	Length       int64
+	Size         int64
+	StrippedSize int64
+	Weight       int64
+	VSize        int64
+	Fee          Amount
+	FeeRate      float64
+	Raw          []byte
+
+	// index in Raw where the witness data starts, only used for segwit txs
+	witnessStart int
 }
 
+/*
+Serialize method returns the full serialization of the tx, including the marker, flag and witness data for segwit txs.
+*/
+func (tx TxData) Serialize() []byte {
+	return tx.Raw
+}
+
+/*
+SerializeNoWitness method returns the legacy serialization of the tx, which leaves out the marker, flag and witness data.
+
+For txs without witness data this is the same as Serialize.
+*/
+func (tx TxData) SerializeNoWitness() []byte {
+	if !tx.SegWit {
+		return tx.Raw
+	}
+
+	legacy := make([]byte, 0, tx.witnessStart+2)
+	legacy = append(legacy, tx.Raw[:4]...)
+	legacy = append(legacy, tx.Raw[6:tx.witnessStart]...)
+	legacy = append(legacy, tx.Raw[len(tx.Raw)-4:]...)
+	return legacy
+}
+
+/*
+IsCoinbase method reports whether the tx is a coinbase tx, a single input spending the null outpoint, as bitcoin-core's IsCoinBase.
+*/
+func (tx TxData) IsCoinbase() bool {
+	return len(tx.Inputs) == 1 && tx.Inputs[0].TxId.IsZero() && tx.Inputs[0].Vout == 0xffffffff
+}
+
+/*
+LocktimeNumber method returns the locktime of the tx as a number, Locktime holds its 4 little-endian bytes.
+*/
+func (tx TxData) LocktimeNumber() uint32 {
+	if len(tx.Locktime) != 4 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(tx.Locktime)
+}
+
+/*
+VoutNumber method returns Vout, the index of the output spent by the input.
+
+Deprecated: Vout is decoded to a number while parsing, use it directly.
+*/
+func (in TxInputs) VoutNumber() uint32 {
+	return in.Vout
+}
+
+/*
+SequenceNumber method returns Sequence, the sequence number of the input.
+
+Deprecated: Sequence is decoded to a number while parsing, use it directly.
+*/
+func (in TxInputs) SequenceNumber() uint32 {
+	return in.Sequence
+}
+
+// TxInputs is a single input of a tx. ScriptSig and Witness point into the
+// block they were parsed from. Witness holds the witness stack of the input,
+// it is empty for legacy txs and for inputs without witness data.
+// PrevOut is the output spent by the input, nil until it is attached with
+// ApplyUndo or UTXOSet.ConnectBlock.
 type TxInputs struct {
-	TxId          string
-	Vout          string
+	TxId          Hash
+	Vout          uint32
 	ScriptSigSize int64
-	ScriptSig     string
-	Sequence      string
+	ScriptSig     []byte
+	Sequence      uint32
+	Witness       [][]byte
+	PrevOut       *Coin
 }
 
+// TxOutputs is a single output of a tx. Type is the kind of ScriptPubKey, see
+// script.Classify, and Address the address it pays on the network of the
+// block, empty when the output has no address or the network is not known.
 type TxOutputs struct {
-	Amount           []byte
+	Amount           Amount
 	ScriptPubKeySize int64
 	ScriptPubKey     []byte
+	Type             script.Class
+	Address          string
 }
 
 type ParseBlockSizeBytes struct {
@@ -243,16 +367,11 @@ func (p ParseBlockSizeBytes) Raw() []byte {
 }
 
 func (p ParseBlockSizeBytes) ParseInt() (int64, error) {
-	bs := fmt.Sprintf("%X", p.Size)
-	// swap bytes
-	blockSize2 := ByteSwapStr(bs)
-	// convert string to int64
-	bs2, err := strconv.ParseInt(blockSize2, 16, 64)
-	if err != nil {
-		errMsg := fmt.Sprintf("could not parse int, error: %v\n", err)
+	if len(p.Size) != 4 {
+		errMsg := fmt.Sprintf("could not parse int, expected 4 bytes but got %d\n", len(p.Size))
 		return -1, errors.New(errMsg)
 	}
-	return bs2, nil
+	return int64(binary.LittleEndian.Uint32(p.Size)), nil
 }
 
 func ParseBlockSizeRaw(blks []byte) ([]byte, error) {
@@ -280,21 +399,30 @@ func ParseBlockSize(blkSize []byte) (int64, error) {
 }
 
 /*
-ParseBlockSizeFunc function slices the first 4 to 8 bytes and returns an int.
-First the little-endian magic number is converted into big-endian, by swapping the bytes, and finally converted into an int.
+ParseBlockSizeFunc function reads the little-endian block size in bytes 4 to 8 of a block record, after the magic number.
 */
 func ParseBlockSizeFunc(blks []byte) (int64, error) {
-	blockSize := blks[4:8]
-	bs := fmt.Sprintf("%X", blockSize)
-	// swap bytes
-	blockSize2 := ByteSwapStr(bs)
-	// convert string to int64
-	bs2, err := strconv.ParseInt(blockSize2, 16, 16)
+	if len(blks) < 8 {
+		errMsg := fmt.Sprintf("can not read block size from %d bytes, expected at least 8 in ParseBlockSizeFunc() function\n", len(blks))
+		return -1, errors.New(errMsg)
+	}
+	return int64(binary.LittleEndian.Uint32(blks[4:8])), nil
+}
+
+/*
+blockRecordSize function returns the size of the block record in blks, erroring when blks is shorter than the
+magic number, size and header or than the size says, so the fixed offsets of ParseBlockRaw and ParseBlockStr can be sliced.
+*/
+func blockRecordSize(blks []byte) (int64, error) {
+	blockSize, err := ParseBlockSizeFunc(blks)
 	if err != nil {
-		errMsg := fmt.Sprintf("could not parse int, error: %v\n", err)
+		return -1, err
+	}
+	if blockSize < blockHeaderSize+2 || int64(len(blks)) < 8+blockSize {
+		errMsg := fmt.Sprintf("block size %d does not fit in %d bytes in blockRecordSize() function\n", blockSize, len(blks))
 		return -1, errors.New(errMsg)
 	}
-	return bs2, nil
+	return blockSize, nil
 }
 
 /*
@@ -302,7 +430,7 @@ ParseBlockRaw function will parse a single block at a time and return byte slice
 */
 func ParseBlockRaw(blks []byte) (ParseBlockBytes, error) {
 	if len(blks) >= 8 {
-		blockSize, err := ParseBlockSizeFunc(blks)
+		blockSize, err := blockRecordSize(blks)
 		if err != nil {
 			return ParseBlockBytes{}, err
 		}
@@ -318,7 +446,7 @@ func ParseBlockRaw(blks []byte) (ParseBlockBytes, error) {
 
 		parseBlockTransactions := BlockTransactionsBytes{
 			TxCount: blks[88:90],
-			TxId:    blks[90:blockSize],
+			TxId:    blks[90 : 8+blockSize],
 		}
 
 		parseBlock := ParseBlockBytes{
@@ -339,7 +467,7 @@ ParseBlockStr function will parse a single block at a time and return strings of
 */
 func ParseBlockStr(blks []byte) (ParseBlockString, error) {
 	if len(blks) >= 8 {
-		blockSize, err := ParseBlockSizeFunc(blks)
+		blockSize, err := blockRecordSize(blks)
 		if err != nil {
 			return ParseBlockString{}, err
 		}
@@ -355,7 +483,7 @@ func ParseBlockStr(blks []byte) (ParseBlockString, error) {
 
 		parseBlockTransactions := BlockTransactionsBytes{
 			TxCount: blks[88:90],
-			TxId:    blks[90:blockSize],
+			TxId:    blks[90 : 8+blockSize],
 		}
 
 		parseBlock := ParseBlockString{
@@ -373,6 +501,9 @@ func ParseBlockStr(blks []byte) (ParseBlockString, error) {
 
 /*
 ParseBlock function will parse a single block at a time and return strings or ints of big-endian numbers.
+
+The merkle root of the block is verified, when it does not match a *MerkleError is returned along with the parsed block.
+The witness commitment depends on the height of the block, see VerifyWitnessCommitment.
 */
 func ParseBlock(blk []byte, blockNum int) (BlockData, error) {
 	// var blockSize int64
@@ -400,12 +531,29 @@ func ParseBlock(blk []byte, blockNum int) (BlockData, error) {
 			return BlockData{}, errors.New(errMsg)
 		}
 
+		// witness bytes only count once towards the block weight
+		strippedSize := blockSize
+		for _, tx := range parseBlockTransactions.Tx {
+			strippedSize -= tx.Size - tx.StrippedSize
+		}
+
 		parseBlock := BlockData{
-			BlockNumber: blockNum,
-			Magic:       ByteSwapStr(fmt.Sprintf("%X", blk[:4])),
-			Size:        blockSize,
-			Header:      parseBlockHeader,
-			Tx:          parseBlockTransactions,
+			BlockNumber:  blockNum,
+			Magic:        reversedUpperHex(blk[:4]),
+			Size:         blockSize,
+			StrippedSize: strippedSize,
+			Weight:       strippedSize*3 + blockSize,
+			Header:       parseBlockHeader,
+			Tx:           parseBlockTransactions,
+		}
+		// addresses depend on the network, which is known from the magic number
+		if net, err := NetworkByMagic(blk[:4]); err == nil {
+			setAddresses(&parseBlock, net)
+		}
+
+		// a corrupt blk file will most likely have txs which no longer match the header
+		if err := parseBlock.VerifyMerkleRoot(); err != nil {
+			return parseBlock, err
 		}
 		return parseBlock, nil
 	} else {
@@ -418,44 +566,25 @@ func ParseBlock(blk []byte, blockNum int) (BlockData, error) {
 parseBlockHeader function is used in ParseBlock function to parse the header block in dat file from bitcoin-core.
 */
 func parseBlockHeader(blkHeader []byte) (BlockHeaderData, error) {
-	v, err := strconv.ParseInt(ByteSwapStr(fmt.Sprintf("%X", blkHeader[:4])), 16, 16)
-	if err != nil {
-		errMsg := fmt.Sprintf("can not swap version bytes in ParseBlock() function.\nerror: %v\n", err)
+	if len(blkHeader) != blockHeaderSize {
+		errMsg := fmt.Sprintf("expected a %d byte header but got %d bytes in parseBlockHeader() function\n", blockHeaderSize, len(blkHeader))
 		return BlockHeaderData{}, errors.New(errMsg)
 	}
 
-	t, err := strconv.ParseInt(ByteSwapStr(fmt.Sprintf("%X", blkHeader[68:72])), 16, 64)
-	if err != nil {
-		errMsg := fmt.Sprintf("can not swap time bytes in ParseBlock() function.\nerror: %v\n", err)
-		return BlockHeaderData{}, errors.New(errMsg)
-	}
-
-	n, err := strconv.ParseInt(ByteSwapStr(fmt.Sprintf("%X", blkHeader[76:80])), 16, 64)
-	if err != nil {
-		errMsg := fmt.Sprintf("can not swap nonce bytes in ParseBlock() function.\nerror: %v\n", err)
-		return BlockHeaderData{}, errors.New(errMsg)
-	}
-
-	blockHash := sha256.New()
-	blockHash.Write(blkHeader)
-	sha256_single := blockHash.Sum(nil)
-	blockHash = sha256.New()
-	blockHash.Write(sha256_single)
-	sha256_double := blockHash.Sum(nil)
-	slices.Reverse(sha256_double)
-
-	prevBlock := blkHeader[4:36]
-	slices.Reverse(prevBlock)
+	// every field is little-endian, version, timestamp and nonce are unsigned
+	t := int64(binary.LittleEndian.Uint32(blkHeader[68:72]))
+	bits := binary.LittleEndian.Uint32(blkHeader[72:76])
 
 	blockHeaderData := BlockHeaderData{
-		Version:       v,
-		BlockHash:     fmt.Sprintf("%X", sha256_double),
-		PrevBlock:     fmt.Sprintf("%X", prevBlock),
-		MerkleRoot:    ByteSwapStr(fmt.Sprintf("%X", blkHeader[36:68])),
+		Version:       int64(binary.LittleEndian.Uint32(blkHeader[:4])),
+		BlockHash:     doubleSha256(blkHeader),
+		PrevBlock:     Hash(blkHeader[4:36]),
+		MerkleRoot:    Hash(blkHeader[36:68]),
 		TimestampUnix: t,
 		Timestamp:     time.Unix(t, 0),
-		Bits:          ByteSwapStr(fmt.Sprintf("%X", blkHeader[72:76])),
-		Nonce:         n,
+		Bits:          bits,
+		Difficulty:    CalcDifficulty(bits),
+		Nonce:         int64(binary.LittleEndian.Uint32(blkHeader[76:80])),
 	}
 
 	return blockHeaderData, nil
@@ -463,24 +592,39 @@ func parseBlockHeader(blkHeader []byte) (BlockHeaderData, error) {
 
 /*
 parseBlockTransactions function is used in ParseBlock function to parse the transaction block in dat file from bitcoin-core.
+
+Every transaction is decoded in order, the number of transactions decoded must match the tx count
+and the transactions must consume the rest of the block.
 */
 func parseBlockTransactions(blkTransactions []byte) (BlockTransactionsData, error) {
 	// size of incoming transaction block
-	txCount, pad, err := ParseTransactionBlockSize(blkTransactions[:9])
+	txCount, pad, err := ParseTransactionBlockSize(blkTransactions)
 	if err != nil || txCount < 0 {
 		errMsg := fmt.Sprintf("can not parse transaction block size in parseBlockTransactions() function.\nerror: %v\n", err)
 		return BlockTransactionsData{}, errors.New(errMsg)
 	}
 
-	txData, err := ParseBlockTx(blkTransactions, pad)
-	if err != nil {
-		errMsg := fmt.Sprintf("can not swap parse block tx in ParseBlock() function.\nerror: %v\n", err)
+	txs := make([]TxData, 0, txCount)
+	for i := 0; i < int(txCount); i++ {
+		txData, err := ParseBlockTx(blkTransactions, pad)
+		if err != nil {
+			errMsg := fmt.Sprintf("can not parse tx %d of %d in parseBlockTransactions() function.\nerror: %v\n", i, txCount, err)
+			return BlockTransactionsData{}, errors.New(errMsg)
+		}
+		// offset is relative to the start of the block header
+		txData.Offset = int64(blockHeaderSize + pad)
+		txs = append(txs, txData)
+		pad += int(txData.Length)
+	}
+
+	if pad != len(blkTransactions) {
+		errMsg := fmt.Sprintf("parsed %d txs using %d bytes but transaction block has %d bytes in parseBlockTransactions() function\n", txCount, pad, len(blkTransactions))
 		return BlockTransactionsData{}, errors.New(errMsg)
 	}
 
 	blockTransactionsData := BlockTransactionsData{
 		TxCount: txCount,
-		Tx:      txData,
+		Tx:      txs,
 	}
 
 	return blockTransactionsData, nil
@@ -488,97 +632,221 @@ func parseBlockTransactions(blkTransactions []byte) (BlockTransactionsData, erro
 
 /*
 ParseBlockTx function is used in parseBlockTransactions function to parse the individual tx in transaction block.
+
+The tx starts at index pad of blkTransactions, the returned TxData has its Length set to the number of bytes the tx uses
+so the next tx starts at pad + Length.
 */
 func ParseBlockTx(blkTransactions []byte, pad int) (TxData, error) {
-	// parse version number for block transaction
-	v, err := strconv.ParseInt(ByteSwapStr(fmt.Sprintf("%X", blkTransactions[pad:pad+4])), 16, 16)
-	if err != nil {
-		errMsg := fmt.Sprintf("can not swap version bytes in ParseBlock() function.\nerror: %v\n", err)
+	if pad < 0 || len(blkTransactions) < pad+4 {
+		errMsg := fmt.Sprintf("can not slice tx version bytes at index %d, tx block has %d bytes\n", pad, len(blkTransactions))
 		return TxData{}, errors.New(errMsg)
 	}
 
+	// parse version number for block transaction
+	v := int64(binary.LittleEndian.Uint32(blkTransactions[pad : pad+4]))
+
+	// segwit txs have a 0x00 marker and 0x01 flag after the version, see BIP144
+	var segWit bool
+	var markerPad int
+	if len(blkTransactions) > pad+4 && blkTransactions[pad+4] == 0 {
+		if len(blkTransactions) < pad+6 || blkTransactions[pad+5] != 1 {
+			return TxData{}, errors.New("tx has segwit marker but flag is not 0x01 in ParseBlockTx() function")
+		}
+		segWit = true
+		markerPad = 2
+	}
+
 	// variable size for inputCount
-	inputCount, txInputPad, err := ParseTransactionBlockSize(blkTransactions[pad+4 : pad+18])
+	inputCount, txInputPad, err := ParseTransactionBlockSize(blkTransactions[pad+4+markerPad:])
 	if err != nil || inputCount < 0 {
-		errMsg := fmt.Sprintf("can not parse transaction input count in parseBlockTransactions() function, segwit transaction?\nerror: %v\n", err)
+		errMsg := fmt.Sprintf("can not parse transaction input count in parseBlockTransactions() function.\nerror: %v\n", err)
 		return TxData{}, errors.New(errMsg)
 	}
 
-	var txInputs []TxInputs
-	var blkPad int = pad + txInputPad + 4
+	// every input uses at least 41 bytes, so a corrupt count can not allocate more than the tx block
+	var blkPad int = pad + txInputPad + 4 + markerPad
+	txInputs := make([]TxInputs, 0, min(inputCount, int64(len(blkTransactions)-blkPad)/41))
 	for i := 0; i < int(inputCount); i++ {
+		if len(blkTransactions) < blkPad+37 {
+			errMsg := fmt.Sprintf("can not slice tx input %d at index %d, tx block has %d bytes\n", i, blkPad, len(blkTransactions))
+			return TxData{}, errors.New(errMsg)
+		}
 		blkTx := blkTransactions[blkPad:]
 		txId := blkTx[:32]
-		vOut := blkTx[32:36]
 		// variable size
-		scriptSigSize, scriptPad, err := ParseTransactionBlockSize(blkTx[36:46])
-		if err != nil || inputCount < 0 {
-			errMsg := fmt.Sprintf("can not parse transaction input script size in parseBlockTransactions() function, segwit transaction?\nerror: %v\n", err)
+		scriptSigSize, scriptPad, err := ParseTransactionBlockSize(blkTx[36:])
+		if err != nil || scriptSigSize < 0 {
+			errMsg := fmt.Sprintf("can not parse transaction input script size in parseBlockTransactions() function.\nerror: %v\n", err)
 			return TxData{}, errors.New(errMsg)
 		}
 
 		scriptPad = scriptPad + 36
-		scriptPadEnd := blkPad + scriptPad + 4
+		if len(blkTx) < scriptPad+int(scriptSigSize)+4 {
+			errMsg := fmt.Sprintf("scriptSigSize (%d) of tx input %d is larger than the remaining tx block\n", scriptSigSize, i)
+			return TxData{}, errors.New(errMsg)
+		}
 		scriptSig := blkTx[scriptPad : scriptPad+int(scriptSigSize)]
-		sequence := blkTx[scriptPad+int(scriptSigSize) : scriptPad+4+int(scriptSigSize)]
-		blkPad = scriptPadEnd + int(scriptSigSize)
+		sequence := binary.LittleEndian.Uint32(blkTx[scriptPad+int(scriptSigSize):])
+		blkPad = blkPad + scriptPad + 4 + int(scriptSigSize)
 
 		txInput := TxInputs{
-			TxId:          fmt.Sprintf("%X", txId),
-			Vout:          fmt.Sprintf("%X", vOut),
+			TxId:          Hash(txId),
+			Vout:          binary.LittleEndian.Uint32(blkTx[32:36]),
 			ScriptSigSize: scriptSigSize,
-			ScriptSig:     fmt.Sprintf("%X", scriptSig),
-			Sequence:      fmt.Sprintf("%X", sequence),
+			ScriptSig:     scriptSig,
+			Sequence:      sequence,
 		}
 		txInputs = append(txInputs, txInput)
 	}
 
 	// variable size for outputCount
-	outputCount, txOutputPad, err := ParseTransactionBlockSize(blkTransactions[blkPad : blkPad+10]) // blockTransactionsData.Tx[4:14]
+	if len(blkTransactions) <= blkPad {
+		errMsg := fmt.Sprintf("can not slice tx output count at index %d, tx block has %d bytes\n", blkPad, len(blkTransactions))
+		return TxData{}, errors.New(errMsg)
+	}
+	outputCount, txOutputPad, err := ParseTransactionBlockSize(blkTransactions[blkPad:])
 	if err != nil || outputCount < 0 {
-		errMsg := fmt.Sprintf("can not parse transaction output count in parseBlockTransactions() function, segwit transaction?\nerror: %v\n", err)
+		errMsg := fmt.Sprintf("can not parse transaction output count in parseBlockTransactions() function.\nerror: %v\n", err)
 		return TxData{}, errors.New(errMsg)
 	}
 
-	var txOutputs []TxOutputs
+	// every output uses at least 9 bytes
 	blkPadOutput := blkPad + txOutputPad
+	txOutputs := make([]TxOutputs, 0, min(outputCount, int64(len(blkTransactions)-blkPadOutput)/9))
 	for i := 0; i < int(outputCount); i++ {
+		if len(blkTransactions) < blkPadOutput+9 {
+			errMsg := fmt.Sprintf("can not slice tx output %d at index %d, tx block has %d bytes\n", i, blkPadOutput, len(blkTransactions))
+			return TxData{}, errors.New(errMsg)
+		}
 		blkTx := blkTransactions[blkPadOutput:]
 		amount := blkTx[:8]
 		// variable size
-		scriptPubKeySize, scriptPad, err := ParseTransactionBlockSize(blkTx[8:18]) // blockTransactionsData.Tx[4:14]
-		if err != nil || outputCount < 0 {
-			errMsg := fmt.Sprintf("can not parse transaction output script size in parseBlockTransactions() function, segwit transaction?\nerror: %v\n", err)
+		scriptPubKeySize, scriptPad, err := ParseTransactionBlockSize(blkTx[8:])
+		if err != nil || scriptPubKeySize < 0 {
+			errMsg := fmt.Sprintf("can not parse transaction output script size in parseBlockTransactions() function.\nerror: %v\n", err)
 			return TxData{}, errors.New(errMsg)
 		}
-		scriptPubKey := blkTx[scriptPad+8 : scriptPad+8+int(scriptPubKeySize)]
-		blkPadOutput = blkPadOutput + scriptPad + 8 + int(scriptPubKeySize)
-
-		if int(scriptPubKeySize) != len(scriptPubKey) {
-			errMsg := fmt.Sprintf("scriptPubKeySize does not equal length of scriptPubKey bytes in parseBlockTransactions() function\nerror: %v\n", err)
+		if len(blkTx) < scriptPad+8+int(scriptPubKeySize) {
+			errMsg := fmt.Sprintf("scriptPubKeySize (%d) of tx output %d is larger than the remaining tx block\n", scriptPubKeySize, i)
 			return TxData{}, errors.New(errMsg)
 		}
+		scriptPubKey := blkTx[scriptPad+8 : scriptPad+8+int(scriptPubKeySize)]
+		blkPadOutput = blkPadOutput + scriptPad + 8 + int(scriptPubKeySize)
 
 		txOutput := TxOutputs{
-			Amount:           amount,
+			Amount:           Amount(binary.LittleEndian.Uint64(amount)),
 			ScriptPubKeySize: scriptPubKeySize,
 			ScriptPubKey:     scriptPubKey,
+			Type:             script.Classify(scriptPubKey),
 		}
 		txOutputs = append(txOutputs, txOutput)
 	}
 
+	// witness data comes after the outputs, one witness stack per input
+	witnessStart := blkPadOutput
+	if segWit {
+		for i := range txInputs {
+			witness, witnessPad, err := parseWitness(blkTransactions[blkPadOutput:])
+			if err != nil {
+				errMsg := fmt.Sprintf("can not parse witness for tx input %d in ParseBlockTx() function.\nerror: %v\n", i, err)
+				return TxData{}, errors.New(errMsg)
+			}
+			txInputs[i].Witness = witness
+			blkPadOutput += witnessPad
+		}
+	}
+
+	// locktime is the last 4 bytes of the tx
+	if len(blkTransactions) < blkPadOutput+4 {
+		errMsg := fmt.Sprintf("can not slice tx locktime at index %d, tx block has %d bytes\n", blkPadOutput, len(blkTransactions))
+		return TxData{}, errors.New(errMsg)
+	}
+	locktime := blkTransactions[blkPadOutput : blkPadOutput+4]
+
+	raw := blkTransactions[pad : blkPadOutput+4]
+	size := int64(len(raw))
+	strippedSize := size
+	txId := doubleSha256(raw)
+	wTxId := txId
+	if segWit {
+		// legacy serialization is the version, everything between the flag and the witness data, and the locktime
+		txId = doubleSha256(raw[:4], raw[6:witnessStart-pad], raw[len(raw)-4:])
+		strippedSize = int64(witnessStart-pad) + 2
+	}
+	weight := strippedSize*3 + size
+
 	txData := TxData{
-		Version:     v,
-		InputCount:  inputCount,
-		Inputs:      txInputs,
-		OutputCount: outputCount,
-		Outputs:     txOutputs,
-		Locktime:    blkTransactions[len(blkTransactions)-4:],
+		TxId:         txId,
+		WTxId:        wTxId,
+		Version:      v,
+		SegWit:       segWit,
+		InputCount:   inputCount,
+		Inputs:       txInputs,
+		OutputCount:  outputCount,
+		Outputs:      txOutputs,
+		Locktime:     locktime,
+		Offset:       int64(pad),
+		Length:       size,
+		Size:         size,
+		StrippedSize: strippedSize,
+		Weight:       weight,
+		VSize:        (weight + 3) / 4,
+		Raw:          raw,
+		witnessStart: witnessStart - pad,
 	}
 
 	return txData, nil
 }
 
+/*
+doubleSha256 function hashes the concatenation of all parts with sha256 twice.
+*/
+func doubleSha256(parts ...[]byte) Hash {
+	var single [sha256.Size]byte
+	if len(parts) == 1 {
+		single = sha256.Sum256(parts[0])
+	} else {
+		hash := sha256.New()
+		for _, part := range parts {
+			hash.Write(part)
+		}
+		hash.Sum(single[:0])
+	}
+	return sha256.Sum256(single[:])
+}
+
+/*
+parseWitness function is used in ParseBlockTx function to parse the witness stack of a single tx input.
+
+Witness stack is a compact size for the number of items, followed by each item as a compact size and the item bytes.
+Returns the witness items and the number of bytes used.
+*/
+func parseWitness(blkWitness []byte) ([][]byte, int, error) {
+	itemCount, pad, err := ParseTransactionBlockSize(blkWitness)
+	if err != nil || itemCount < 0 {
+		errMsg := fmt.Sprintf("can not parse witness item count in parseWitness() function.\nerror: %v\n", err)
+		return nil, -1, errors.New(errMsg)
+	}
+
+	witness := make([][]byte, 0, min(itemCount, int64(len(blkWitness))))
+	for i := 0; i < int(itemCount); i++ {
+		itemSize, itemPad, err := ParseTransactionBlockSize(blkWitness[pad:])
+		if err != nil || itemSize < 0 {
+			errMsg := fmt.Sprintf("can not parse size of witness item %d in parseWitness() function.\nerror: %v\n", i, err)
+			return nil, -1, errors.New(errMsg)
+		}
+		pad += itemPad
+		if len(blkWitness) < pad+int(itemSize) {
+			errMsg := fmt.Sprintf("witness item %d size (%d) is larger than the remaining tx block\n", i, itemSize)
+			return nil, -1, errors.New(errMsg)
+		}
+		witness = append(witness, blkWitness[pad:pad+int(itemSize)])
+		pad += int(itemSize)
+	}
+
+	return witness, pad, nil
+}
+
 /*
 parseTransactionBlockSize function is used in parseBlockTransactions function to parse the tx count in the transaction block.
 
@@ -592,46 +860,60 @@ Leading byte determines how many more bytes to read to figure out tx count; lead
 
 - if leading byte = 'ff' then parse next eight bytes
 
+The second value returned is the total number of bytes used by the compact size, including the leading byte.
+
 for more info read https://learnmeabitcoin.com/technical/general/compact-size/
 */
 func ParseTransactionBlockSize(blkTranSize []byte) (int64, int, error) {
-	leadingByte := strings.ToUpper(fmt.Sprintf("%x", blkTranSize[0]))
-	// fmt.Printf("leading byte: %v\n", leadingByte)
-	if leadingByte <= "FC" && leadingByte >= "0" {
-		txCount, err := strconv.ParseInt(leadingByte, 16, 16)
-		if err != nil {
-			errMsg := fmt.Sprintf("can not swap TxCount bytes in parseTransactionBlockSize() function.\nerror: %v\n", err)
-			return -1, -1, errors.New(errMsg)
+	if len(blkTranSize) == 0 {
+		errMsg := fmt.Sprintln("can not read leading byte in parseTransactionBlockSize() function, no bytes left")
+		return int64(-1), -1, errors.New(errMsg)
+	}
+
+	// the number following the leading byte is little-endian
+	switch leadingByte := blkTranSize[0]; leadingByte {
+	case 0xfd:
+		if len(blkTranSize) < 3 {
+			return int64(-1), -1, errors.New(compactSizeShortMsg(len(blkTranSize), 3))
 		}
-		return txCount, 1, nil
-	} else if leadingByte == "FD" {
-		number := ByteSwapStr(fmt.Sprintf("%x", blkTranSize[1:3]))
-		txCount, err := strconv.ParseInt(number, 16, 64)
-		if err != nil {
-			errMsg := fmt.Sprintf("can not swap TxCount bytes in parseTransactionBlockSize() function.\nerror: %v\n", err)
-			return int64(-1), -1, errors.New(errMsg)
+		return int64(binary.LittleEndian.Uint16(blkTranSize[1:3])), 3, nil
+	case 0xfe:
+		if len(blkTranSize) < 5 {
+			return int64(-1), -1, errors.New(compactSizeShortMsg(len(blkTranSize), 5))
 		}
-		return txCount, 2, nil
-	} else if leadingByte == "FE" {
-		number := ByteSwapStr(fmt.Sprintf("%x", blkTranSize[1:5]))
-		txCount, err := strconv.ParseInt(number, 16, 64)
-		if err != nil {
-			errMsg := fmt.Sprintf("can not swap TxCount bytes in parseTransactionBlockSize() function.\nerror: %v\n", err)
-			return int64(-1), -1, errors.New(errMsg)
+		return int64(binary.LittleEndian.Uint32(blkTranSize[1:5])), 5, nil
+	case 0xff:
+		if len(blkTranSize) < 9 {
+			return int64(-1), -1, errors.New(compactSizeShortMsg(len(blkTranSize), 9))
 		}
-		return txCount, 4, nil
-	} else if leadingByte == "FF" {
-		number := ByteSwapStr(fmt.Sprintf("%x", blkTranSize[1:9]))
-		txCount, err := strconv.ParseInt(number, 16, 64)
-		if err != nil {
-			errMsg := fmt.Sprintf("can not swap TxCount bytes in parseTransactionBlockSize() function.\nerror: %v\n", err)
+		txCount := binary.LittleEndian.Uint64(blkTranSize[1:9])
+		if txCount > math.MaxInt64 {
+			errMsg := fmt.Sprintf("compact size %d does not fit in an int64 in parseTransactionBlockSize() function\n", txCount)
 			return int64(-1), -1, errors.New(errMsg)
 		}
-		return txCount, 8, nil
-	} else {
-		errMsg := fmt.Sprintln("did not expect to return outside of if statement in parseTransactionBlockSize() function. leading byte does not match anything in table from https://learnmeabitcoin.com/technical/general/compact-size/#structure")
-		return int64(-1), -1, errors.New(errMsg)
+		return int64(txCount), 9, nil
+	default:
+		return int64(leadingByte), 1, nil
+	}
+}
+
+func compactSizeShortMsg(got int, want int) string {
+	return fmt.Sprintf("compact size needs %d bytes but only %d bytes are left in parseTransactionBlockSize() function\n", want, got)
+}
+
+/*
+reversedUpperHex function returns the little-endian number b as big-endian upper case hex, the same as
+ByteSwapStr(fmt.Sprintf("%X", b)).
+*/
+func reversedUpperHex(b []byte) string {
+	const digits = "0123456789ABCDEF"
+	var sb strings.Builder
+	sb.Grow(2 * len(b))
+	for i := len(b) - 1; i >= 0; i-- {
+		sb.WriteByte(digits[b[i]>>4])
+		sb.WriteByte(digits[b[i]&0x0f])
 	}
+	return sb.String()
 }
 
 // Output a single blocks details to the terminal.
@@ -1,10 +1,14 @@
 package bparser_test
 
 import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/davidhintelmann/blockchain/bparser"
@@ -73,3 +77,169 @@ func BenchmarkParseBlk(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkParseHeaders(b *testing.B) {
+	if testing.Short() {
+		b.Skip("skipping benchmark in short mode.")
+	}
+
+	matches, err := filepath.Glob(blocksFilePath + "*.dat")
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	for i := 0; i < b.N; i++ {
+		file, err := os.Open(matches[0])
+		if err != nil {
+			log.Fatalf("error: %v\n", err)
+		}
+		defer file.Close()
+
+		_, err = bparser.ParseHeaders(file, &bparser.MainNet)
+		if err != nil {
+			log.Fatalf("error: %v\n", err)
+		}
+	}
+}
+
+/*
+syntheticBlock function returns a mainnet dat file record with a segwit coinbase followed by txCount txs, alternating between
+a legacy tx with two P2PKH outputs and the BIP143 segwit tx, so the decoder can be benchmarked without blk files on disk.
+*/
+func syntheticBlock(b *testing.B, txCount int) []byte {
+	b.Helper()
+	segWitTx, err := hex.DecodeString(segWitTxHex)
+	if err != nil {
+		b.Fatalf("can not decode segwit tx hex\nerror: %v\n", err)
+	}
+
+	// the coinbase wtxid is replaced by zeros in the witness commitment
+	txs := [][]byte{nil}
+	wTxIds := []bparser.Hash{{}}
+	for i := 0; i < txCount; i++ {
+		tx := segWitTx
+		if i%2 == 0 {
+			tx = testTx(bparser.MainNet.GenesisHash, uint32(i), byte(i), testOutput(uint64(i), p2pkhScript), testOutput(5_000_000_000, p2pkhScript))
+		}
+		parsed, err := bparser.ParseBlockTx(tx, 0)
+		if err != nil {
+			b.Fatalf("ParseBlockTx() returned error\nerror: %v\n", err)
+		}
+		txs = append(txs, tx)
+		wTxIds = append(wTxIds, parsed.WTxId)
+	}
+	witnessRoot := bparser.MerkleRoot(wTxIds)
+	txs[0] = segWitCoinbase(doubleSha256(append(witnessRoot[:], make([]byte, 32)...)))
+
+	txIds := make([]bparser.Hash, 0, len(txs))
+	for _, tx := range txs {
+		parsed, _ := bparser.ParseBlockTx(tx, 0)
+		txIds = append(txIds, parsed.TxId)
+	}
+	root := bparser.MerkleRoot(txIds)
+
+	header := testHeader(bparser.MainNet.GenesisHash, 0)
+	body := append(header[:36:36], root[:]...)
+	body = append(body, header[68:]...)
+	body = append(body, 0xfd)
+	body = binary.LittleEndian.AppendUint16(body, uint16(len(txs)))
+	for _, tx := range txs {
+		body = append(body, tx...)
+	}
+
+	blk := []byte{249, 190, 180, 217}
+	blk = binary.LittleEndian.AppendUint32(blk, uint32(len(body)))
+	return append(blk, body...)
+}
+
+func BenchmarkParseSyntheticBlock(b *testing.B) {
+	blk := syntheticBlock(b, 2_000)
+	b.SetBytes(int64(len(blk)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := bparser.ParseBlock(blk, 0); err != nil {
+			b.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+		}
+	}
+}
+
+func BenchmarkParseBlockTx(b *testing.B) {
+	tx, err := hex.DecodeString(segWitTxHex)
+	if err != nil {
+		b.Fatalf("can not decode segwit tx hex\nerror: %v\n", err)
+	}
+	b.SetBytes(int64(len(tx)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := bparser.ParseBlockTx(tx, 0); err != nil {
+			b.Fatalf("ParseBlockTx() returned error\nerror: %v\n", err)
+		}
+	}
+}
+
+func BenchmarkParseTransactionBlockSize(b *testing.B) {
+	sizes := [][]byte{{1}, {253, 232, 3}, {254, 160, 134, 1, 0}, {255, 0, 228, 11, 84, 2, 0, 0, 0}}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := bparser.ParseTransactionBlockSize(sizes[i%len(sizes)]); err != nil {
+			b.Fatalf("ParseTransactionBlockSize() returned error\nerror: %v\n", err)
+		}
+	}
+}
+
+/*
+BenchmarkParseMainChainSmallBlocks reads a chain of 2,000 single tx blocks from an obfuscated blk file with one worker,
+so the cost of locating every block in its file is not hidden by the size of the blocks.
+*/
+func BenchmarkParseMainChainSmallBlocks(b *testing.B) {
+	data := slices.Clone(geneisBlockDec)
+	prev := bparser.MainNet.GenesisHash
+	for i := 1; i <= 2_000; i++ {
+		coinbase := testTx(bparser.Hash{}, 0, byte(i), testOutput(5_000_000_000, p2pkhScript))
+		blk := buildBlock(testHeader(prev, uint32(i)), coinbase)
+		block, err := bparser.ParseBlock(blk, i)
+		if err != nil {
+			b.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+		}
+		data = append(data, blk...)
+		prev = block.Header.BlockHash
+	}
+
+	dir := b.TempDir()
+	key := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	obfuscated := slices.Clone(data)
+	for i := range obfuscated {
+		obfuscated[i] ^= key[i%len(key)]
+	}
+	if err := os.WriteFile(filepath.Join(dir, "xor.dat"), key, 0o644); err != nil {
+		b.Fatalf("can not write xor.dat\nerror: %v\n", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "blk00000.dat"), obfuscated, 0o644); err != nil {
+		b.Fatalf("can not write blk00000.dat\nerror: %v\n", err)
+	}
+
+	blocksDir, err := bparser.OpenBlocksDir(dir)
+	if err != nil {
+		b.Fatalf("OpenBlocksDir() returned error\nerror: %v\n", err)
+	}
+	chain, err := bparser.BuildHeaderChain(blocksDir, &bparser.MainNet)
+	if err != nil {
+		b.Fatalf("BuildHeaderChain() returned error\nerror: %v\n", err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := bparser.ParseMainChain(context.Background(), blocksDir, chain, 0, 1, func(bparser.BlockData) error { return nil })
+		if err != nil {
+			b.Fatalf("ParseMainChain() returned error\nerror: %v\n", err)
+		}
+	}
+}
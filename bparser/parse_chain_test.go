@@ -1,6 +1,9 @@
 package bparser_test
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"testing"
 
@@ -12,7 +15,13 @@ import (
 // 	blk00000Height = 0
 // )
 
+// native P2WPKH example tx from BIP143, the second input has a witness stack of two items
+const segWitTxHex = "01000000000102fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f00000000494830450221008b9d1dc26ba6a9cb62127b02742fa9d754cd3bebf337f7a55d114c8e5cdd30be022040529b194ba3f9281a99f2b1c0a19c0489bc22ede944ccf4ecbab4cc618ef3ed01eeffffffef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a0100000000ffffffff02202cb206000000001976a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac9093510d000000001976a9143bde42dbee7e4dbe6a21b2d50ce2f0167faa815988ac000247304402203609e17b84f6a7d30c80bfa610b5b4542f32a8a0d5447a12fb1366d7f01cc44a0220573a954c4518331561406f90300e8f3358f51928d43c212a8caed02de67eebee0121025476c2e83188368da1ff3e292e7acafcdb3566bb0ad253f62fc70f07aeee635711000000"
+
 var (
+	// tx from block height 672,119 spending one P2PKH output, with its locktime changed to 672,119
+	secondTx = []byte{1, 0, 0, 0, 1, 59, 165, 212, 161, 9, 141, 155, 79, 44, 51, 107, 193, 189, 157, 137, 26, 146, 136, 243, 179, 89, 182, 137, 30, 118, 132, 21, 248, 36, 42, 30, 59, 1, 0, 0, 0, 107, 72, 48, 69, 2, 33, 0, 241, 77, 54, 196, 153, 187, 17, 32, 238, 11, 31, 180, 251, 105, 111, 28, 42, 42, 114, 222, 121, 224, 245, 29, 210, 143, 46, 224, 29, 161, 180, 246, 2, 32, 15, 35, 36, 53, 92, 213, 223, 136, 187, 39, 77, 166, 240, 141, 247, 93, 114, 12, 193, 143, 190, 225, 8, 69, 220, 206, 46, 253, 14, 141, 79, 166, 1, 33, 3, 161, 115, 190, 132, 127, 152, 90, 10, 217, 7, 87, 107, 209, 97, 144, 108, 177, 197, 85, 203, 128, 242, 80, 131, 34, 139, 23, 83, 88, 69, 184, 186, 255, 255, 255, 255, 2, 215, 37, 3, 0, 0, 0, 0, 0, 25, 118, 169, 20, 65, 160, 218, 69, 116, 194, 64, 156, 150, 113, 176, 36, 245, 207, 103, 118, 106, 249, 119, 134, 136, 172, 14, 73, 9, 0, 0, 0, 0, 0, 23, 169, 20, 203, 205, 60, 129, 136, 102, 212, 187, 36, 245, 189, 212, 99, 222, 23, 150, 52, 158, 121, 40, 135, 119, 65, 10, 0}
+
 	geneisBlockDec = []byte{249, 190, 180, 217, 29, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 59, 163, 237, 253, 122, 123, 18, 178, 122, 199, 44, 62, 103, 118, 143, 97, 127, 200, 27, 195, 136, 138, 81, 50, 58, 159, 184, 170, 75, 30, 94, 74, 41, 171, 95, 73, 255, 255, 0, 29, 29, 172, 43, 124, 1, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 255, 255, 255, 255, 77, 4, 255, 255, 0, 29, 1, 4, 69, 84, 104, 101, 32, 84, 105, 109, 101, 115, 32, 48, 51, 47, 74, 97, 110, 47, 50, 48, 48, 57, 32, 67, 104, 97, 110, 99, 101, 108, 108, 111, 114, 32, 111, 110, 32, 98, 114, 105, 110, 107, 32, 111, 102, 32, 115, 101, 99, 111, 110, 100, 32, 98, 97, 105, 108, 111, 117, 116, 32, 102, 111, 114, 32, 98, 97, 110, 107, 115, 255, 255, 255, 255, 1, 0, 242, 5, 42, 1, 0, 0, 0, 67, 65, 4, 103, 138, 253, 176, 254, 85, 72, 39, 25, 103, 241, 166, 113, 48, 183, 16, 92, 214, 168, 40, 224, 57, 9, 166, 121, 98, 224, 234, 31, 97, 222, 182, 73, 246, 188, 63, 76, 239, 56, 196, 243, 85, 4, 229, 30, 193, 18, 222, 92, 56, 77, 247, 186, 11, 141, 87, 138, 76, 112, 43, 107, 241, 29, 95, 172, 0, 0, 0, 0}
 )
 
@@ -61,10 +70,19 @@ func TestParseGenesis(t *testing.T) {
 	// fmt.Printf("Magic Number: %v\nBlock Size: %v\n", block.MagicNumber, block.Size)
 	// fmt.Printf("Version: %v\nPrev Block: %v\nMerkle Root: %v\nTimestamp: %v\nBits: %v\nNonce: %v\n", block.BlockHeader.Version, block.BlockHeader.PrevBlock, block.BlockHeader.MerkleRoot, block.BlockHeader.Timestamp, block.BlockHeader.Bits, block.BlockHeader.Nonce)
 
-	_, err = bparser.ParseBlock(geneisBlockDec, 0)
+	block, err := bparser.ParseBlock(geneisBlockDec, 0)
 	if err != nil {
 		t.Errorf("could not parse int, error: %v\n", err)
 	}
+
+	// genesis coinbase txid is the merkle root of the genesis block
+	if block.Tx.Tx[0].TxId != block.Header.MerkleRoot {
+		t.Errorf("Expected genesis txid to equal %s, but got %s\n", block.Header.MerkleRoot, block.Tx.Tx[0].TxId)
+	} else if block.Tx.Tx[0].WTxId != block.Tx.Tx[0].TxId {
+		t.Errorf("Expected genesis wtxid to equal txid %s, but got %s\n", block.Tx.Tx[0].TxId, block.Tx.Tx[0].WTxId)
+	} else if block.Weight != 285*4 || block.StrippedSize != 285 {
+		t.Errorf("Expected genesis weight %d and stripped size %d, but got %d and %d\n", 285*4, 285, block.Weight, block.StrippedSize)
+	}
 }
 
 func TestParseBlockSize(t *testing.T) {
@@ -98,31 +116,37 @@ func TestParseTransactionBlockSize(t *testing.T) {
 		name            string
 		transationBytes []byte
 		want            int64
+		wantPad         int
 	}{
 		{
 			name:            "genesis block size, leading byte <= FC",
 			transationBytes: []byte{01},
 			want:            int64(1),
+			wantPad:         1,
 		},
 		{
 			name:            "single byte - 252 leading byte <= FC",
 			transationBytes: []byte{252},
 			want:            int64(252),
+			wantPad:         1,
 		},
 		{
 			name:            "next two bytes - 253, 232, 03 leading byte == FD",
 			transationBytes: []byte{253, 232, 03},
 			want:            int64(1_000),
+			wantPad:         3,
 		},
 		{
 			name:            "next four bytes - 254, 160, 134, 01, 00 leading byte == FE",
 			transationBytes: []byte{254, 160, 134, 01, 00},
 			want:            int64(100_000),
+			wantPad:         5,
 		},
 		{
 			name:            "next eight bytes - 255, 00, 228, 11, 84, 02, 00, 00, 00 leading byte == FF",
 			transationBytes: []byte{255, 00, 228, 11, 84, 02, 00, 00, 00},
 			want:            int64(10_000_000_000),
+			wantPad:         9,
 		},
 	}
 
@@ -145,13 +169,34 @@ func TestParseTransactionBlockSize(t *testing.T) {
 	// test leading bytes from the table at https://learnmeabitcoin.com/technical/general/compact-size/#structure
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, _, _ := bparser.ParseTransactionBlockSize(tt.transationBytes)
+			got, gotPad, _ := bparser.ParseTransactionBlockSize(tt.transationBytes)
 			if got != tt.want {
 				t.Errorf("ParseTransactionBlockSize(blkTranSize:%v) got = %v, want %v", tt.transationBytes, got, tt.want)
+			} else if gotPad != tt.wantPad {
+				t.Errorf("ParseTransactionBlockSize(blkTranSize:%v) got pad = %v, want pad %v", tt.transationBytes, gotPad, tt.wantPad)
+			}
+		})
+	}
+
+	// truncated compact sizes must return an error instead of panicking
+	for _, tt := range tests {
+		if tt.wantPad == 1 {
+			continue
+		}
+		t.Run("truncated "+tt.name, func(t *testing.T) {
+			_, _, err := bparser.ParseTransactionBlockSize(tt.transationBytes[:tt.wantPad-1])
+			if err == nil {
+				t.Errorf("ParseTransactionBlockSize(blkTranSize:%v) expected an error for truncated bytes", tt.transationBytes[:tt.wantPad-1])
 			}
 		})
 	}
 
+	// compact sizes larger than an int64 must return an error instead of a negative count
+	overflow := []byte{255, 0, 0, 0, 0, 0, 0, 0, 128}
+	if got, _, err := bparser.ParseTransactionBlockSize(overflow); err == nil {
+		t.Errorf("ParseTransactionBlockSize(blkTranSize:%v) got = %v, expected an error for a count larger than an int64", overflow, got)
+	}
+
 }
 
 /*
@@ -173,17 +218,17 @@ func TestParseTransactionBlock(t *testing.T) {
 				InputCount: int64(1),
 				Inputs: []bparser.TxInputs{
 					{
-						TxId:          "0000000000000000000000000000000000000000000000000000000000000000",
-						Vout:          "FFFFFFFF",
+						TxId:          bparser.Hash{},
+						Vout:          0xffffffff,
 						ScriptSigSize: int64(77),
-						ScriptSig:     "04FFFF001D0104455468652054696D65732030332F4A616E2F32303039204368616E63656C6C6F72206F6E206272696E6B206F66207365636F6E64206261696C6F757420666F722062616E6B73",
-						Sequence:      "FFFFFFFF",
+						ScriptSig:     hexBytes("04FFFF001D0104455468652054696D65732030332F4A616E2F32303039204368616E63656C6C6F72206F6E206272696E6B206F66207365636F6E64206261696C6F757420666F722062616E6B73"),
+						Sequence:      0xffffffff,
 					},
 				},
 				OutputCount: int64(1),
 				Outputs: []bparser.TxOutputs{
 					{
-						Amount:           []byte{0, 242, 5, 42, 1, 0, 0, 0},
+						Amount:           5_000_000_000,
 						ScriptPubKeySize: int64(67),
 						ScriptPubKey:     []byte{65, 4, 103, 138, 253, 176, 254, 85, 72, 39, 25, 103, 241, 166, 113, 48, 183, 16, 92, 214, 168, 40, 224, 57, 9, 166, 121, 98, 224, 234, 31, 97, 222, 182, 73, 246, 188, 63, 76, 239, 56, 196, 243, 85, 4, 229, 30, 193, 18, 222, 92, 56, 77, 247, 186, 11, 141, 87, 138, 76, 112, 43, 107, 241, 29, 95, 172},
 					},
@@ -200,17 +245,17 @@ func TestParseTransactionBlock(t *testing.T) {
 				InputCount: int64(1),
 				Inputs: []bparser.TxInputs{
 					{
-						TxId:          "0000000000000000000000000000000000000000000000000000000000000000",
-						Vout:          "FFFFFFFF",
+						TxId:          bparser.Hash{},
+						Vout:          0xffffffff,
 						ScriptSigSize: int64(07),
-						ScriptSig:     "04FFFF001D0104",
-						Sequence:      "FFFFFFFF",
+						ScriptSig:     hexBytes("04FFFF001D0104"),
+						Sequence:      0xffffffff,
 					},
 				},
 				OutputCount: int64(1),
 				Outputs: []bparser.TxOutputs{
 					{
-						Amount:           []byte{0, 242, 5, 42, 1, 0, 0, 0},
+						Amount:           5_000_000_000,
 						ScriptPubKeySize: int64(67),
 						ScriptPubKey:     []byte{65, 4, 150, 181, 56, 232, 83, 81, 156, 114, 106, 44, 145, 230, 30, 193, 22, 0, 174, 19, 144, 129, 58, 98, 124, 102, 251, 139, 231, 148, 123, 230, 60, 82, 218, 117, 137, 55, 149, 21, 212, 224, 166, 4, 248, 20, 23, 129, 230, 34, 148, 114, 17, 102, 191, 98, 30, 115, 168, 44, 191, 35, 66, 200, 88, 238, 172},
 					},
@@ -227,22 +272,22 @@ func TestParseTransactionBlock(t *testing.T) {
 				InputCount: int64(1),
 				Inputs: []bparser.TxInputs{
 					{
-						TxId:          "3BA5D4A1098D9B4F2C336BC1BD9D891A9288F3B359B6891E768415F8242A1E3B",
-						Vout:          "01000000",
+						TxId:          bparser.MustParseHash("3b1e2a24f81584761e89b659b3f388921a899dbdc16b332c4f9b8d09a1d4a53b"),
+						Vout:          1,
 						ScriptSigSize: int64(107),
-						ScriptSig:     "483045022100F14D36C499BB1120EE0B1FB4FB696F1C2A2A72DE79E0F51DD28F2EE01DA1B4F602200F2324355CD5DF88BB274DA6F08DF75D720CC18FBEE10845DCCE2EFD0E8D4FA6012103A173BE847F985A0AD907576BD161906CB1C555CB80F25083228B17535845B8BA",
-						Sequence:      "FFFFFFFF",
+						ScriptSig:     hexBytes("483045022100F14D36C499BB1120EE0B1FB4FB696F1C2A2A72DE79E0F51DD28F2EE01DA1B4F602200F2324355CD5DF88BB274DA6F08DF75D720CC18FBEE10845DCCE2EFD0E8D4FA6012103A173BE847F985A0AD907576BD161906CB1C555CB80F25083228B17535845B8BA"),
+						Sequence:      0xffffffff,
 					},
 				},
 				OutputCount: int64(2),
 				Outputs: []bparser.TxOutputs{
 					{
-						Amount:           []byte{215, 37, 3, 0, 0, 0, 0, 0},
+						Amount:           206_295,
 						ScriptPubKeySize: int64(25),
 						ScriptPubKey:     []byte{118, 169, 20, 65, 160, 218, 69, 116, 194, 64, 156, 150, 113, 176, 36, 245, 207, 103, 118, 106, 249, 119, 134, 136, 172},
 					},
 					{
-						Amount:           []byte{14, 73, 9, 0, 0, 0, 0, 0},
+						Amount:           608_526,
 						ScriptPubKeySize: int64(23),
 						ScriptPubKey:     []byte{169, 20, 203, 205, 60, 129, 136, 102, 212, 187, 36, 245, 189, 212, 99, 222, 23, 150, 52, 158, 121, 40, 135},
 					},
@@ -274,20 +319,20 @@ func TestParseTransactionBlock(t *testing.T) {
 				if id != tt.want.Inputs[i].TxId {
 					t.Errorf("ParseBlockTx() got TxId = %s, want = TxId %s", id, tt.want.Inputs[i].TxId)
 				} else if vout != tt.want.Inputs[i].Vout {
-					t.Errorf("ParseBlockTx() got Vout = %s, want = Vout %s", vout, tt.want.Inputs[i].Vout)
+					t.Errorf("ParseBlockTx() got Vout = %d, want = Vout %d", vout, tt.want.Inputs[i].Vout)
 				} else if sigsize != tt.want.Inputs[i].ScriptSigSize {
 					t.Errorf("ParseBlockTx() got ScriptSigSize = %d, want = ScriptSigSize %d", sigsize, tt.want.Inputs[i].ScriptSigSize)
-				} else if sig != tt.want.Inputs[i].ScriptSig {
-					t.Errorf("ParseBlockTx() got ScriptSig = %s, want = ScriptSig %s", sig, tt.want.Inputs[i].ScriptSig)
+				} else if !bytes.Equal(sig, tt.want.Inputs[i].ScriptSig) {
+					t.Errorf("ParseBlockTx() got ScriptSig = %X, want = ScriptSig %X", sig, tt.want.Inputs[i].ScriptSig)
 				} else if seq != tt.want.Inputs[i].Sequence {
-					t.Errorf("ParseBlockTx() got Sequence = %s, want = Sequence %s", sig, tt.want.Inputs[i].Sequence)
+					t.Errorf("ParseBlockTx() got Sequence = %d, want = Sequence %d", seq, tt.want.Inputs[i].Sequence)
 				}
 			}
 
 			for i, field := range gotOutputs {
-				amount, sigsize, sig := bparser.ByteSwap(field.Amount), field.ScriptPubKeySize, bparser.ByteSwap(field.ScriptPubKey)
-				if amount != bparser.ByteSwap(tt.want.Outputs[i].Amount) {
-					t.Errorf("ParseBlockTx() got Amount = %s, want = Amount %s", amount, bparser.ByteSwap(tt.want.Outputs[i].Amount))
+				amount, sigsize, sig := field.Amount, field.ScriptPubKeySize, bparser.ByteSwap(field.ScriptPubKey)
+				if amount != tt.want.Outputs[i].Amount {
+					t.Errorf("ParseBlockTx() got Amount = %s, want = Amount %s", amount, tt.want.Outputs[i].Amount)
 				} else if sigsize != tt.want.Outputs[i].ScriptPubKeySize {
 					t.Errorf("ParseBlockTx() got ScriptPubKeySize = %d, want = ScriptPubKeySize %d", sigsize, tt.want.Outputs[i].ScriptPubKeySize)
 				} else if sig != bparser.ByteSwap(tt.want.Outputs[i].ScriptPubKey) {
@@ -298,6 +343,210 @@ func TestParseTransactionBlock(t *testing.T) {
 	}
 }
 
+/*
+buildBlock function returns a mainnet dat file record with the given header and txs, the merkle root of the header is replaced
+by the merkle root of txs.
+*/
+func buildBlock(header []byte, txs ...[]byte) []byte {
+	txIds := make([]bparser.Hash, 0, len(txs))
+	for _, tx := range txs {
+		parsed, _ := bparser.ParseBlockTx(tx, 0)
+		txIds = append(txIds, parsed.TxId)
+	}
+	root := bparser.MerkleRoot(txIds)
+
+	var body []byte
+	body = append(body, header[:36]...)
+	body = append(body, root[:]...)
+	body = append(body, header[68:]...)
+	body = append(body, byte(len(txs)))
+	for _, tx := range txs {
+		body = append(body, tx...)
+	}
+
+	blk := []byte{249, 190, 180, 217}
+	blk = binary.LittleEndian.AppendUint32(blk, uint32(len(body)))
+	return append(blk, body...)
+}
+
+/*
+hexBytes function decodes the hex fixture s.
+*/
+func hexBytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+/*
+test ParseBlock with a block holding more than one transaction
+*/
+func TestParseBlockTransactions(t *testing.T) {
+	// genesis coinbase tx followed by the tx from block height 672,119 with a non zero locktime
+	coinbaseTx := geneisBlockDec[89:]
+	blk := buildBlock(geneisBlockDec[8:88], coinbaseTx, secondTx)
+
+	block, err := bparser.ParseBlock(blk, 0)
+	if err != nil {
+		t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+	}
+
+	if block.Tx.TxCount != 2 || len(block.Tx.Tx) != 2 {
+		t.Fatalf("ParseBlock() got TxCount = %d and %d txs, want 2", block.Tx.TxCount, len(block.Tx.Tx))
+	}
+
+	tests := []struct {
+		name     string
+		offset   int64
+		length   int64
+		outputs  int64
+		locktime string
+	}{
+		{name: "coinbase tx", offset: 81, length: int64(len(coinbaseTx)), outputs: 1, locktime: "00000000"},
+		{name: "second tx", offset: int64(81 + len(coinbaseTx)), length: int64(len(secondTx)), outputs: 2, locktime: "000A4177"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := block.Tx.Tx[i]
+			if tx.Offset != tt.offset {
+				t.Errorf("ParseBlock() got Offset = %d, want Offset = %d", tx.Offset, tt.offset)
+			} else if tx.Length != tt.length {
+				t.Errorf("ParseBlock() got Length = %d, want Length = %d", tx.Length, tt.length)
+			} else if tx.OutputCount != tt.outputs {
+				t.Errorf("ParseBlock() got OutputCount = %d, want OutputCount = %d", tx.OutputCount, tt.outputs)
+			} else if bparser.ByteSwap(tx.Locktime) != tt.locktime {
+				t.Errorf("ParseBlock() got Locktime = %s, want Locktime = %s", bparser.ByteSwap(tx.Locktime), tt.locktime)
+			}
+		})
+	}
+
+	// a tx count larger than the number of txs in the block must fail
+	blk[88] = 3
+	if _, err := bparser.ParseBlock(blk, 0); err == nil {
+		t.Errorf("ParseBlock() expected an error when tx count is larger than the number of txs")
+	}
+}
+
+/*
+test ParseBlockSizeFunc, ParseBlockRaw and ParseBlockStr with a block larger than 32 KiB
+*/
+func TestParseLargeBlock(t *testing.T) {
+	// coinbase tx with 200 OP_RETURN outputs of 200 bytes each
+	script := append([]byte{0x6a, 0x4c, 197}, make([]byte, 197)...)
+	outputs := make([][]byte, 200)
+	for i := range outputs {
+		outputs[i] = testOutput(0, script)
+	}
+	blk := buildBlock(testHeader(bparser.Hash{}, 0), testTx(bparser.Hash{}, 0, 1, outputs...))
+
+	blockSize, err := bparser.ParseBlockSizeFunc(blk)
+	if err != nil {
+		t.Fatalf("ParseBlockSizeFunc() returned error\nerror: %v\n", err)
+	}
+	if blockSize != int64(len(blk)-8) || blockSize <= 32768 {
+		t.Fatalf("ParseBlockSizeFunc() got block size = %d, want %d", blockSize, len(blk)-8)
+	}
+
+	raw, err := bparser.ParseBlockRaw(blk)
+	if err != nil {
+		t.Fatalf("ParseBlockRaw() returned error\nerror: %v\n", err)
+	}
+	if len(raw.Tx.TxId) != len(blk)-90 {
+		t.Errorf("ParseBlockRaw() got %d bytes of txs, want %d", len(raw.Tx.TxId), len(blk)-90)
+	}
+
+	if _, err := bparser.ParseBlockStr(blk); err != nil {
+		t.Errorf("ParseBlockStr() returned error\nerror: %v\n", err)
+	}
+
+	// a block size larger than the bytes passed must fail
+	if _, err := bparser.ParseBlockRaw(blk[:len(blk)-1]); err == nil {
+		t.Errorf("ParseBlockRaw() expected an error when the block is truncated")
+	}
+	if _, err := bparser.ParseBlockSizeFunc(blk[:6]); err == nil {
+		t.Errorf("ParseBlockSizeFunc() expected an error for fewer than 8 bytes")
+	}
+}
+
+/*
+test ParseBlockTx function with a BIP144 segwit tx
+*/
+func TestParseSegWitTransaction(t *testing.T) {
+	raw, err := hex.DecodeString(segWitTxHex)
+	if err != nil {
+		t.Fatalf("can not decode segwit tx hex\nerror: %v\n", err)
+	}
+
+	tx, err := bparser.ParseBlockTx(raw, 0)
+	if err != nil {
+		t.Fatalf("ParseBlockTx() returned error\nerror: %v\n", err)
+	}
+
+	if !tx.SegWit {
+		t.Errorf("ParseBlockTx() got SegWit = false, want SegWit = true")
+	} else if tx.InputCount != 2 || tx.OutputCount != 2 {
+		t.Errorf("ParseBlockTx() got InputCount = %d and OutputCount = %d, want 2 and 2", tx.InputCount, tx.OutputCount)
+	} else if tx.Length != int64(len(raw)) {
+		t.Errorf("ParseBlockTx() got Length = %d, want Length = %d", tx.Length, len(raw))
+	} else if bparser.ByteSwap(tx.Locktime) != "00000011" {
+		t.Errorf("ParseBlockTx() got Locktime = %s, want Locktime = %s", bparser.ByteSwap(tx.Locktime), "00000011")
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{name: "TxId", got: tx.TxId, want: bparser.MustParseHash("e8151a2af31c368a35053ddd4bdb285a8595c769a3ad83e0fa02314a602d4609")},
+		{name: "WTxId", got: tx.WTxId, want: bparser.MustParseHash("c36c38370907df2324d9ce9d149d191192f338b37665a82e78e76a12c909b762")},
+		{name: "Size", got: tx.Size, want: int64(343)},
+		{name: "StrippedSize", got: tx.StrippedSize, want: int64(233)},
+		{name: "Weight", got: tx.Weight, want: int64(1042)},
+		{name: "VSize", got: tx.VSize, want: int64(261)},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("ParseBlockTx() got %s = %v, want %s = %v", tt.name, tt.got, tt.name, tt.want)
+		}
+	}
+
+	if len(tx.Inputs[0].Witness) != 0 {
+		t.Errorf("ParseBlockTx() got %d witness items for input 0, want 0", len(tx.Inputs[0].Witness))
+	} else if len(tx.Inputs[1].Witness) != 2 {
+		t.Fatalf("ParseBlockTx() got %d witness items for input 1, want 2", len(tx.Inputs[1].Witness))
+	} else if len(tx.Inputs[1].Witness[0]) != 71 || len(tx.Inputs[1].Witness[1]) != 33 {
+		t.Errorf("ParseBlockTx() got witness item sizes %d and %d, want 71 and 33", len(tx.Inputs[1].Witness[0]), len(tx.Inputs[1].Witness[1]))
+	}
+
+	if !bytes.Equal(tx.Serialize(), raw) {
+		t.Errorf("Serialize() does not return the raw tx bytes")
+	}
+
+	// legacy serialization drops the marker, flag and witness data
+	legacy := tx.SerializeNoWitness()
+	if len(legacy) != 233 {
+		t.Fatalf("SerializeNoWitness() got %d bytes, want 233", len(legacy))
+	}
+	legacyTx, err := bparser.ParseBlockTx(legacy, 0)
+	if err != nil {
+		t.Fatalf("ParseBlockTx() on legacy serialization returned error\nerror: %v\n", err)
+	} else if legacyTx.SegWit {
+		t.Errorf("ParseBlockTx() on legacy serialization got SegWit = true")
+	} else if !bytes.Equal(legacyTx.SerializeNoWitness(), legacy) {
+		t.Errorf("SerializeNoWitness() of a legacy tx does not return the raw tx bytes")
+	}
+
+	// marker without the 0x01 flag is not a valid tx
+	bad := bytes.Clone(raw)
+	bad[5] = 2
+	if _, err := bparser.ParseBlockTx(bad, 0); err == nil {
+		t.Errorf("ParseBlockTx() expected an error for a segwit flag that is not 0x01")
+	}
+}
+
 func ExampleByteSwap() {
 	str := bparser.ByteSwapStr("6FE28C0AB6F1B372C1A6A246AE63F74F931E8365E15A089C68D6190000000000")
 	fmt.Println(str)
@@ -0,0 +1,205 @@
+package bparser
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+)
+
+var (
+	// oneLsh256 is 2^256, used to calculate the work of a target
+	oneLsh256 = new(big.Int).Lsh(big.NewInt(1), 256)
+)
+
+/*
+CompactToBig function converts the compact representation of a target, as stored in the Bits field of a block header, into a big integer.
+
+The compact format is a 1 byte exponent followed by a 3 byte mantissa with a sign bit, target = mantissa * 256^(exponent-3).
+*/
+func CompactToBig(bits uint32) *big.Int {
+	mantissa := bits & 0x007fffff
+	negative := bits&0x00800000 != 0
+	exponent := uint(bits >> 24)
+
+	var target *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		target = big.NewInt(int64(mantissa))
+	} else {
+		target = big.NewInt(int64(mantissa))
+		target.Lsh(target, 8*(exponent-3))
+	}
+
+	if negative {
+		target.Neg(target)
+	}
+
+	return target
+}
+
+/*
+CalcWork function returns the expected number of hashes needed to find a block with the given bits, 2^256 / (target + 1).
+*/
+func CalcWork(bits uint32) *big.Int {
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+
+	denominator := new(big.Int).Add(target, big.NewInt(1))
+	return new(big.Int).Div(oneLsh256, denominator)
+}
+
+/*
+BigToCompact function converts a target into the compact representation stored in the Bits field of a block header,
+the reverse of CompactToBig. Precision beyond the 3 byte mantissa is lost.
+*/
+func BigToCompact(n *big.Int) uint32 {
+	if n.Sign() == 0 {
+		return 0
+	}
+
+	abs := new(big.Int).Abs(n)
+	exponent := uint(len(abs.Bytes()))
+	var mantissa uint32
+	if exponent <= 3 {
+		mantissa = uint32(abs.Uint64()) << (8 * (3 - exponent))
+	} else {
+		mantissa = uint32(new(big.Int).Rsh(abs, 8*(exponent-3)).Uint64())
+	}
+
+	// the sign bit is part of the mantissa, so move a set high bit into the exponent
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+
+	bits := uint32(exponent<<24) | mantissa
+	if n.Sign() < 0 {
+		bits |= 0x00800000
+	}
+	return bits
+}
+
+/*
+CalcDifficulty function returns the difficulty of bits, how many times harder the target is to reach than the target of
+bits 0x1d00ffff. This is the difficulty shown by bitcoin-core and block explorers for every network.
+*/
+func CalcDifficulty(bits uint32) float64 {
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return 0
+	}
+
+	difficulty, _ := new(big.Float).Quo(new(big.Float).SetInt(CompactToBig(0x1d00ffff)), new(big.Float).SetInt(target)).Float64()
+	return difficulty
+}
+
+/*
+CheckProofOfWork function returns an error when the target of bits is not between 1 and the proof of work limit of net,
+or when hash is larger than the target.
+*/
+func CheckProofOfWork(hash Hash, bits uint32, net *Network) error {
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 || target.Cmp(CompactToBig(net.PowLimitBits)) > 0 {
+		errMsg := fmt.Sprintf("bits %08x of block %s are not between 1 and the proof of work limit %08x of %s in CheckProofOfWork() function\n", bits, hash, net.PowLimitBits, net.Name)
+		return errors.New(errMsg)
+	}
+
+	if HashToBig(hash).Cmp(target) > 0 {
+		errMsg := fmt.Sprintf("block hash %s is above the target of bits %08x in CheckProofOfWork() function\n", hash, bits)
+		return errors.New(errMsg)
+	}
+
+	return nil
+}
+
+/*
+NextWorkRequired method returns the bits a block building on prev with the given timestamp must have, following bitcoin-core's
+GetNextWorkRequired. Build must be called first.
+
+The target changes every RetargetInterval blocks, scaled by the time the previous interval took, limited to a factor of 4
+either way. Between retargets the bits stay the same, except on networks allowing min difficulty blocks where a block more than
+twice the target spacing after prev may use the proof of work limit.
+*/
+func (c *HeaderChain) NextWorkRequired(prev *ChainEntry, timestamp int64) uint32 {
+	interval := c.net.RetargetInterval()
+	if (prev.Height+1)%interval != 0 {
+		if c.net.PowAllowMinDifficultyBlocks {
+			if timestamp > prev.Header.TimestampUnix+c.net.PowTargetSpacing*2 {
+				return c.net.PowLimitBits
+			}
+			// bits of the last block in the interval which was not a min difficulty block
+			return prev.periodBits
+		}
+		return prev.Bits
+	}
+
+	if c.net.PowNoRetargeting {
+		return prev.Bits
+	}
+
+	first := c.ancestor(prev, prev.Height-(interval-1))
+	timespan := prev.Header.TimestampUnix - first.Header.TimestampUnix
+	timespan = max(timespan, c.net.PowTargetTimespan/4)
+	timespan = min(timespan, c.net.PowTargetTimespan*4)
+
+	// BIP94 uses the first block of the interval, which can not be a min difficulty block
+	target := CompactToBig(prev.Bits)
+	if c.net.EnforceBIP94 {
+		target = CompactToBig(first.Bits)
+	}
+	target.Mul(target, big.NewInt(timespan))
+	target.Div(target, big.NewInt(c.net.PowTargetTimespan))
+
+	if powLimit := CompactToBig(c.net.PowLimitBits); target.Cmp(powLimit) > 0 {
+		target = powLimit
+	}
+	return BigToCompact(target)
+}
+
+/*
+VerifyHeaderChain function checks the proof of work of every block linked to genesis, and that its bits match the bits
+required by NextWorkRequired. Blocks are checked in height order and the first failure is returned.
+*/
+func VerifyHeaderChain(chain *HeaderChain) error {
+	entries := make([]*ChainEntry, 0, len(chain.entries))
+	for _, entry := range chain.entries {
+		if entry.Height >= 0 {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return errors.New("header chain has not been built in VerifyHeaderChain() function")
+	}
+	slices.SortFunc(entries, func(a, b *ChainEntry) int {
+		return cmp.Or(cmp.Compare(a.Height, b.Height), cmp.Compare(a.seq, b.seq))
+	})
+
+	for _, entry := range entries {
+		if err := CheckProofOfWork(entry.Header.BlockHash, entry.Bits, chain.net); err != nil {
+			errMsg := fmt.Sprintf("invalid proof of work at height %d in VerifyHeaderChain() function.\nerror: %v\n", entry.Height, err)
+			return errors.New(errMsg)
+		}
+		if entry.parent == nil {
+			continue
+		}
+
+		if want := chain.NextWorkRequired(entry.parent, entry.Header.TimestampUnix); entry.Bits != want {
+			errMsg := fmt.Sprintf("block %s at height %d has bits %08x but %08x are required in VerifyHeaderChain() function\n", entry.Header.BlockHash, entry.Height, entry.Bits, want)
+			return errors.New(errMsg)
+		}
+	}
+
+	return nil
+}
+
+/*
+HashToBig function returns hash as a number, reading it in display order so that it can be compared with a target.
+*/
+func HashToBig(hash Hash) *big.Int {
+	hash.reverse()
+	return new(big.Int).SetBytes(hash[:])
+}
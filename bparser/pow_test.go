@@ -0,0 +1,189 @@
+package bparser_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+func TestCompact(t *testing.T) {
+	tests := []struct {
+		bits   uint32
+		target string
+	}{
+		{bits: 0x1d00ffff, target: "ffff0000000000000000000000000000000000000000000000000000"},
+		{bits: 0x1b0404cb, target: "404cb000000000000000000000000000000000000000000000000"},
+		{bits: 0x207fffff, target: "7fffff0000000000000000000000000000000000000000000000000000000000"},
+		{bits: 0x02008000, target: "80"},
+		{bits: 0x01120000, target: "12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%08x", tt.bits), func(t *testing.T) {
+			target := bparser.CompactToBig(tt.bits)
+			if target.Text(16) != tt.target {
+				t.Errorf("CompactToBig() got = %s, want %s", target.Text(16), tt.target)
+			} else if bits := bparser.BigToCompact(target); bits != tt.bits {
+				t.Errorf("BigToCompact() got = %08x, want %08x", bits, tt.bits)
+			}
+		})
+	}
+
+	if bits := bparser.BigToCompact(big.NewInt(0)); bits != 0 {
+		t.Errorf("BigToCompact(0) got = %08x, want 0", bits)
+	} else if bits := bparser.BigToCompact(big.NewInt(-0x12345)); bits != 0x03812345 {
+		t.Errorf("BigToCompact(-0x12345) got = %08x, want 03812345", bits)
+	}
+}
+
+func TestCalcDifficulty(t *testing.T) {
+	tests := []struct {
+		bits       uint32
+		difficulty float64
+	}{
+		{bits: 0x1d00ffff, difficulty: 1},
+		{bits: 0x1b0404cb, difficulty: 16307.420938523983},
+		{bits: 0, difficulty: 0},
+	}
+
+	for _, tt := range tests {
+		if got := bparser.CalcDifficulty(tt.bits); got != tt.difficulty {
+			t.Errorf("CalcDifficulty(%08x) got = %v, want %v", tt.bits, got, tt.difficulty)
+		}
+	}
+
+	block, err := bparser.ParseBlock(geneisBlockDec, 0)
+	if err != nil {
+		t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+	} else if block.Header.Difficulty != 1 {
+		t.Errorf("ParseBlock() got Difficulty = %v, want 1", block.Header.Difficulty)
+	}
+}
+
+func TestCheckProofOfWork(t *testing.T) {
+	tests := []struct {
+		name    string
+		bits    uint32
+		wantErr bool
+	}{
+		{name: "genesis bits", bits: 0x1d00ffff},
+		{name: "hash above target", bits: 0x1b00ffff, wantErr: true},
+		{name: "target above limit", bits: 0x1d01ffff, wantErr: true},
+		{name: "negative target", bits: 0x1d80ffff, wantErr: true},
+		{name: "zero target", bits: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bparser.CheckProofOfWork(bparser.MainNet.GenesisHash, tt.bits, &bparser.MainNet)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckProofOfWork() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+/*
+mineHeader function returns a header building on prev which meets the target of bits.
+*/
+func mineHeader(prev bparser.Hash, timestamp int64, bits uint32) bparser.BlockHeaderData {
+	target := bparser.CompactToBig(bits)
+	for nonce := uint32(0); ; nonce++ {
+		header := binary.LittleEndian.AppendUint32(nil, 4)
+		header = append(header, prev[:]...)
+		header = append(header, make([]byte, 32)...)
+		header = binary.LittleEndian.AppendUint32(header, uint32(timestamp))
+		header = binary.LittleEndian.AppendUint32(header, bits)
+		header = binary.LittleEndian.AppendUint32(header, nonce)
+
+		hash := bparser.Hash(doubleSha256(header))
+		if bparser.HashToBig(hash).Cmp(target) <= 0 {
+			return bparser.BlockHeaderData{
+				BlockHash:     hash,
+				PrevBlock:     prev,
+				TimestampUnix: timestamp,
+				Bits:          bits,
+				Nonce:         int64(nonce),
+			}
+		}
+	}
+}
+
+func TestVerifyHeaderChain(t *testing.T) {
+	// a network with the regtest proof of work limit which retargets every 4 blocks
+	net := bparser.MainNet
+	net.PowLimitBits = 0x207fffff
+	net.PowTargetTimespan = 4 * 600
+
+	minDifficulty := net
+	minDifficulty.PowAllowMinDifficultyBlocks = true
+
+	// blocks 1 to 3 take 300 seconds so the target at height 4 is 900 / 2400 of the limit
+	type block struct {
+		timestamp int64
+		bits      uint32
+	}
+	normal := []block{{0, 0x207fffff}, {300, 0x207fffff}, {600, 0x207fffff}, {900, 0x207fffff}, {1200, 0x202fffff}, {1500, 0x202fffff}}
+	// block 5 is more than 20 minutes after block 4 so it may use the limit, block 6 goes back to the retarget bits
+	minDiff := []block{{0, 0x207fffff}, {300, 0x207fffff}, {600, 0x207fffff}, {900, 0x207fffff}, {1200, 0x202fffff}, {2500, 0x207fffff}, {2600, 0x202fffff}}
+
+	tests := []struct {
+		name    string
+		net     bparser.Network
+		blocks  []block
+		wantErr bool
+	}{
+		{name: "retarget", net: net, blocks: normal},
+		{name: "min difficulty block", net: minDifficulty, blocks: minDiff},
+		{name: "min difficulty not allowed", net: net, blocks: minDiff, wantErr: true},
+		{name: "no retarget", net: net, blocks: append(slices.Clone(normal[:4]), block{1200, 0x207fffff}), wantErr: true},
+		{name: "retarget bits too low", net: net, blocks: append(slices.Clone(normal[:4]), block{1200, 0x201fffff}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			genesis := mineHeader(bparser.Hash{}, tt.blocks[0].timestamp, tt.blocks[0].bits)
+			tt.net.GenesisHash = genesis.BlockHash
+
+			chain := bparser.NewHeaderChain(&tt.net)
+			prev := genesis
+			chain.Add(genesis, 0, 0)
+			for _, b := range tt.blocks[1:] {
+				prev = mineHeader(prev.BlockHash, b.timestamp, b.bits)
+				if err := chain.Add(prev, 0, 0); err != nil {
+					t.Fatalf("Add() returned error\nerror: %v\n", err)
+				}
+			}
+			if err := chain.Build(); err != nil {
+				t.Fatalf("Build() returned error\nerror: %v\n", err)
+			}
+
+			err := bparser.VerifyHeaderChain(chain)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyHeaderChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// a block whose hash does not meet its target
+	chain := bparser.NewHeaderChain(&net)
+	genesis := mineHeader(bparser.Hash{}, 0, 0x207fffff)
+	net.GenesisHash = genesis.BlockHash
+	chain.Add(genesis, 0, 0)
+	aboveTarget := genesis.BlockHash
+	aboveTarget[31] = 0xff
+	chain.Add(bparser.BlockHeaderData{BlockHash: aboveTarget, PrevBlock: genesis.BlockHash, Bits: 0x207fffff}, 0, 0)
+	if err := chain.Build(); err != nil {
+		t.Fatalf("Build() returned error\nerror: %v\n", err)
+	} else if err := bparser.VerifyHeaderChain(chain); err == nil {
+		t.Errorf("VerifyHeaderChain() expected an error for a hash above the target")
+	}
+
+	if entry, _ := chain.AtHeight(0); entry.Bits != 0x207fffff || entry.Target.Cmp(bparser.CompactToBig(0x207fffff)) != 0 {
+		t.Errorf("AtHeight(0) got Bits = %08x and Target = %x", entry.Bits, entry.Target)
+	}
+}
@@ -0,0 +1,56 @@
+package bparser
+
+import "github.com/davidhintelmann/blockchain/bparser/script"
+
+// annexTag is the first byte of the optional last witness item of a taproot input, see BIP341
+const annexTag = 0x50
+
+/*
+ScriptSigASM method returns the scriptSig of the input as bitcoin-core style ASM with signature sighash types decoded, see script.SigASM.
+*/
+func (in TxInputs) ScriptSigASM() string {
+	return script.SigASM(in.ScriptSig)
+}
+
+/*
+WitnessScript method returns the script executed from the witness of the input, the last witness item when spending a P2WSH output
+or the tapscript when spending a P2TR output by script path. It is nil for any other input and when PrevOut has not been attached,
+since the witness alone does not tell which kind of output is spent.
+*/
+func (in TxInputs) WitnessScript() []byte {
+	if in.PrevOut == nil || len(in.Witness) == 0 {
+		return nil
+	}
+
+	version, program, ok := script.IsWitnessProgram(in.PrevOut.ScriptPubKey)
+	switch {
+	case !ok:
+		return nil
+	case version == 0 && len(program) == 32:
+		return in.Witness[len(in.Witness)-1]
+	case version == 1 && len(program) == 32:
+		stack := in.Witness
+		if len(stack) >= 2 && len(stack[len(stack)-1]) > 0 && stack[len(stack)-1][0] == annexTag {
+			stack = stack[:len(stack)-1]
+		}
+		// a key path spend has only the signature left, a script path spend ends with the script and control block
+		if len(stack) >= 2 {
+			return stack[len(stack)-2]
+		}
+	}
+	return nil
+}
+
+/*
+WitnessScriptASM method returns WitnessScript as bitcoin-core style ASM, or an empty string when the input has no witness script.
+*/
+func (in TxInputs) WitnessScriptASM() string {
+	return script.ASM(in.WitnessScript())
+}
+
+/*
+ScriptPubKeyASM method returns the scriptPubKey of the output as bitcoin-core style ASM, see script.ASM.
+*/
+func (out TxOutputs) ScriptPubKeyASM() string {
+	return script.ASM(out.ScriptPubKey)
+}
@@ -0,0 +1,149 @@
+package script
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	bech32Alphabet = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	// constants the bech32 and bech32m checksums are xor'ed with, see BIP173 and BIP350
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+/*
+EncodeBase58Check function encodes a legacy address, the version byte and payload followed by the first 4 bytes
+of their double sha256, in base58. Leading zero bytes are written as '1'.
+*/
+func EncodeBase58Check(version byte, payload []byte) string {
+	// addresses have 20 byte payloads, so the scratch buffers only escape to the heap for unusually long payloads
+	var scratch [64]byte
+	b := append(scratch[:0], version)
+	b = append(b, payload...)
+	first := sha256.Sum256(b)
+	second := sha256.Sum256(first[:])
+	b = append(b, second[:4]...)
+
+	zeros := 0
+	for zeros < len(b) && b[zeros] == 0 {
+		zeros++
+	}
+
+	// each base58 digit holds log(256) / log(58) ~ 1.37 bytes, digits are kept big-endian as in bitcoin-core's EncodeBase58
+	size := (len(b)-zeros)*138/100 + 1
+	var digitScratch [96]byte
+	digits := digitScratch[:]
+	if size > len(digits) {
+		digits = make([]byte, size)
+	}
+	digits = digits[:size]
+	length := 0
+	for _, c := range b[zeros:] {
+		carry := int(c)
+		i := 0
+		for k := size - 1; k >= 0 && (carry != 0 || i < length); k, i = k-1, i+1 {
+			carry += 256 * int(digits[k])
+			digits[k] = byte(carry % 58)
+			carry /= 58
+		}
+		length = i
+	}
+
+	var sb strings.Builder
+	sb.Grow(zeros + length)
+	for range zeros {
+		sb.WriteByte('1')
+	}
+	for _, d := range digits[size-length:] {
+		sb.WriteByte(base58Alphabet[d])
+	}
+	return sb.String()
+}
+
+/*
+EncodeSegWitAddress function encodes a witness program as an address with the human readable part hrp,
+using bech32 for version 0 and bech32m for versions 1 to 16.
+*/
+func EncodeSegWitAddress(hrp string, version int, program []byte) (string, error) {
+	if version < 0 || version > 16 || len(program) < 2 || len(program) > 40 || (version == 0 && len(program) != 20 && len(program) != 32) {
+		errMsg := fmt.Sprintf("invalid witness program of version %d and %d bytes in EncodeSegWitAddress() function\n", version, len(program))
+		return "", errors.New(errMsg)
+	}
+
+	// the version is a single 5 bit group, followed by the program regrouped from 8 to 5 bits
+	data := make([]byte, 0, 1+(len(program)*8+4)/5)
+	data = append(data, byte(version))
+	acc, bits := 0, 0
+	for _, b := range program {
+		acc = acc<<8 | int(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			data = append(data, byte(acc>>bits)&31)
+		}
+	}
+	if bits > 0 {
+		data = append(data, byte(acc<<(5-bits))&31)
+	}
+
+	checksumConst := uint32(bech32Const)
+	if version > 0 {
+		checksumConst = bech32mConst
+	}
+
+	hrp = strings.ToLower(hrp)
+	var sb strings.Builder
+	sb.Grow(len(hrp) + 1 + len(data) + 6)
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, d := range data {
+		sb.WriteByte(bech32Alphabet[d])
+	}
+	for _, d := range bech32Checksum(hrp, data, checksumConst) {
+		sb.WriteByte(bech32Alphabet[d])
+	}
+	return sb.String(), nil
+}
+
+/*
+bech32Checksum function returns the 6 checksum groups of hrp and data, see BIP173.
+*/
+func bech32Checksum(hrp string, data []byte, checksumConst uint32) [6]byte {
+	values := make([]byte, 0, len(hrp)*2+1+len(data)+6)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]>>5)
+	}
+	values = append(values, 0)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]&31)
+	}
+	values = append(values, data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+
+	polymod := bech32Polymod(values) ^ checksumConst
+	var checksum [6]byte
+	for i := range checksum {
+		checksum[i] = byte(polymod>>(5*(5-i))) & 31
+	}
+	return checksum
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i, g := range generator {
+			if (top>>i)&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+	return chk
+}
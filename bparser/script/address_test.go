@@ -0,0 +1,72 @@
+package script_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser/script"
+)
+
+func hexString(b []byte) string {
+	return hex.EncodeToString(b)
+}
+
+func TestEncodeBase58Check(t *testing.T) {
+	tests := []struct {
+		version byte
+		payload string
+		want    string
+	}{
+		{0x00, "62e907b15cbf27d5425399ebf6f0fb50ebb88f18", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+		{0x00, "0000000000000000000000000000000000000000", "1111111111111111111114oLvT2"},
+		{0x6f, "62e907b15cbf27d5425399ebf6f0fb50ebb88f18", "mpXwg4jMtRhuSpVq4xS3HFHmCmWp9NyGKt"},
+	}
+
+	for _, test := range tests {
+		if got := script.EncodeBase58Check(test.version, decodeHex(t, test.payload)); got != test.want {
+			t.Errorf("EncodeBase58Check(%02x, %s) got = %s, want %s", test.version, test.payload, got, test.want)
+		}
+	}
+}
+
+/*
+test EncodeSegWitAddress function with the valid address vectors of BIP173 and BIP350
+*/
+func TestEncodeSegWitAddress(t *testing.T) {
+	tests := []struct {
+		hrp     string
+		version int
+		program string
+		want    string
+	}{
+		{"bc", 0, "751e76e8199196d454941c45d1b3a323f1433bd6", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{"tb", 0, "1863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7"},
+		{"bc", 1, "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+		{"bc", 16, "751e", "bc1sw50qgdz25j"},
+		{"bc", 2, "751e76e8199196d454941c45d1b3a323", "bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs"},
+		{"BC", 1, "4e73", "bc1pfeessrawgf"},
+	}
+
+	for _, test := range tests {
+		got, err := script.EncodeSegWitAddress(test.hrp, test.version, decodeHex(t, test.program))
+		if err != nil {
+			t.Errorf("EncodeSegWitAddress(%s, %d, %s)\nerror: %v\n", test.hrp, test.version, test.program, err)
+		} else if got != test.want {
+			t.Errorf("EncodeSegWitAddress(%s, %d, %s) got = %s, want %s", test.hrp, test.version, test.program, got, test.want)
+		}
+	}
+
+	invalid := []struct {
+		version int
+		program string
+	}{
+		{0, "751e76e8199196d454941c45d1b3a323"},
+		{17, "751e"},
+		{1, "75"},
+	}
+	for _, test := range invalid {
+		if _, err := script.EncodeSegWitAddress("bc", test.version, decodeHex(t, test.program)); err == nil {
+			t.Errorf("EncodeSegWitAddress(bc, %d, %s) expected an error", test.version, test.program)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package script
+
+/*
+Class type is the kind of an output script, as found by bitcoin-core's Solver. String returns the name bitcoin-core's RPCs
+use for scriptPubKey.type.
+*/
+type Class int
+
+const (
+	NonStandard Class = iota
+	PubKey
+	PubKeyHash
+	ScriptHash
+	MultiSig
+	NullData
+	WitnessV0KeyHash
+	WitnessV0ScriptHash
+	WitnessV1Taproot
+	Anchor
+	WitnessUnknown
+)
+
+var classNames = [...]string{
+	NonStandard:         "nonstandard",
+	PubKey:              "pubkey",
+	PubKeyHash:          "pubkeyhash",
+	ScriptHash:          "scripthash",
+	MultiSig:            "multisig",
+	NullData:            "nulldata",
+	WitnessV0KeyHash:    "witness_v0_keyhash",
+	WitnessV0ScriptHash: "witness_v0_scripthash",
+	WitnessV1Taproot:    "witness_v1_taproot",
+	Anchor:              "anchor",
+	WitnessUnknown:      "witness_unknown",
+}
+
+func (c Class) String() string {
+	if c < 0 || int(c) >= len(classNames) {
+		return classNames[NonStandard]
+	}
+	return classNames[c]
+}
+
+/*
+MarshalText method writes the class by name, so it is written as "pubkeyhash" rather than a number in JSON.
+*/
+func (c Class) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// anchorProgram is the witness v1 program of a pay to anchor output, OP_1 followed by a push of 0x4e73
+var anchorProgram = []byte{0x4e, 0x73}
+
+/*
+Classify function returns the class of an output script, checking the templates in the same order as bitcoin-core:
+
+	P2SH          OP_HASH160 <20 bytes> OP_EQUAL
+	witness       OP_0 to OP_16 followed by a push of 2 to 40 bytes, see IsWitnessProgram
+	OP_RETURN     OP_RETURN followed only by pushes
+	P2PK          <33 or 65 byte public key> OP_CHECKSIG
+	P2PKH         OP_DUP OP_HASH160 <20 bytes> OP_EQUALVERIFY OP_CHECKSIG
+	bare multisig OP_1 to OP_16 <public keys> OP_1 to OP_16 OP_CHECKMULTISIG
+
+A v0 witness program which is neither 20 nor 32 bytes is NonStandard, while any other witness version without a
+known template is WitnessUnknown.
+*/
+func Classify(script []byte) Class {
+	if isPayToScriptHash(script) {
+		return ScriptHash
+	}
+
+	if version, program, ok := IsWitnessProgram(script); ok {
+		switch {
+		case version == 0 && len(program) == 20:
+			return WitnessV0KeyHash
+		case version == 0 && len(program) == 32:
+			return WitnessV0ScriptHash
+		case version == 1 && len(program) == 32:
+			return WitnessV1Taproot
+		case version == 1 && string(program) == string(anchorProgram):
+			return Anchor
+		case version != 0:
+			return WitnessUnknown
+		}
+		return NonStandard
+	}
+
+	switch {
+	case len(script) > 0 && Opcode(script[0]) == OP_RETURN && isPushOnly(script[1:]):
+		return NullData
+	case isPayToPubKey(script):
+		return PubKey
+	case isPayToPubKeyHash(script):
+		return PubKeyHash
+	case isMultiSig(script):
+		return MultiSig
+	}
+	return NonStandard
+}
+
+/*
+PubKeys function returns the public keys of a P2PK or bare multisig script, and nil for any other script.
+*/
+func PubKeys(script []byte) [][]byte {
+	switch Classify(script) {
+	case PubKey:
+		return [][]byte{script[1 : len(script)-1]}
+	case MultiSig:
+		tokens, _ := Tokenize(script)
+		keys := make([][]byte, 0, len(tokens)-3)
+		for _, token := range tokens[1 : len(tokens)-2] {
+			keys = append(keys, token.Data)
+		}
+		return keys
+	}
+	return nil
+}
+
+func isPayToScriptHash(script []byte) bool {
+	return len(script) == 23 && Opcode(script[0]) == OP_HASH160 && script[1] == 20 && Opcode(script[22]) == OP_EQUAL
+}
+
+func isPayToPubKeyHash(script []byte) bool {
+	return len(script) == 25 && Opcode(script[0]) == OP_DUP && Opcode(script[1]) == OP_HASH160 && script[2] == 20 &&
+		Opcode(script[23]) == OP_EQUALVERIFY && Opcode(script[24]) == OP_CHECKSIG
+}
+
+func isPayToPubKey(script []byte) bool {
+	if len(script) != 35 && len(script) != 67 {
+		return false
+	}
+	return int(script[0]) == len(script)-2 && Opcode(script[len(script)-1]) == OP_CHECKSIG && validPubKeySize(script[1:len(script)-1])
+}
+
+/*
+isMultiSig function matches m <public keys> n OP_CHECKMULTISIG where n is the number of keys and m is at most n.
+*/
+func isMultiSig(script []byte) bool {
+	if len(script) == 0 || Opcode(script[len(script)-1]) != OP_CHECKMULTISIG {
+		return false
+	}
+
+	t := NewTokenizer(script)
+	if !t.Next() || t.Token().Op == OP_0 || !t.Token().Op.IsSmallInt() {
+		return false
+	}
+	required := t.Token().Op.SmallInt()
+
+	keys := 0
+	for t.Next() && validPubKeySize(t.Token().Data) {
+		keys++
+	}
+	op := t.Token().Op
+	if t.Err() != nil || op == OP_0 || !op.IsSmallInt() || op.SmallInt() != keys || keys < required {
+		return false
+	}
+	// only OP_CHECKMULTISIG may follow the number of keys
+	return t.Token().Offset+2 == len(script)
+}
+
+/*
+isPushOnly function reports whether script only pushes data, including OP_1NEGATE, OP_RESERVED and OP_1 to OP_16.
+*/
+func isPushOnly(script []byte) bool {
+	t := NewTokenizer(script)
+	for t.Next() {
+		if t.Token().Op > OP_16 {
+			return false
+		}
+	}
+	return t.Err() == nil
+}
+
+/*
+validPubKeySize function reports whether the length of pubKey matches its first byte, 33 bytes for compressed keys
+and 65 bytes for uncompressed and hybrid keys. The key itself is not checked to be on the curve.
+*/
+func validPubKeySize(pubKey []byte) bool {
+	if len(pubKey) == 0 {
+		return false
+	}
+	switch pubKey[0] {
+	case 0x02, 0x03:
+		return len(pubKey) == 33
+	case 0x04, 0x06, 0x07:
+		return len(pubKey) == 65
+	}
+	return false
+}
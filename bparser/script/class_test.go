@@ -0,0 +1,75 @@
+package script_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser/script"
+)
+
+const (
+	compressedKey   = "02" + "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	uncompressedKey = "04678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5f"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		class  script.Class
+	}{
+		{"p2pk uncompressed", "41" + uncompressedKey + "ac", script.PubKey},
+		{"p2pk compressed", "21" + compressedKey + "ac", script.PubKey},
+		{"p2pk wrong key prefix", "21" + "05" + compressedKey[2:] + "ac", script.NonStandard},
+		{"p2pkh", "76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac", script.PubKeyHash},
+		{"p2sh", "a914748284390f9e263a4b766a75d0633c50426eb87587", script.ScriptHash},
+		{"p2wpkh", "0014751e76e8199196d454941c45d1b3a323f1433bd6", script.WitnessV0KeyHash},
+		{"p2wsh", "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262", script.WitnessV0ScriptHash},
+		{"p2tr", "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", script.WitnessV1Taproot},
+		{"anchor", "51024e73", script.Anchor},
+		{"witness v2", "5210751e76e8199196d454941c45d1b3a323", script.WitnessUnknown},
+		{"witness v0 wrong size", "0010751e76e8199196d454941c45d1b3a323", script.NonStandard},
+		{"multisig 1 of 2", "51" + "21" + compressedKey + "41" + uncompressedKey + "52ae", script.MultiSig},
+		{"multisig more required than keys", "52" + "21" + compressedKey + "51ae", script.NonStandard},
+		{"multisig wrong key count", "51" + "21" + compressedKey + "52ae", script.NonStandard},
+		{"multisig 0 required", "00" + "21" + compressedKey + "51ae", script.NonStandard},
+		{"op_return", "6a", script.NullData},
+		{"op_return with data", "6a0b68656c6c6f20776f726c6451", script.NullData},
+		{"op_return with opcode", "6a51ac", script.NonStandard},
+		{"op_return malformed push", "6a05aabb", script.NonStandard},
+		{"empty", "", script.NonStandard},
+		{"true", "51", script.NonStandard},
+	}
+
+	for _, test := range tests {
+		if got := script.Classify(decodeHex(t, test.script)); got != test.class {
+			t.Errorf("Classify() %s got = %s, want %s", test.name, got, test.class)
+		}
+	}
+}
+
+func TestClassString(t *testing.T) {
+	if got, _ := script.WitnessV0KeyHash.MarshalText(); string(got) != "witness_v0_keyhash" {
+		t.Errorf("MarshalText() got = %s, want witness_v0_keyhash", got)
+	}
+	if got := script.Class(-1).String(); got != "nonstandard" {
+		t.Errorf("String() of an unknown class got = %s, want nonstandard", got)
+	}
+}
+
+func TestPubKeys(t *testing.T) {
+	multisig := decodeHex(t, "51"+"21"+compressedKey+"41"+uncompressedKey+"52ae")
+	keys := script.PubKeys(multisig)
+	if len(keys) != 2 || !bytes.Equal(keys[0], decodeHex(t, compressedKey)) || !bytes.Equal(keys[1], decodeHex(t, uncompressedKey)) {
+		t.Errorf("PubKeys() of multisig got = %X", keys)
+	}
+
+	keys = script.PubKeys(decodeHex(t, "41"+uncompressedKey+"ac"))
+	if len(keys) != 1 || hexString(keys[0]) != uncompressedKey {
+		t.Errorf("PubKeys() of p2pk got = %X", keys)
+	}
+
+	if keys := script.PubKeys(decodeHex(t, "51")); keys != nil {
+		t.Errorf("PubKeys() of non-standard got = %X, want nil", keys)
+	}
+}
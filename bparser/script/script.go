@@ -0,0 +1,292 @@
+/*
+Package script tokenizes bitcoin scripts, such as the scriptSig and witness of an input or the scriptPubKey of an output,
+and renders them as ASM in the format used by bitcoin-core's decoderawtransaction and getblock RPCs.
+
+Scripts found in the block chain are not always valid, a push may claim more bytes than are left in the script.
+Tokenize and Tokenizer return a *PushError for such a push, and ASM renders it as "[error]" as bitcoin-core does.
+*/
+package script
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+Token type is a single operation of a script. Data is the pushed data for OP_0 to OP_PUSHDATA4 and nil for any other
+opcode, Offset is the position of the opcode in the script.
+*/
+type Token struct {
+	Op     Opcode
+	Data   []byte
+	Offset int
+}
+
+/*
+PushError type is returned when a push opcode at Offset needs Want bytes, including the length of a OP_PUSHDATA push,
+but only Have bytes are left in the script.
+*/
+type PushError struct {
+	Op     Opcode
+	Offset int
+	Want   int
+	Have   int
+}
+
+func (e *PushError) Error() string {
+	return fmt.Sprintf("malformed push: opcode 0x%02x at offset %d needs %d bytes but only %d are left", byte(e.Op), e.Offset, e.Want, e.Have)
+}
+
+/*
+Tokenizer type reads the tokens of a script one at a time without allocating, Data of each token is a slice of the script.
+
+# Example
+
+	t := script.NewTokenizer(scriptPubKey)
+	for t.Next() {
+		fmt.Println(t.Token().Op)
+	}
+	if err := t.Err(); err != nil {
+		...
+	}
+*/
+type Tokenizer struct {
+	script []byte
+	pos    int
+	token  Token
+	err    error
+}
+
+/*
+NewTokenizer function returns a Tokenizer for script.
+*/
+func NewTokenizer(script []byte) *Tokenizer {
+	return &Tokenizer{script: script}
+}
+
+/*
+Next method reads the next token, it returns false at the end of the script or when a push is malformed,
+in which case Err returns a *PushError.
+*/
+func (t *Tokenizer) Next() bool {
+	if t.err != nil || t.pos >= len(t.script) {
+		return false
+	}
+
+	start := t.pos
+	op := Opcode(t.script[start])
+	left := len(t.script) - start - 1
+	if !op.IsPush() {
+		t.token = Token{Op: op, Offset: start}
+		t.pos++
+		return true
+	}
+
+	// number of bytes holding the length of the push
+	lenSize := 0
+	switch op {
+	case OP_PUSHDATA1:
+		lenSize = 1
+	case OP_PUSHDATA2:
+		lenSize = 2
+	case OP_PUSHDATA4:
+		lenSize = 4
+	}
+	if left < lenSize {
+		t.err = &PushError{Op: op, Offset: start, Want: lenSize, Have: left}
+		return false
+	}
+
+	length := uint64(op)
+	b := t.script[start+1:]
+	switch lenSize {
+	case 1:
+		length = uint64(b[0])
+	case 2:
+		length = uint64(binary.LittleEndian.Uint16(b))
+	case 4:
+		length = uint64(binary.LittleEndian.Uint32(b))
+	}
+	if uint64(left-lenSize) < length {
+		t.err = &PushError{Op: op, Offset: start, Want: lenSize + int(length), Have: left}
+		return false
+	}
+
+	dataStart := start + 1 + lenSize
+	t.pos = dataStart + int(length)
+	t.token = Token{Op: op, Data: t.script[dataStart:t.pos:t.pos], Offset: start}
+	return true
+}
+
+/*
+Token method returns the token read by the last call to Next.
+*/
+func (t *Tokenizer) Token() Token {
+	return t.token
+}
+
+/*
+Err method returns the *PushError which stopped the tokenizer, or nil when the whole script was read.
+*/
+func (t *Tokenizer) Err() error {
+	return t.err
+}
+
+/*
+Tokenize function splits script into its tokens. When a push is malformed, the tokens before it are returned together with a *PushError.
+*/
+func Tokenize(script []byte) ([]Token, error) {
+	var tokens []Token
+	t := NewTokenizer(script)
+	for t.Next() {
+		tokens = append(tokens, t.Token())
+	}
+	return tokens, t.Err()
+}
+
+/*
+ASM function renders script the way bitcoin-core's ScriptToAsmStr does: pushes of up to 4 bytes are shown as a decimal
+script number, longer pushes in lowercase hex, and other opcodes by name, see Opcode.String. A malformed push ends the ASM with "[error]".
+
+This is the format of scriptPubKey.asm in bitcoin-core's RPCs.
+*/
+func ASM(script []byte) string {
+	return asm(script, false)
+}
+
+/*
+SigASM function is ASM with the sighash type of signatures decoded, a push which is a strictly DER encoded signature
+has its sighash byte written as [ALL], [NONE], [SINGLE] or any of them with |ANYONECANPAY.
+
+This is the format of scriptSig.asm in bitcoin-core's RPCs.
+*/
+func SigASM(script []byte) string {
+	return asm(script, true)
+}
+
+func asm(script []byte, decodeSigHash bool) string {
+	var sb strings.Builder
+	t := NewTokenizer(script)
+	for t.Next() {
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+
+		token := t.Token()
+		if !token.Op.IsPush() {
+			sb.WriteString(token.Op.String())
+		} else if len(token.Data) <= 4 {
+			sb.WriteString(strconv.FormatInt(ScriptNum(token.Data), 10))
+		} else if sigHash, ok := sigHashName(token.Data); decodeSigHash && ok {
+			sb.WriteString(hex.EncodeToString(token.Data[:len(token.Data)-1]))
+			sb.WriteString(sigHash)
+		} else {
+			sb.WriteString(hex.EncodeToString(token.Data))
+		}
+	}
+
+	if t.Err() != nil {
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString("[error]")
+	}
+	return sb.String()
+}
+
+/*
+ScriptNum function decodes a script number, little endian with the sign in the highest bit of the last byte.
+Numbers used by scripts have at most 4 bytes, b should not be longer than 8 bytes.
+*/
+func ScriptNum(b []byte) int64 {
+	if len(b) == 0 {
+		return 0
+	}
+
+	var n int64
+	for i, v := range b {
+		n |= int64(v) << (8 * i)
+	}
+	if b[len(b)-1]&0x80 != 0 {
+		return -(n &^ (0x80 << (8 * (len(b) - 1))))
+	}
+	return n
+}
+
+/*
+IsWitnessProgram function reports whether script is a segwit output, a version opcode OP_0 or OP_1 to OP_16
+followed by a single push of 2 to 40 bytes, and returns the witness version and program.
+*/
+func IsWitnessProgram(script []byte) (int, []byte, bool) {
+	if len(script) < 4 || len(script) > 42 {
+		return 0, nil, false
+	}
+	op := Opcode(script[0])
+	if !op.IsSmallInt() || int(script[1])+2 != len(script) {
+		return 0, nil, false
+	}
+	return op.SmallInt(), script[2:], true
+}
+
+// sigHashTypes are the names of the sighash types bitcoin-core decodes in scriptSig ASM
+var sigHashTypes = map[byte]string{
+	0x01: "[ALL]",
+	0x02: "[NONE]",
+	0x03: "[SINGLE]",
+	0x81: "[ALL|ANYONECANPAY]",
+	0x82: "[NONE|ANYONECANPAY]",
+	0x83: "[SINGLE|ANYONECANPAY]",
+}
+
+/*
+sigHashName function returns the name of the sighash type of sig when it is a strictly DER encoded signature with a defined sighash type.
+*/
+func sigHashName(sig []byte) (string, bool) {
+	if !isValidSignatureEncoding(sig) {
+		return "", false
+	}
+	name, ok := sigHashTypes[sig[len(sig)-1]]
+	return name, ok
+}
+
+/*
+isValidSignatureEncoding function checks the strict DER encoding of a signature followed by its sighash byte, see BIP66.
+
+	0x30 [total-length] 0x02 [R-length] [R] 0x02 [S-length] [S] [sighash]
+*/
+func isValidSignatureEncoding(sig []byte) bool {
+	if len(sig) < 9 || len(sig) > 73 {
+		return false
+	}
+	if sig[0] != 0x30 || int(sig[1]) != len(sig)-3 {
+		return false
+	}
+
+	lenR := int(sig[3])
+	if 5+lenR >= len(sig) {
+		return false
+	}
+	lenS := int(sig[5+lenR])
+	if lenR+lenS+7 != len(sig) {
+		return false
+	}
+
+	// R and S must be positive integers without unnecessary leading zeros
+	if sig[2] != 0x02 || lenR == 0 || sig[4]&0x80 != 0 {
+		return false
+	}
+	if lenR > 1 && sig[4] == 0 && sig[5]&0x80 == 0 {
+		return false
+	}
+	if sig[lenR+4] != 0x02 || lenS == 0 || sig[lenR+6]&0x80 != 0 {
+		return false
+	}
+	if lenS > 1 && sig[lenR+6] == 0 && sig[lenR+7]&0x80 == 0 {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,129 @@
+package script_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser/script"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("can not decode hex %q\nerror: %v\n", s, err)
+	}
+	return b
+}
+
+/*
+test Tokenize function with push opcodes of every size and with malformed pushes
+*/
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		ops    []script.Opcode
+		data   []string
+		offset int // offset of the malformed push, -1 when there is none
+	}{
+		{"empty", "", nil, nil, -1},
+		{"p2pkh", "76a914000102030405060708090a0b0c0d0e0f1011121388ac",
+			[]script.Opcode{script.OP_DUP, script.OP_HASH160, 0x14, script.OP_EQUALVERIFY, script.OP_CHECKSIG},
+			[]string{"", "", "000102030405060708090a0b0c0d0e0f10111213", "", ""}, -1},
+		{"pushdata", "004c02aabb4d0100cc4e01000000dd",
+			[]script.Opcode{script.OP_0, script.OP_PUSHDATA1, script.OP_PUSHDATA2, script.OP_PUSHDATA4},
+			[]string{"", "aabb", "cc", "dd"}, -1},
+		{"short direct push", "5103aabb", []script.Opcode{script.OP_1}, []string{""}, 1},
+		{"missing pushdata length", "6a4d01", []script.Opcode{script.OP_RETURN}, []string{""}, 1},
+		{"short pushdata4", "4effffffff00", nil, nil, 0},
+	}
+
+	for _, test := range tests {
+		tokens, err := script.Tokenize(decodeHex(t, test.script))
+		var pushErr *script.PushError
+		if test.offset < 0 && err != nil {
+			t.Errorf("Tokenize() %s\nerror: %v\n", test.name, err)
+		} else if test.offset >= 0 && (!errors.As(err, &pushErr) || pushErr.Offset != test.offset) {
+			t.Errorf("Tokenize() %s expected a *PushError at offset %d but got %v", test.name, test.offset, err)
+		}
+
+		if len(tokens) != len(test.ops) {
+			t.Errorf("Tokenize() %s got %d tokens, want %d", test.name, len(tokens), len(test.ops))
+			continue
+		}
+		for i, token := range tokens {
+			if token.Op != test.ops[i] || hex.EncodeToString(token.Data) != test.data[i] {
+				t.Errorf("Tokenize() %s token %d got = %s %x, want %s %s", test.name, i, token.Op, token.Data, test.ops[i], test.data[i])
+			}
+		}
+	}
+}
+
+/*
+test ASM and SigASM functions against the output of bitcoin-core's decodescript RPC
+*/
+func TestASM(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		asm    string
+		sigAsm string
+	}{
+		{"empty", "", "", ""},
+		{"genesis coinbase",
+			"04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73",
+			"486604799 4 5468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73", ""},
+		{"p2pkh", "76a914000102030405060708090a0b0c0d0e0f1011121388ac",
+			"OP_DUP OP_HASH160 000102030405060708090a0b0c0d0e0f10111213 OP_EQUALVERIFY OP_CHECKSIG", ""},
+		{"multisig", "51210300000000000000000000000000000000000000000000000000000000000000005152ae",
+			"1 030000000000000000000000000000000000000000000000000000000000000000 1 2 OP_CHECKMULTISIG", ""},
+		{"script numbers", "4f000181018002ff000400000080", "-1 0 -1 0 255 0", ""},
+		{"unknown opcodes", "bafbba", "OP_CHECKSIGADD OP_UNKNOWN OP_CHECKSIGADD", ""},
+		{"malformed push", "76a914aabb", "OP_DUP OP_HASH160 [error]", ""},
+		{"malformed first push", "4c", "[error]", ""},
+		{"signature", "09300602010102010101", "300602010102010101", "3006020101020101[ALL]"},
+		{"anyonecanpay", "09300602010102010183", "300602010102010183", "3006020101020101[SINGLE|ANYONECANPAY]"},
+		// a high S value is not a valid DER integer, neither is an undefined sighash type
+		{"not der", "09300602010102018101", "300602010102018101", ""},
+		{"undefined sighash", "09300602010102010104", "300602010102010104", ""},
+	}
+
+	for _, test := range tests {
+		b := decodeHex(t, test.script)
+		if got := script.ASM(b); got != test.asm {
+			t.Errorf("ASM() %s got = %q, want %q", test.name, got, test.asm)
+		}
+		sigAsm := test.sigAsm
+		if sigAsm == "" {
+			sigAsm = test.asm
+		}
+		if got := script.SigASM(b); got != sigAsm {
+			t.Errorf("SigASM() %s got = %q, want %q", test.name, got, sigAsm)
+		}
+	}
+}
+
+func TestIsWitnessProgram(t *testing.T) {
+	tests := []struct {
+		script  string
+		version int
+		program string
+		ok      bool
+	}{
+		{"0014000102030405060708090a0b0c0d0e0f10111213", 0, "000102030405060708090a0b0c0d0e0f10111213", true},
+		{"5120000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", 1, "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", true},
+		{"60020001", 16, "0001", true},
+		{"4f020001", 0, "", false},
+		{"00010001", 0, "", false},
+		{"0014000102", 0, "", false},
+	}
+
+	for _, test := range tests {
+		version, program, ok := script.IsWitnessProgram(decodeHex(t, test.script))
+		if ok != test.ok || version != test.version || hex.EncodeToString(program) != test.program {
+			t.Errorf("IsWitnessProgram(%s) got = %d %x %t, want %d %s %t", test.script, version, program, ok, test.version, test.program, test.ok)
+		}
+	}
+}
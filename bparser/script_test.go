@@ -0,0 +1,99 @@
+package bparser_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+/*
+test ScriptSigASM and ScriptPubKeyASM methods with the genesis block coinbase
+*/
+func TestGenesisASM(t *testing.T) {
+	block, err := bparser.ParseBlock(geneisBlockDec, 0)
+	if err != nil {
+		t.Fatalf("can not parse genesis block\nerror: %v\n", err)
+	}
+
+	coinbase := block.Tx.Tx[0]
+	wantSig := "486604799 4 5468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73"
+	if got := coinbase.Inputs[0].ScriptSigASM(); got != wantSig {
+		t.Errorf("ScriptSigASM() got = %q, want %q", got, wantSig)
+	}
+
+	wantPubKey := "04678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5f OP_CHECKSIG"
+	if got := coinbase.Outputs[0].ScriptPubKeyASM(); got != wantPubKey {
+		t.Errorf("ScriptPubKeyASM() got = %q, want %q", got, wantPubKey)
+	}
+
+	if got := (bparser.TxInputs{ScriptSig: []byte{0x4c}}).ScriptSigASM(); got != "[error]" {
+		t.Errorf("ScriptSigASM() of a malformed push got = %q, want [error]", got)
+	}
+}
+
+func TestWitnessScript(t *testing.T) {
+	witnessScript := []byte{0x51, 0xb2}
+	p2wsh := append([]byte{0x00, 0x20}, make([]byte, 32)...)
+	p2tr := append([]byte{0x51, 0x20}, make([]byte, 32)...)
+	p2wpkh := append([]byte{0x00, 0x14}, make([]byte, 20)...)
+	controlBlock := make([]byte, 33)
+	annex := []byte{0x50, 0x01}
+
+	tests := []struct {
+		name    string
+		prevOut *bparser.Coin
+		witness [][]byte
+		want    []byte
+	}{
+		{"no prevout", nil, [][]byte{{0x01}, witnessScript}, nil},
+		{"p2wsh", &bparser.Coin{ScriptPubKey: p2wsh}, [][]byte{{}, {0x01}, witnessScript}, witnessScript},
+		{"p2wpkh", &bparser.Coin{ScriptPubKey: p2wpkh}, [][]byte{{0x01}, {0x02}}, nil},
+		{"p2tr key path", &bparser.Coin{ScriptPubKey: p2tr}, [][]byte{make([]byte, 64)}, nil},
+		{"p2tr key path with annex", &bparser.Coin{ScriptPubKey: p2tr}, [][]byte{make([]byte, 64), annex}, nil},
+		{"p2tr script path", &bparser.Coin{ScriptPubKey: p2tr}, [][]byte{{0x01}, witnessScript, controlBlock}, witnessScript},
+		{"p2tr script path with annex", &bparser.Coin{ScriptPubKey: p2tr}, [][]byte{witnessScript, controlBlock, annex}, witnessScript},
+	}
+
+	for _, test := range tests {
+		in := bparser.TxInputs{PrevOut: test.prevOut, Witness: test.witness}
+		if got := in.WitnessScript(); !bytes.Equal(got, test.want) {
+			t.Errorf("WitnessScript() %s got = %X, want %X", test.name, got, test.want)
+		}
+	}
+
+	in := bparser.TxInputs{PrevOut: &bparser.Coin{ScriptPubKey: p2wsh}, Witness: [][]byte{witnessScript}}
+	if got := in.WitnessScriptASM(); got != "1 OP_CHECKSEQUENCEVERIFY" {
+		t.Errorf("WitnessScriptASM() got = %q, want %q", got, "1 OP_CHECKSEQUENCEVERIFY")
+	}
+}
+
+/*
+test block.tmpl renders the ASM of the genesis block
+*/
+func TestBlockTemplate(t *testing.T) {
+	block, err := bparser.ParseBlock(geneisBlockDec, 0)
+	if err != nil {
+		t.Fatalf("can not parse genesis block\nerror: %v\n", err)
+	}
+
+	tmpl, err := template.ParseFiles("block.tmpl")
+	if err != nil {
+		t.Fatalf("can not parse block.tmpl\nerror: %v\n", err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, block); err != nil {
+		t.Fatalf("can not execute block.tmpl\nerror: %v\n", err)
+	}
+	if !strings.Contains(out.String(), "ASM          : 04678afdb0fe") || !strings.Contains(out.String(), "Type         : pubkey") || !strings.Contains(out.String(), "ScriptSig ASM: 486604799 4 ") {
+		t.Errorf("block.tmpl output is missing ASM\n%s", out.String())
+	}
+	// scripts and compact numbers are written as upper case hex, as they were when they were hex strings
+	for _, want := range []string{"Bits           : 1D00FFFF", "ScriptSig    : 04FFFF001D0104455468", "Sequence     : FFFFFFFF"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("block.tmpl output is missing %q\n%s", want, out.String())
+		}
+	}
+}
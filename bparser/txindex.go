@@ -0,0 +1,283 @@
+package bparser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+const (
+	// prefix of the position of every tx, followed by the txid in internal byte order, the same as bitcoin-core's txindex
+	txIndexPrefix = 't'
+	// key holding the height and hash of the last block added to the tx index
+	txIndexStateKey = 'B'
+)
+
+// ErrTxNotFound is returned by TxIndex.Get and TxIndex.GetTransaction when the txid is not in the tx index.
+var ErrTxNotFound = errors.New("tx not found in tx index")
+
+/*
+DiskTxPos type locates a tx in the blk files. DataPos is the offset of the header of its block in blkNNNNN.dat, after
+the magic number and block size, the same as DiskBlockIndex.DataPos, and TxOffset is the offset of the tx after the
+80 byte header. Size is the full size of the tx.
+*/
+type DiskTxPos struct {
+	FileNum  int
+	DataPos  int64
+	TxOffset int64
+	Size     int64
+}
+
+/*
+TxIndex type maps every txid of the main chain to the position of its tx in the blk files, like bitcoin-core's -txindex,
+so a single tx can be read without parsing its block.
+
+Positions are stored in a LevelDB database with the same keys and CDiskTxPos encoding as bitcoin-core's indexes/txindex,
+followed by a VARINT of the tx size. As with UTXOSet, blocks are added in height order, changes are kept in memory until
+Checkpoint is called and opening an existing database resumes from the last checkpoint. Blocks can not be removed.
+
+The coinbase txs of blocks 91,842 and 91,880 repeat the txids of blocks 91,812 and 91,722, and like bitcoin-core
+the later tx is the one kept.
+
+# Example
+
+	ti, _ := OpenTxIndex("txindex", &MainNet)
+	defer ti.Close()
+	ti.Replay(ctx, dir, chain, 10_000, nil)
+	tx, _ := ti.GetTransaction(dir, txid)
+*/
+type TxIndex struct {
+	db        *leveldb.DB
+	net       *Network
+	height    int
+	blockHash Hash
+
+	// positions added since the last checkpoint
+	added map[Hash]DiskTxPos
+}
+
+/*
+OpenTxIndex function opens the tx index database at path, creating it when it does not exist.
+net is used to set the Address of the outputs of the txs read by GetTransaction.
+*/
+func OpenTxIndex(path string, net *Network) (*TxIndex, error) {
+	db, err := leveldb.OpenFile(path, &opt.Options{})
+	if err != nil {
+		errMsg := fmt.Sprintf("can not open tx index at %s in OpenTxIndex() function.\nerror: %v\n", path, err)
+		return nil, errors.New(errMsg)
+	}
+
+	ti := &TxIndex{
+		db:     db,
+		net:    net,
+		height: -1,
+		added:  make(map[Hash]DiskTxPos),
+	}
+
+	value, err := db.Get([]byte{txIndexStateKey}, nil)
+	if err == leveldb.ErrNotFound {
+		return ti, nil
+	} else if err != nil {
+		db.Close()
+		errMsg := fmt.Sprintf("can not read tx index state in OpenTxIndex() function.\nerror: %v\n", err)
+		return nil, errors.New(errMsg)
+	}
+
+	// 32 byte last block, VARINT height + 1
+	r := varIntReader{b: value}
+	hash := r.bytes(32)
+	ti.height = int(r.varInt()) - 1
+	if r.err != nil {
+		db.Close()
+		errMsg := fmt.Sprintf("can not decode tx index state in OpenTxIndex() function.\nerror: %v\n", r.err)
+		return nil, errors.New(errMsg)
+	}
+	ti.blockHash = Hash(hash)
+
+	return ti, nil
+}
+
+/*
+Close method writes a checkpoint and closes the database.
+*/
+func (ti *TxIndex) Close() error {
+	if err := ti.Checkpoint(); err != nil {
+		ti.db.Close()
+		return err
+	}
+	return ti.db.Close()
+}
+
+/*
+Height method returns the height of the last added block, -1 for an empty index.
+*/
+func (ti *TxIndex) Height() int {
+	return ti.height
+}
+
+/*
+AddBlock method adds the position of every tx of block, which is stored in blkNNNNN.dat with NNNNN being fileNum.
+BlockNumber of the block must be its height, FileOffset must be set as BlockReader does, and the block must be
+the child of the last added block.
+*/
+func (ti *TxIndex) AddBlock(block *BlockData, fileNum int) error {
+	header := block.Header
+	if block.BlockNumber != ti.height+1 || (block.BlockNumber > 0 && header.PrevBlock != ti.blockHash) {
+		errMsg := fmt.Sprintf("block %s at height %d is not the child of block %s at height %d in AddBlock() method\n", header.BlockHash, block.BlockNumber, ti.blockHash, ti.height)
+		return errors.New(errMsg)
+	}
+
+	// the magic number and block size come before the header
+	dataPos := block.FileOffset + 8
+	for _, tx := range block.Tx.Tx {
+		ti.added[tx.TxId] = DiskTxPos{
+			FileNum:  fileNum,
+			DataPos:  dataPos,
+			TxOffset: tx.Offset - blockHeaderSize,
+			Size:     tx.Length,
+		}
+	}
+
+	ti.height = block.BlockNumber
+	ti.blockHash = header.BlockHash
+	return nil
+}
+
+/*
+Checkpoint method writes the positions added since the last checkpoint to the database in a single batch.
+*/
+func (ti *TxIndex) Checkpoint() error {
+	batch := new(leveldb.Batch)
+	for txId, pos := range ti.added {
+		value := AppendVarInt(nil, uint64(pos.FileNum))
+		value = AppendVarInt(value, uint64(pos.DataPos))
+		value = AppendVarInt(value, uint64(pos.TxOffset))
+		batch.Put(txIndexKey(txId), AppendVarInt(value, uint64(pos.Size)))
+	}
+
+	if ti.height >= 0 {
+		state := AppendVarInt(ti.blockHash[:], uint64(ti.height+1))
+		batch.Put([]byte{txIndexStateKey}, state)
+	}
+
+	if err := ti.db.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+		errMsg := fmt.Sprintf("can not write checkpoint at height %d in Checkpoint() method.\nerror: %v\n", ti.height, err)
+		return errors.New(errMsg)
+	}
+
+	clear(ti.added)
+	return nil
+}
+
+/*
+Get method returns the position of the tx with txid, or ErrTxNotFound when it is not in the index.
+*/
+func (ti *TxIndex) Get(txid Hash) (DiskTxPos, error) {
+	if pos, ok := ti.added[txid]; ok {
+		return pos, nil
+	}
+
+	value, err := ti.db.Get(txIndexKey(txid), nil)
+	if err == leveldb.ErrNotFound {
+		return DiskTxPos{}, ErrTxNotFound
+	} else if err != nil {
+		errMsg := fmt.Sprintf("can not read position of tx %s from tx index in Get() method.\nerror: %v\n", txid, err)
+		return DiskTxPos{}, errors.New(errMsg)
+	}
+
+	// VARINT file number, VARINT data position, VARINT tx offset, VARINT tx size
+	r := varIntReader{b: value}
+	pos := DiskTxPos{
+		FileNum:  int(r.varInt()),
+		DataPos:  int64(r.varInt()),
+		TxOffset: int64(r.varInt()),
+		Size:     int64(r.varInt()),
+	}
+	if r.err != nil {
+		errMsg := fmt.Sprintf("can not decode position of tx %s in Get() method.\nerror: %v\n", txid, r.err)
+		return DiskTxPos{}, errors.New(errMsg)
+	}
+
+	return pos, nil
+}
+
+/*
+GetTransaction method looks up the position of the tx with txid and reads only that tx from its blk file in dir.
+Offset of the returned tx is relative to the start of its block header, the same as the txs of ParseBlock.
+*/
+func (ti *TxIndex) GetTransaction(dir *BlocksDir, txid Hash) (TxData, error) {
+	pos, err := ti.Get(txid)
+	if err != nil {
+		return TxData{}, err
+	}
+
+	file, err := dir.OpenBlockFile(pos.FileNum)
+	if err != nil {
+		return TxData{}, err
+	}
+	defer file.Close()
+
+	raw := make([]byte, pos.Size)
+	offset := pos.DataPos + blockHeaderSize + pos.TxOffset
+	if _, err := file.ReadAt(raw, offset); err != nil {
+		errMsg := fmt.Sprintf("can not read tx %s at offset %d in blk%05d.dat in GetTransaction() method.\nerror: %v\n", txid, offset, pos.FileNum, err)
+		return TxData{}, errors.New(errMsg)
+	}
+
+	tx, err := ParseBlockTx(raw, 0)
+	if err != nil {
+		return TxData{}, err
+	}
+	if tx.TxId != txid {
+		errMsg := fmt.Sprintf("expected tx %s at offset %d in blk%05d.dat but got %s in GetTransaction() method\n", txid, offset, pos.FileNum, tx.TxId)
+		return TxData{}, errors.New(errMsg)
+	}
+
+	tx.Offset = blockHeaderSize + pos.TxOffset
+	for i := range tx.Outputs {
+		tx.Outputs[i].Address = ti.net.Address(tx.Outputs[i].ScriptPubKey)
+	}
+	return tx, nil
+}
+
+/*
+Replay method adds every main chain block of chain after the last added block, reading each block from dir.
+Blocks are parsed in parallel with ParseMainChain and added in height order until the tip or until ctx is cancelled.
+A checkpoint is written every checkpointEvery blocks and after the last block, including when replaying stops early,
+fn is called with the height of every block.
+*/
+func (ti *TxIndex) Replay(ctx context.Context, dir *BlocksDir, chain *HeaderChain, checkpointEvery int, fn func(height int) error) error {
+	_, err := ParseMainChain(ctx, dir, chain, ti.height+1, 0, func(block BlockData) error {
+		entry, ok := chain.AtHeight(block.BlockNumber)
+		if !ok {
+			errMsg := fmt.Sprintf("block %s at height %d is not in the main chain in Replay() method\n", block.Header.BlockHash, block.BlockNumber)
+			return errors.New(errMsg)
+		}
+		if err := ti.AddBlock(&block, entry.FileNum); err != nil {
+			return err
+		}
+		if fn != nil {
+			if err := fn(block.BlockNumber); err != nil {
+				return err
+			}
+		}
+
+		if checkpointEvery > 0 && block.BlockNumber%checkpointEvery == 0 {
+			return ti.Checkpoint()
+		}
+		return nil
+	})
+	if err != nil {
+		// keep the blocks added before the error, they were all on the main chain
+		return errors.Join(err, ti.Checkpoint())
+	}
+
+	return ti.Checkpoint()
+}
+
+func txIndexKey(txid Hash) []byte {
+	return append([]byte{txIndexPrefix}, txid[:]...)
+}
@@ -0,0 +1,173 @@
+package bparser_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+func TestTxIndex(t *testing.T) {
+	var file []byte
+	var txs []bparser.TxData
+	for i, blk := range testChain(t) {
+		file = append(file, blk...)
+		block, _ := bparser.ParseBlock(blk, i)
+		txs = append(txs, block.Tx.Tx...)
+	}
+
+	tests := []struct {
+		name      string
+		blocksDir func(t *testing.T) *bparser.BlocksDir
+	}{
+		{"two blocks per file in reverse order", func(t *testing.T) *bparser.BlocksDir { return writeChainFiles(t, 1) }},
+		{"obfuscated", func(t *testing.T) *bparser.BlocksDir {
+			blocksDir, err := bparser.OpenBlocksDir(writeBlocksDir(t, file, []byte{1, 2, 3, 4, 5, 6, 7, 8}))
+			if err != nil {
+				t.Fatalf("OpenBlocksDir() returned error\nerror: %v\n", err)
+			}
+			return blocksDir
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			blocksDir := test.blocksDir(t)
+			chain, err := bparser.BuildHeaderChain(blocksDir, &bparser.MainNet)
+			if err != nil {
+				t.Fatalf("BuildHeaderChain() returned error\nerror: %v\n", err)
+			}
+
+			path := filepath.Join(t.TempDir(), "txindex")
+			ti, err := bparser.OpenTxIndex(path, &bparser.MainNet)
+			if err != nil {
+				t.Fatalf("OpenTxIndex() returned error\nerror: %v\n", err)
+			}
+			var heights []int
+			err = ti.Replay(context.Background(), blocksDir, chain, 2, func(height int) error {
+				heights = append(heights, height)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Replay() returned error\nerror: %v\n", err)
+			} else if !slices.Equal(heights, []int{0, 1, 2, 3}) {
+				t.Errorf("Replay() added heights %v, want [0 1 2 3]", heights)
+			}
+			if err := ti.Close(); err != nil {
+				t.Fatalf("Close() returned error\nerror: %v\n", err)
+			}
+
+			// opening again resumes from the last checkpoint
+			ti, err = bparser.OpenTxIndex(path, &bparser.MainNet)
+			if err != nil {
+				t.Fatalf("OpenTxIndex() returned error\nerror: %v\n", err)
+			}
+			defer ti.Close()
+			if ti.Height() != 3 {
+				t.Errorf("Height() after OpenTxIndex() got %d, want 3", ti.Height())
+			}
+
+			for _, want := range txs {
+				got, err := ti.GetTransaction(blocksDir, want.TxId)
+				if err != nil {
+					t.Errorf("GetTransaction() of tx %s returned error\nerror: %v\n", want.TxId, err)
+					continue
+				}
+				if !slices.Equal(got.Raw, want.Raw) || got.Offset != want.Offset || got.Length != want.Length {
+					t.Errorf("GetTransaction() of tx %s got offset %d and %d bytes, want offset %d and %d bytes", want.TxId, got.Offset, got.Length, want.Offset, want.Length)
+				} else if got.Outputs[0].Address != want.Outputs[0].Address {
+					t.Errorf("GetTransaction() of tx %s got address %q, want %q", want.TxId, got.Outputs[0].Address, want.Outputs[0].Address)
+				}
+			}
+
+			if _, err := ti.GetTransaction(blocksDir, bparser.Hash{1}); err != bparser.ErrTxNotFound {
+				t.Errorf("GetTransaction() of an unknown tx got error %v, want ErrTxNotFound", err)
+			}
+		})
+	}
+}
+
+func TestTxIndexAddBlock(t *testing.T) {
+	ti, err := bparser.OpenTxIndex(filepath.Join(t.TempDir(), "txindex"), &bparser.MainNet)
+	if err != nil {
+		t.Fatalf("OpenTxIndex() returned error\nerror: %v\n", err)
+	}
+	defer ti.Close()
+
+	chain := testChain(t)
+	blocks := make([]bparser.BlockData, len(chain))
+	for i, blk := range chain {
+		blocks[i], _ = bparser.ParseBlock(blk, i)
+	}
+
+	if err := ti.AddBlock(&blocks[1], 0); err == nil {
+		t.Errorf("AddBlock() of height 1 to an empty index expected an error")
+	}
+	if err := ti.AddBlock(&blocks[0], 0); err != nil {
+		t.Fatalf("AddBlock() returned error\nerror: %v\n", err)
+	}
+	if err := ti.AddBlock(&blocks[2], 0); err == nil {
+		t.Errorf("AddBlock() of height 2 after height 0 expected an error")
+	}
+
+	// positions are found before the checkpoint is written
+	blocks[1].FileOffset = 300
+	if err := ti.AddBlock(&blocks[1], 7); err != nil {
+		t.Fatalf("AddBlock() returned error\nerror: %v\n", err)
+	}
+	coinbase := blocks[1].Tx.Tx[0]
+	want := bparser.DiskTxPos{FileNum: 7, DataPos: 308, TxOffset: 1, Size: coinbase.Length}
+	for _, checkpoint := range []bool{false, true} {
+		if checkpoint {
+			if err := ti.Checkpoint(); err != nil {
+				t.Fatalf("Checkpoint() returned error\nerror: %v\n", err)
+			}
+		}
+		if pos, err := ti.Get(coinbase.TxId); err != nil || pos != want {
+			t.Errorf("Get() with checkpoint %t got %+v and error %v, want %+v", checkpoint, pos, err, want)
+		}
+	}
+}
+
+func TestTxIndexReplayStopped(t *testing.T) {
+	blocksDir := writeChainFiles(t, 1)
+	chain, err := bparser.BuildHeaderChain(blocksDir, &bparser.MainNet)
+	if err != nil {
+		t.Fatalf("BuildHeaderChain() returned error\nerror: %v\n", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "txindex")
+	ti, err := bparser.OpenTxIndex(path, &bparser.MainNet)
+	if err != nil {
+		t.Fatalf("OpenTxIndex() returned error\nerror: %v\n", err)
+	}
+	errStop := errors.New("stop")
+	err = ti.Replay(context.Background(), blocksDir, chain, 10, func(height int) error {
+		if height == 1 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("Replay() got error %v, want %v", err, errStop)
+	}
+	ti.Close()
+
+	// blocks added before the error are checkpointed even though checkpointEvery was not reached
+	ti, err = bparser.OpenTxIndex(path, &bparser.MainNet)
+	if err != nil {
+		t.Fatalf("OpenTxIndex() returned error\nerror: %v\n", err)
+	}
+	defer ti.Close()
+	if ti.Height() != 1 {
+		t.Errorf("Height() after a stopped Replay() got %d, want 1", ti.Height())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ti.Replay(ctx, blocksDir, chain, 10, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Replay() with a cancelled context got error %v, want %v", err, context.Canceled)
+	}
+}
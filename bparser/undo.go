@@ -0,0 +1,222 @@
+package bparser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// undoChecksumSize is the size of the checksum written after every undo record
+	undoChecksumSize = 32
+	// maxUndoSize is the largest undo record BlockReader will read, undo data is not bounded by the block size
+	maxUndoSize = 64_000_000
+)
+
+/*
+TxUndo type holds the outputs spent by a single tx, in the same order as the tx inputs.
+*/
+type TxUndo struct {
+	PrevOuts []Coin
+}
+
+/*
+BlockUndo type is a single undo record from a rev*.dat file (CBlockUndo), which holds the outputs spent by every tx in a block
+except the coinbase.
+
+Checksum is the double sha256 of the previous block hash and Raw, the serialized undo record, which is how bitcoin-core
+ties an undo record to its block.
+*/
+type BlockUndo struct {
+	Txs        []TxUndo
+	Checksum   []byte
+	FileOffset int64
+	Raw        []byte
+}
+
+/*
+Matches method returns true when the undo record belongs to the block whose previous block hash is prevBlock.
+*/
+func (u BlockUndo) Matches(prevBlock Hash) bool {
+	checksum := doubleSha256(prevBlock[:], u.Raw)
+	return bytes.Equal(checksum[:], u.Checksum)
+}
+
+/*
+ParseBlockUndo function decodes a serialized CBlockUndo, the undo record without its magic number, size and checksum.
+
+	compact size number of txs
+	for each tx, compact size number of inputs
+	for each input:
+		VARINT height * 2 + coinbase
+		VARINT version, only when height > 0 and always 0
+		compressed amount and script, see compressedTxOut
+*/
+func ParseBlockUndo(record []byte) (BlockUndo, error) {
+	r := varIntReader{b: record}
+	txCount := r.compactSize()
+	if r.err == nil && txCount > int64(len(record)) {
+		errMsg := fmt.Sprintf("undo record has %d txs but only %d bytes in ParseBlockUndo() function\n", txCount, len(record))
+		return BlockUndo{}, errors.New(errMsg)
+	}
+
+	txs := make([]TxUndo, 0, txCount)
+	for i := 0; i < int(txCount) && r.err == nil; i++ {
+		inputCount := r.compactSize()
+		if r.err == nil && inputCount > int64(len(record)) {
+			errMsg := fmt.Sprintf("undo record for tx %d has %d inputs but only %d bytes in ParseBlockUndo() function\n", i, inputCount, len(record))
+			return BlockUndo{}, errors.New(errMsg)
+		}
+
+		prevOuts := make([]Coin, 0, inputCount)
+		for j := 0; j < int(inputCount) && r.err == nil; j++ {
+			code := r.varInt()
+			coin := Coin{Height: int(code >> 1), Coinbase: code&1 == 1}
+			if coin.Height > 0 {
+				// version of the tx, no longer used by bitcoin-core
+				r.varInt()
+			}
+			coin.Amount, coin.ScriptPubKey = r.compressedTxOut()
+			prevOuts = append(prevOuts, coin)
+		}
+		txs = append(txs, TxUndo{PrevOuts: prevOuts})
+	}
+
+	if r.err != nil {
+		errMsg := fmt.Sprintf("can not decode undo record in ParseBlockUndo() function.\nerror: %v\n", r.err)
+		return BlockUndo{}, errors.New(errMsg)
+	} else if r.pos != len(record) {
+		errMsg := fmt.Sprintf("undo record has %d bytes left over in ParseBlockUndo() function\n", len(record)-r.pos)
+		return BlockUndo{}, errors.New(errMsg)
+	}
+
+	return BlockUndo{Txs: txs, Raw: record}, nil
+}
+
+/*
+UndoReader type reads undo records one at a time from a bitcoin-core rev*.dat file.
+
+Each record is the magic number, a 4 byte little-endian size, the undo data and a 32 byte checksum.
+Undo records are written as blocks are connected, so their order does not match the order of blocks in the blk file
+with the same number. Use BlockUndo.Matches or the block index to pair records with blocks.
+*/
+type UndoReader struct {
+	br *BlockReader
+}
+
+/*
+NewUndoReader function returns an UndoReader which reads undo records of network net from r.
+*/
+func NewUndoReader(r io.Reader, net *Network) *UndoReader {
+	return &UndoReader{br: NewBlockReader(r, net)}
+}
+
+/*
+Next method reads and decodes the next undo record, io.EOF is returned once there are no more records.
+*/
+func (ur *UndoReader) Next() (BlockUndo, error) {
+	record, offset, err := ur.br.nextRecord(1, maxUndoSize)
+	if err != nil {
+		return BlockUndo{}, err
+	}
+
+	checksum := make([]byte, undoChecksumSize)
+	n, err := io.ReadFull(ur.br.r, checksum)
+	ur.br.offset += int64(n)
+	if err != nil {
+		errMsg := fmt.Sprintf("can not read checksum of undo record at offset %d in Next() method.\nerror: %v\n", offset, err)
+		return BlockUndo{}, errors.New(errMsg)
+	}
+
+	undo, err := ParseBlockUndo(record[8:])
+	if err != nil {
+		errMsg := fmt.Sprintf("can not parse undo record at offset %d in Next() method.\nerror: %v\n", offset, err)
+		return BlockUndo{}, errors.New(errMsg)
+	}
+	undo.Checksum = checksum
+	undo.FileOffset = offset
+
+	return undo, nil
+}
+
+/*
+ApplyUndo function attaches the outputs spent by every input of block from its undo record,
+and sets the Fee and FeeRate of every tx except the coinbase.
+*/
+func ApplyUndo(block *BlockData, undo BlockUndo) error {
+	if len(block.Tx.Tx) == 0 || len(undo.Txs) != len(block.Tx.Tx)-1 {
+		errMsg := fmt.Sprintf("undo record has %d txs but block %s has %d txs besides the coinbase in ApplyUndo() function\n", len(undo.Txs), block.Header.BlockHash, len(block.Tx.Tx)-1)
+		return errors.New(errMsg)
+	}
+
+	for i, txUndo := range undo.Txs {
+		tx := &block.Tx.Tx[i+1]
+		if len(txUndo.PrevOuts) != len(tx.Inputs) {
+			errMsg := fmt.Sprintf("undo record has %d spent outputs but tx %s has %d inputs in ApplyUndo() function\n", len(txUndo.PrevOuts), tx.TxId, len(tx.Inputs))
+			return errors.New(errMsg)
+		}
+
+		var in Amount
+		for j := range tx.Inputs {
+			prevOut := txUndo.PrevOuts[j]
+			tx.Inputs[j].PrevOut = &prevOut
+			sum, err := in.Add(prevOut.Amount)
+			if err != nil {
+				errMsg := fmt.Sprintf("can not add spent output %d of tx %s in ApplyUndo() function.\nerror: %v\n", j, tx.TxId, err)
+				return errors.New(errMsg)
+			}
+			in = sum
+		}
+
+		if err := tx.setFee(in); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+OpenUndoFile method opens revNNNNN.dat for file number num.
+*/
+func (d *BlocksDir) OpenUndoFile(num int) (*DatFile, error) {
+	return d.Open(fmt.Sprintf("rev%05d.dat", num))
+}
+
+/*
+ReadUndo method seeks directly to the undo record of a block in its rev file and parses it.
+*/
+func (bi *BlockIndex) ReadUndo(dir *BlocksDir, entry DiskBlockIndex) (BlockUndo, error) {
+	if !entry.HaveUndo() {
+		errMsg := fmt.Sprintf("block %s at height %d has no undo data in ReadUndo() method\n", entry.Header.BlockHash, entry.Height)
+		return BlockUndo{}, errors.New(errMsg)
+	}
+
+	file, err := dir.OpenUndoFile(entry.FileNum)
+	if err != nil {
+		return BlockUndo{}, err
+	}
+	defer file.Close()
+
+	// undo position points after the magic number and size
+	offset := entry.UndoPos - 8
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		errMsg := fmt.Sprintf("can not seek to offset %d in rev%05d.dat in ReadUndo() method.\nerror: %v\n", offset, entry.FileNum, err)
+		return BlockUndo{}, errors.New(errMsg)
+	}
+
+	ur := NewUndoReader(file, bi.net)
+	ur.br.offset = offset
+	undo, err := ur.Next()
+	if err != nil {
+		return BlockUndo{}, err
+	}
+
+	if !undo.Matches(entry.Header.PrevBlock) {
+		errMsg := fmt.Sprintf("undo record at offset %d in rev%05d.dat does not match block %s in ReadUndo() method\n", offset, entry.FileNum, entry.Header.BlockHash)
+		return BlockUndo{}, errors.New(errMsg)
+	}
+
+	return undo, nil
+}
@@ -0,0 +1,143 @@
+package bparser_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+/*
+undoRecord function returns a serialized undo record for a block with a coinbase and one tx spending a single P2PKH output.
+*/
+func undoRecord(amount bparser.Amount) []byte {
+	script := []byte{118, 169, 20, 65, 160, 218, 69, 116, 194, 64, 156, 150, 113, 176, 36, 245, 207, 103, 118, 106, 249, 119, 134, 136, 172}
+	record := []byte{1, 1}                       // one tx with one input
+	record = bparser.AppendVarInt(record, 600*2) // height 600, not a coinbase
+	record = bparser.AppendVarInt(record, 0)     // version
+	return bparser.AppendCompressedTxOut(record, amount, script)
+}
+
+/*
+revFile function returns a rev file holding a single undo record for a block whose previous block hash is prevBlock in internal byte order.
+*/
+func revFile(record []byte, prevBlock []byte) []byte {
+	first := sha256.Sum256(append(append([]byte{}, prevBlock...), record...))
+	checksum := sha256.Sum256(first[:])
+
+	file := []byte{249, 190, 180, 217}
+	file = binary.LittleEndian.AppendUint32(file, uint32(len(record)))
+	file = append(file, record...)
+	return append(file, checksum[:]...)
+}
+
+func TestUndoReader(t *testing.T) {
+	record := undoRecord(1_000_000)
+	file := revFile(record, make([]byte, 32))
+
+	ur := bparser.NewUndoReader(bytes.NewReader(file), &bparser.MainNet)
+	undo, err := ur.Next()
+	if err != nil {
+		t.Fatalf("Next() returned error\nerror: %v\n", err)
+	}
+	if _, err := ur.Next(); err != io.EOF {
+		t.Errorf("Next() expected io.EOF after the last record but got %v", err)
+	}
+
+	if undo.FileOffset != 0 {
+		t.Errorf("Next() got FileOffset = %d, want 0", undo.FileOffset)
+	} else if len(undo.Txs) != 1 || len(undo.Txs[0].PrevOuts) != 1 {
+		t.Fatalf("Next() got %d txs, want 1 tx with 1 spent output", len(undo.Txs))
+	}
+
+	coin := undo.Txs[0].PrevOuts[0]
+	if coin.Height != 600 || coin.Coinbase || coin.Amount != 1_000_000 {
+		t.Errorf("Next() got coin = %+v, want height 600 and amount 1000000", coin)
+	}
+
+	// the genesis header has a previous block hash of all zeros
+	block, err := bparser.ParseBlock(buildBlock(geneisBlockDec[8:88], geneisBlockDec[89:], secondTx), 0)
+	if err != nil {
+		t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+	}
+	if !undo.Matches(block.Header.PrevBlock) {
+		t.Errorf("Matches() returned false for the block the undo record was written for")
+	}
+	if undo.Matches(bparser.MainNet.GenesisHash) {
+		t.Errorf("Matches() returned true for a different block")
+	}
+
+	// a corrupt checksum is not detected by Next, only by Matches
+	file[len(file)-1] ^= 1
+	undo, err = bparser.NewUndoReader(bytes.NewReader(file), &bparser.MainNet).Next()
+	if err != nil {
+		t.Fatalf("Next() returned error\nerror: %v\n", err)
+	} else if undo.Matches(block.Header.PrevBlock) {
+		t.Errorf("Matches() returned true for a corrupt checksum")
+	}
+
+	// a record without its checksum must fail
+	if _, err := bparser.NewUndoReader(bytes.NewReader(file[:len(file)-1]), &bparser.MainNet).Next(); err == nil {
+		t.Errorf("Next() expected an error for a truncated checksum")
+	}
+}
+
+func TestApplyUndo(t *testing.T) {
+	block, err := bparser.ParseBlock(buildBlock(geneisBlockDec[8:88], geneisBlockDec[89:], secondTx), 0)
+	if err != nil {
+		t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+	}
+	undo, err := bparser.ParseBlockUndo(undoRecord(1_000_000))
+	if err != nil {
+		t.Fatalf("ParseBlockUndo() returned error\nerror: %v\n", err)
+	}
+
+	if err := bparser.ApplyUndo(&block, undo); err != nil {
+		t.Fatalf("ApplyUndo() returned error\nerror: %v\n", err)
+	}
+
+	// outputs of the second tx pay 206,295 and 608,526 satoshis
+	tx := block.Tx.Tx[1]
+	if tx.Fee != 185_179 {
+		t.Errorf("ApplyUndo() got Fee = %d, want Fee = %d", tx.Fee, 185_179)
+	} else if want := float64(185_179) / float64(tx.VSize); tx.FeeRate != want {
+		t.Errorf("ApplyUndo() got FeeRate = %f, want FeeRate = %f", tx.FeeRate, want)
+	} else if tx.Inputs[0].PrevOut == nil || tx.Inputs[0].PrevOut.Amount != 1_000_000 {
+		t.Errorf("ApplyUndo() did not attach the spent output to the input")
+	}
+	if block.Tx.Tx[0].Fee != 0 || block.Tx.Tx[0].Inputs[0].PrevOut != nil {
+		t.Errorf("ApplyUndo() must not change the coinbase tx")
+	}
+
+	// the undo record of a different block must fail
+	coinbaseOnly, _ := bparser.ParseBlock(geneisBlockDec, 0)
+	if err := bparser.ApplyUndo(&coinbaseOnly, undo); err == nil {
+		t.Errorf("ApplyUndo() expected an error when the number of txs does not match")
+	}
+}
+
+func TestParseBlockUndoErrors(t *testing.T) {
+	record := undoRecord(1_000_000)
+
+	tests := []struct {
+		name   string
+		record []byte
+	}{
+		{name: "empty record", record: []byte{}},
+		{name: "truncated record", record: record[:len(record)-1]},
+		{name: "bytes left over", record: append(append([]byte{}, record...), 0)},
+		{name: "too many txs", record: []byte{0xfd, 0xff, 0xff}},
+		{name: "public key not in field", record: append([]byte{1, 1, 0, 0, 4}, bytes.Repeat([]byte{0xff}, 32)...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := bparser.ParseBlockUndo(tt.record); err == nil {
+				t.Errorf("ParseBlockUndo() expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package bparser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// key holding the height, best block, supply and UTXO count of the last checkpoint
+	utxoStateKey = 'S'
+)
+
+/*
+UTXOStats type is the state of a UTXOSet after a block has been connected.
+
+Supply is the total amount of all unspent outputs in satoshis and Count the number of unspent outputs,
+the same as total_amount and txouts of bitcoin-core's gettxoutsetinfo. Fees is the sum of the fees paid by the txs of the block.
+*/
+type UTXOStats struct {
+	Height    int
+	BlockHash Hash
+	Count     int64
+	Supply    Amount
+	Fees      Amount
+}
+
+/*
+UTXOSet type tracks unspent outputs by connecting blocks in height order, starting from the genesis block.
+
+Coins are stored in a LevelDB database using the same keys and coin encoding as bitcoin-core's chainstate, without obfuscation.
+Changes are kept in memory until Checkpoint is called, so the database always holds the UTXO set as of the last checkpoint,
+and opening an existing database resumes from there.
+
+Like bitcoin-core, the outputs of the genesis block and provably unspendable outputs, OP_RETURN or scripts larger than
+10,000 bytes, are never added to the set. Only the main chain is followed, blocks can not be disconnected.
+
+# Example
+
+	set, _ := OpenUTXOSet("utxo", &MainNet)
+	defer set.Close()
+	stats, _ := set.ConnectBlock(&block)
+*/
+type UTXOSet struct {
+	db    *leveldb.DB
+	net   *Network
+	stats UTXOStats
+
+	// coins added and spent since the last checkpoint, an outpoint is never in both
+	added map[OutPoint]Coin
+	spent map[OutPoint]struct{}
+
+	// coins added and spent by the block being connected, merged into added and spent once the whole block is connected
+	blockAdded map[OutPoint]Coin
+	blockSpent map[OutPoint]struct{}
+}
+
+/*
+OpenUTXOSet function opens the UTXO set database at path, creating it when it does not exist.
+*/
+func OpenUTXOSet(path string, net *Network) (*UTXOSet, error) {
+	db, err := leveldb.OpenFile(path, &opt.Options{})
+	if err != nil {
+		errMsg := fmt.Sprintf("can not open UTXO set at %s in OpenUTXOSet() function.\nerror: %v\n", path, err)
+		return nil, errors.New(errMsg)
+	}
+
+	s := &UTXOSet{
+		db:    db,
+		net:   net,
+		stats: UTXOStats{Height: -1},
+		added: make(map[OutPoint]Coin),
+		spent: make(map[OutPoint]struct{}),
+
+		blockAdded: make(map[OutPoint]Coin),
+		blockSpent: make(map[OutPoint]struct{}),
+	}
+
+	value, err := db.Get([]byte{utxoStateKey}, nil)
+	if err == leveldb.ErrNotFound {
+		return s, nil
+	} else if err != nil {
+		db.Close()
+		errMsg := fmt.Sprintf("can not read UTXO set state in OpenUTXOSet() function.\nerror: %v\n", err)
+		return nil, errors.New(errMsg)
+	}
+
+	// 32 byte best block, VARINT height + 1, VARINT supply, VARINT count
+	r := varIntReader{b: value}
+	hash := r.bytes(32)
+	s.stats.Height = int(r.varInt()) - 1
+	s.stats.Supply = Amount(r.varInt())
+	s.stats.Count = int64(r.varInt())
+	if r.err != nil {
+		db.Close()
+		errMsg := fmt.Sprintf("can not decode UTXO set state in OpenUTXOSet() function.\nerror: %v\n", r.err)
+		return nil, errors.New(errMsg)
+	}
+	s.stats.BlockHash = Hash(hash)
+
+	return s, nil
+}
+
+/*
+Close method writes a checkpoint and closes the database.
+*/
+func (s *UTXOSet) Close() error {
+	if err := s.Checkpoint(); err != nil {
+		s.db.Close()
+		return err
+	}
+	return s.db.Close()
+}
+
+/*
+Stats method returns the state of the set after the last connected block, Height is -1 before the genesis block is connected.
+*/
+func (s *UTXOSet) Stats() UTXOStats {
+	return s.stats
+}
+
+/*
+Get method returns the unspent output at outpoint, or ErrCoinNotFound when it is spent or never existed.
+*/
+func (s *UTXOSet) Get(outpoint OutPoint) (Coin, error) {
+	if coin, ok := s.added[outpoint]; ok {
+		return coin, nil
+	} else if _, ok := s.spent[outpoint]; ok {
+		return Coin{}, ErrCoinNotFound
+	}
+
+	value, err := s.db.Get(coinKey(outpoint), nil)
+	if err == leveldb.ErrNotFound {
+		return Coin{}, ErrCoinNotFound
+	} else if err != nil {
+		errMsg := fmt.Sprintf("can not read coin %s from UTXO set in Get() method.\nerror: %v\n", outpoint, err)
+		return Coin{}, errors.New(errMsg)
+	}
+
+	return ParseCoin(value)
+}
+
+/*
+ConnectBlock method spends the outputs used by the inputs of block and adds its new outputs.
+The block must be the child of the last connected block, or the genesis block of the network for an empty set.
+
+The spent outputs are attached to the inputs and the Fee and FeeRate of every tx are set, as ApplyUndo does.
+The changes of the block are only applied once every tx has been connected, so when an error is returned the set
+is left as it was after the last connected block.
+*/
+func (s *UTXOSet) ConnectBlock(block *BlockData) (UTXOStats, error) {
+	if s.stats.Height < 0 && block.Header.BlockHash != s.net.GenesisHash {
+		errMsg := fmt.Sprintf("expected genesis block %s but got %s in ConnectBlock() method\n", s.net.GenesisHash, block.Header.BlockHash)
+		return s.stats, errors.New(errMsg)
+	} else if s.stats.Height >= 0 && block.Header.PrevBlock != s.stats.BlockHash {
+		errMsg := fmt.Sprintf("block %s does not build on %s at height %d in ConnectBlock() method\n", block.Header.BlockHash, s.stats.BlockHash, s.stats.Height)
+		return s.stats, errors.New(errMsg)
+	}
+
+	stats := s.stats
+	stats.Height++
+	stats.BlockHash = block.Header.BlockHash
+	stats.Fees = 0
+	clear(s.blockAdded)
+	clear(s.blockSpent)
+
+	for i := range block.Tx.Tx {
+		tx := &block.Tx.Tx[i]
+		coinbase := i == 0
+
+		var in Amount
+		if !coinbase {
+			for j := range tx.Inputs {
+				outpoint := tx.Inputs[j].OutPoint()
+				coin, err := s.getStaged(outpoint)
+				if err != nil {
+					errMsg := fmt.Sprintf("input %d of tx %s spends %s in ConnectBlock() method.\nerror: %v\n", j, tx.TxId, outpoint, err)
+					return s.stats, errors.New(errMsg)
+				}
+
+				tx.Inputs[j].PrevOut = &coin
+				if in, err = in.Add(coin.Amount); err != nil {
+					errMsg := fmt.Sprintf("can not add input %d of tx %s in ConnectBlock() method.\nerror: %v\n", j, tx.TxId, err)
+					return s.stats, errors.New(errMsg)
+				}
+				delete(s.blockAdded, outpoint)
+				s.blockSpent[outpoint] = struct{}{}
+				stats.Count--
+				stats.Supply -= coin.Amount
+			}
+		}
+
+		// every output is checked to be in range, so the supply can not overflow
+		if coinbase {
+			if _, err := tx.OutputTotal(); err != nil {
+				return s.stats, err
+			}
+		} else if err := tx.setFee(in); err != nil {
+			return s.stats, err
+		} else {
+			stats.Fees += tx.Fee
+		}
+
+		for vout, output := range tx.Outputs {
+			amount := output.Amount
+			// the genesis coinbase can not be spent, bitcoin-core never adds it
+			if stats.Height == 0 || isUnspendable(output.ScriptPubKey) {
+				continue
+			}
+
+			outpoint := OutPoint{TxId: tx.TxId, Vout: uint32(vout)}
+			if coinbase {
+				// two coinbase txs were duplicated before BIP30, the later one overwrites the earlier outputs
+				if old, err := s.getStaged(outpoint); err == nil {
+					stats.Count--
+					stats.Supply -= old.Amount
+				} else if err != ErrCoinNotFound {
+					return s.stats, err
+				}
+			}
+
+			// copy the script so the set does not keep every block in memory until the next checkpoint
+			s.blockAdded[outpoint] = Coin{Height: stats.Height, Coinbase: coinbase, Amount: amount, ScriptPubKey: bytes.Clone(output.ScriptPubKey)}
+			delete(s.blockSpent, outpoint)
+			stats.Count++
+			stats.Supply += amount
+		}
+
+	}
+
+	for outpoint := range s.blockSpent {
+		delete(s.added, outpoint)
+		s.spent[outpoint] = struct{}{}
+	}
+	for outpoint, coin := range s.blockAdded {
+		s.added[outpoint] = coin
+		delete(s.spent, outpoint)
+	}
+	s.stats = stats
+	return stats, nil
+}
+
+/*
+getStaged method returns the unspent output at outpoint, including the changes of the block being connected.
+*/
+func (s *UTXOSet) getStaged(outpoint OutPoint) (Coin, error) {
+	if coin, ok := s.blockAdded[outpoint]; ok {
+		return coin, nil
+	} else if _, ok := s.blockSpent[outpoint]; ok {
+		return Coin{}, ErrCoinNotFound
+	}
+	return s.Get(outpoint)
+}
+
+/*
+Checkpoint method writes the changes since the last checkpoint to the database in a single batch.
+*/
+func (s *UTXOSet) Checkpoint() error {
+	batch := new(leveldb.Batch)
+	for outpoint := range s.spent {
+		batch.Delete(coinKey(outpoint))
+	}
+	for outpoint, coin := range s.added {
+		value := AppendVarInt(nil, uint64(coin.Height)<<1|boolBit(coin.Coinbase))
+		batch.Put(coinKey(outpoint), AppendCompressedTxOut(value, coin.Amount, coin.ScriptPubKey))
+	}
+
+	if s.stats.Height >= 0 {
+		state := AppendVarInt(s.stats.BlockHash[:], uint64(s.stats.Height+1))
+		state = AppendVarInt(state, uint64(s.stats.Supply))
+		state = AppendVarInt(state, uint64(s.stats.Count))
+		batch.Put([]byte{utxoStateKey}, state)
+	}
+
+	if err := s.db.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+		errMsg := fmt.Sprintf("can not write checkpoint at height %d in Checkpoint() method.\nerror: %v\n", s.stats.Height, err)
+		return errors.New(errMsg)
+	}
+
+	clear(s.added)
+	clear(s.spent)
+	return nil
+}
+
+/*
+ForEach method calls fn for every unspent output as of the last checkpoint, ordered by txid in internal byte order then by output index.
+Iteration stops at the first error returned by fn.
+*/
+func (s *UTXOSet) ForEach(fn func(OutPoint, Coin) error) error {
+	iter := s.db.NewIterator(util.BytesPrefix([]byte{coinPrefix}), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		outpoint, err := ParseCoinKey(iter.Key())
+		if err != nil {
+			return err
+		}
+		coin, err := ParseCoin(iter.Value())
+		if err != nil {
+			errMsg := fmt.Sprintf("can not decode coin %s in ForEach() method.\nerror: %v\n", outpoint, err)
+			return errors.New(errMsg)
+		}
+		if err := fn(outpoint, coin); err != nil {
+			return err
+		}
+	}
+
+	return iter.Error()
+}
+
+/*
+Replay method connects every main chain block of chain after the last connected block, reading each block from dir.
+Blocks are parsed in parallel with ParseMainChain and connected in height order until the tip or until ctx is cancelled.
+A checkpoint is written every checkpointEvery blocks and after the last block, including when replaying stops early,
+fn is called with the stats of every block.
+*/
+func (s *UTXOSet) Replay(ctx context.Context, dir *BlocksDir, chain *HeaderChain, checkpointEvery int, fn func(UTXOStats) error) error {
+	_, err := ParseMainChain(ctx, dir, chain, s.stats.Height+1, 0, func(block BlockData) error {
+		stats, err := s.ConnectBlock(&block)
+		if err != nil {
+			return err
+		}
+		if fn != nil {
+			if err := fn(stats); err != nil {
+				return err
+			}
+		}
+
+		if checkpointEvery > 0 && stats.Height%checkpointEvery == 0 {
+			return s.Checkpoint()
+		}
+		return nil
+	})
+	if err != nil {
+		// a block which failed to connect left the set unchanged, so the blocks before it can be kept
+		return errors.Join(err, s.Checkpoint())
+	}
+
+	return s.Checkpoint()
+}
+
+/*
+OutPoint method returns the output spent by the input.
+*/
+func (in TxInputs) OutPoint() OutPoint {
+	return OutPoint{TxId: in.TxId, Vout: in.Vout}
+}
+
+/*
+coinKey function returns the chainstate key of outpoint, see ParseCoinKey.
+*/
+func coinKey(outpoint OutPoint) []byte {
+	return AppendVarInt(hashKey(coinPrefix, outpoint.TxId), uint64(outpoint.Vout))
+}
+
+/*
+isUnspendable function returns true for outputs bitcoin-core never adds to the UTXO set, OP_RETURN and oversized scripts.
+*/
+func isUnspendable(script []byte) bool {
+	return (len(script) > 0 && script[0] == 0x6a) || len(script) > maxScriptSize
+}
+
+func boolBit(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
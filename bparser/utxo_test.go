@@ -0,0 +1,274 @@
+package bparser_test
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+var p2pkhScript = []byte{118, 169, 20, 65, 160, 218, 69, 116, 194, 64, 156, 150, 113, 176, 36, 245, 207, 103, 118, 106, 249, 119, 134, 136, 172}
+
+/*
+testHeader function returns a block header building on prevBlock.
+*/
+func testHeader(prevBlock bparser.Hash, nonce uint32) []byte {
+	header := binary.LittleEndian.AppendUint32(nil, 1)
+	header = append(header, prevBlock[:]...)
+	header = append(header, make([]byte, 32)...)
+	header = binary.LittleEndian.AppendUint32(header, 1231469665)
+	header = binary.LittleEndian.AppendUint32(header, 0x1d00ffff)
+	return binary.LittleEndian.AppendUint32(header, nonce)
+}
+
+/*
+testOutput function returns a serialized tx output.
+*/
+func testOutput(amount uint64, script []byte) []byte {
+	output := binary.LittleEndian.AppendUint64(nil, amount)
+	output = append(output, byte(len(script)))
+	return append(output, script...)
+}
+
+/*
+testTx function returns a legacy tx spending output vout of prevTx.
+A zero prevTx makes a coinbase tx with tag as its script sig.
+*/
+func testTx(prevTx bparser.Hash, vout uint32, tag byte, outputs ...[]byte) []byte {
+	tx := binary.LittleEndian.AppendUint32(nil, 1)
+	tx = append(tx, 1)
+	if prevTx.IsZero() {
+		tx = append(tx, make([]byte, 32)...)
+		tx = binary.LittleEndian.AppendUint32(tx, 0xffffffff)
+	} else {
+		tx = append(tx, prevTx[:]...)
+		tx = binary.LittleEndian.AppendUint32(tx, vout)
+	}
+	tx = append(tx, 2, 0x51, tag)
+	tx = binary.LittleEndian.AppendUint32(tx, 0xffffffff)
+	tx = append(tx, byte(len(outputs)))
+	for _, output := range outputs {
+		tx = append(tx, output...)
+	}
+	return binary.LittleEndian.AppendUint32(tx, 0)
+}
+
+/*
+testChain function returns four dat file records building on the mainnet genesis block, with the genesis block first.
+
+Block 1 has a coinbase paying 50 BTC and an OP_RETURN output, block 2 spends the 50 BTC paying a fee of 0.1 BTC,
+and block 3 repeats the coinbase of block 1 like the duplicate coinbase txs from before BIP30.
+*/
+func testChain(t *testing.T) [][]byte {
+	t.Helper()
+	coinbase1 := testTx(bparser.Hash{}, 0, 1, testOutput(5_000_000_000, p2pkhScript), testOutput(0, []byte{0x6a, 1, 0}))
+	block1 := buildBlock(testHeader(bparser.MainNet.GenesisHash, 1), coinbase1)
+	parsed1, err := bparser.ParseBlock(block1, 1)
+	if err != nil {
+		t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+	}
+
+	coinbase2 := testTx(bparser.Hash{}, 0, 2, testOutput(5_010_000_000, p2pkhScript))
+	spend := testTx(parsed1.Tx.Tx[0].TxId, 0, 0, testOutput(3_000_000_000, p2pkhScript), testOutput(1_990_000_000, p2pkhScript))
+	block2 := buildBlock(testHeader(parsed1.Header.BlockHash, 2), coinbase2, spend)
+	parsed2, err := bparser.ParseBlock(block2, 2)
+	if err != nil {
+		t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+	}
+
+	block3 := buildBlock(testHeader(parsed2.Header.BlockHash, 3), coinbase1)
+	return [][]byte{geneisBlockDec, block1, block2, block3}
+}
+
+var testChainStats = []bparser.UTXOStats{
+	{Height: 0, Count: 0, Supply: 0},
+	{Height: 1, Count: 1, Supply: 5_000_000_000},
+	{Height: 2, Count: 3, Supply: 5_010_000_000 + 3_000_000_000 + 1_990_000_000, Fees: 10_000_000},
+	// the duplicate coinbase adds the output of block 1 again since it was spent, the OP_RETURN is skipped again
+	{Height: 3, Count: 4, Supply: 15_000_000_000},
+}
+
+func TestUTXOSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "utxo")
+	set, err := bparser.OpenUTXOSet(path, &bparser.MainNet)
+	if err != nil {
+		t.Fatalf("OpenUTXOSet() returned error\nerror: %v\n", err)
+	}
+
+	var spendTx bparser.TxData
+	for i, blk := range testChain(t)[:3] {
+		block, err := bparser.ParseBlock(blk, i)
+		if err != nil {
+			t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+		}
+
+		stats, err := set.ConnectBlock(&block)
+		if err != nil {
+			t.Fatalf("ConnectBlock() returned error at height %d\nerror: %v\n", i, err)
+		}
+		want := testChainStats[i]
+		want.BlockHash = block.Header.BlockHash
+		if stats != want {
+			t.Errorf("ConnectBlock() got stats = %+v, want %+v", stats, want)
+		}
+		if i == 2 {
+			spendTx = block.Tx.Tx[1]
+		}
+	}
+
+	if spendTx.Fee != 10_000_000 {
+		t.Errorf("ConnectBlock() got Fee = %d, want Fee = %d", spendTx.Fee, 10_000_000)
+	} else if spendTx.Inputs[0].PrevOut == nil || spendTx.Inputs[0].PrevOut.Height != 1 || !spendTx.Inputs[0].PrevOut.Coinbase {
+		t.Errorf("ConnectBlock() did not attach the spent coinbase output to the input")
+	}
+
+	// the spent coinbase output is gone before and after the checkpoint
+	outpoint := spendTx.Inputs[0].OutPoint()
+	if _, err := set.Get(outpoint); err != bparser.ErrCoinNotFound {
+		t.Errorf("Get() expected ErrCoinNotFound for a spent output but got %v", err)
+	}
+	if err := set.Close(); err != nil {
+		t.Fatalf("Close() returned error\nerror: %v\n", err)
+	}
+
+	set, err = bparser.OpenUTXOSet(path, &bparser.MainNet)
+	if err != nil {
+		t.Fatalf("OpenUTXOSet() returned error\nerror: %v\n", err)
+	}
+	defer set.Close()
+
+	if stats := set.Stats(); stats.Height != 2 || stats.Count != testChainStats[2].Count || stats.Supply != testChainStats[2].Supply {
+		t.Errorf("Stats() after reopening got %+v, want %+v", stats, testChainStats[2])
+	}
+	if _, err := set.Get(outpoint); err != bparser.ErrCoinNotFound {
+		t.Errorf("Get() expected ErrCoinNotFound for a spent output but got %v", err)
+	}
+	coin, err := set.Get(bparser.OutPoint{TxId: spendTx.TxId, Vout: 1})
+	if err != nil {
+		t.Fatalf("Get() returned error\nerror: %v\n", err)
+	} else if coin.Height != 2 || coin.Coinbase || coin.Amount != 1_990_000_000 {
+		t.Errorf("Get() got coin = %+v", coin)
+	}
+
+	count := 0
+	if err := set.ForEach(func(bparser.OutPoint, bparser.Coin) error { count++; return nil }); err != nil || count != 3 {
+		t.Errorf("ForEach() got %d coins, want 3\nerror: %v\n", count, err)
+	}
+
+	// a block which does not build on the tip must fail
+	genesis, _ := bparser.ParseBlock(geneisBlockDec, 0)
+	if _, err := set.ConnectBlock(&genesis); err == nil {
+		t.Errorf("ConnectBlock() expected an error for a block not building on the tip")
+	}
+}
+
+func TestUTXOSetConnectBlockError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "utxo")
+	set, err := bparser.OpenUTXOSet(path, &bparser.MainNet)
+	if err != nil {
+		t.Fatalf("OpenUTXOSet() returned error\nerror: %v\n", err)
+	}
+
+	chain := testChain(t)
+	var block1 bparser.BlockData
+	for i, blk := range chain[:2] {
+		block, err := bparser.ParseBlock(blk, i)
+		if err != nil {
+			t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+		}
+		if _, err := set.ConnectBlock(&block); err != nil {
+			t.Fatalf("ConnectBlock() returned error at height %d\nerror: %v\n", i, err)
+		}
+		block1 = block
+	}
+	if err := set.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint() returned error\nerror: %v\n", err)
+	}
+
+	// the first tx spends the coinbase output of block 1 and the second an output which does not exist
+	coinbaseOut := bparser.OutPoint{TxId: block1.Tx.Tx[0].TxId, Vout: 0}
+	blk := buildBlock(testHeader(block1.Header.BlockHash, 2),
+		testTx(bparser.Hash{}, 0, 2, testOutput(5_000_000_000, p2pkhScript)),
+		testTx(coinbaseOut.TxId, 0, 0, testOutput(4_000_000_000, p2pkhScript)),
+		testTx(bparser.Hash{9}, 0, 0, testOutput(1, p2pkhScript)))
+	block2, err := bparser.ParseBlock(blk, 2)
+	if err != nil {
+		t.Fatalf("ParseBlock() returned error\nerror: %v\n", err)
+	}
+	if _, err := set.ConnectBlock(&block2); err == nil {
+		t.Fatalf("ConnectBlock() expected an error for an input spending a missing output")
+	}
+
+	// nothing of the failed block is applied, before or after closing
+	if _, err := set.Get(coinbaseOut); err != nil {
+		t.Errorf("Get() after a failed ConnectBlock() returned error\nerror: %v\n", err)
+	} else if stats := set.Stats(); stats.Height != 1 || stats.Count != testChainStats[1].Count || stats.Supply != testChainStats[1].Supply {
+		t.Errorf("Stats() after a failed ConnectBlock() got %+v, want %+v", stats, testChainStats[1])
+	}
+	if err := set.Close(); err != nil {
+		t.Fatalf("Close() returned error\nerror: %v\n", err)
+	}
+
+	set, err = bparser.OpenUTXOSet(path, &bparser.MainNet)
+	if err != nil {
+		t.Fatalf("OpenUTXOSet() returned error\nerror: %v\n", err)
+	}
+	defer set.Close()
+	if stats := set.Stats(); stats.Height != 1 || stats.Count != testChainStats[1].Count || stats.Supply != testChainStats[1].Supply {
+		t.Errorf("Stats() after reopening got %+v, want %+v", stats, testChainStats[1])
+	}
+	if coin, err := set.Get(coinbaseOut); err != nil || coin.Amount != 5_000_000_000 {
+		t.Errorf("Get() after reopening got %+v with error %v, want the coinbase output of block 1", coin, err)
+	}
+	if _, err := set.Get(bparser.OutPoint{TxId: block2.Tx.Tx[1].TxId, Vout: 0}); err != bparser.ErrCoinNotFound {
+		t.Errorf("Get() of an output of the failed block got error %v, want ErrCoinNotFound", err)
+	}
+}
+
+func TestUTXOSetReplay(t *testing.T) {
+	var file []byte
+	for _, blk := range testChain(t) {
+		file = append(file, blk...)
+	}
+	blocksPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(blocksPath, "blk00000.dat"), file, 0o644); err != nil {
+		t.Fatalf("can not write blk00000.dat\nerror: %v\n", err)
+	}
+	blocksDir, err := bparser.OpenBlocksDir(blocksPath)
+	if err != nil {
+		t.Fatalf("OpenBlocksDir() returned error\nerror: %v\n", err)
+	}
+	chain, err := bparser.BuildHeaderChain(blocksDir, &bparser.MainNet)
+	if err != nil {
+		t.Fatalf("BuildHeaderChain() returned error\nerror: %v\n", err)
+	}
+
+	set, err := bparser.OpenUTXOSet(filepath.Join(t.TempDir(), "utxo"), &bparser.MainNet)
+	if err != nil {
+		t.Fatalf("OpenUTXOSet() returned error\nerror: %v\n", err)
+	}
+	defer set.Close()
+
+	var got []bparser.UTXOStats
+	err = set.Replay(context.Background(), blocksDir, chain, 2, func(stats bparser.UTXOStats) error {
+		stats.BlockHash = bparser.Hash{}
+		got = append(got, stats)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay() returned error\nerror: %v\n", err)
+	} else if !slices.Equal(got, testChainStats) {
+		t.Errorf("Replay() got stats %+v, want %+v", got, testChainStats)
+	}
+
+	// replaying again has no blocks left to connect
+	if err := set.Replay(context.Background(), blocksDir, chain, 2, nil); err != nil {
+		t.Errorf("Replay() returned error\nerror: %v\n", err)
+	} else if set.Stats().Height != 3 {
+		t.Errorf("Stats() got Height = %d, want 3", set.Stats().Height)
+	}
+}
@@ -0,0 +1,100 @@
+package bparser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+/*
+ReadVarInt function reads bitcoin-core's VARINT encoding, which is used in the LevelDB databases and undo files.
+This is not the same as the compact size used in blocks, see ParseTransactionBlockSize.
+
+Each byte holds 7 bits of the number, most significant group first, and the high bit is set on every byte except the last.
+One is added to every group except the last so each number has exactly one encoding.
+
+Returns the number and the number of bytes read.
+*/
+func ReadVarInt(b []byte) (uint64, int, error) {
+	var n uint64
+	for i := 0; i < len(b); i++ {
+		if n > math.MaxUint64>>7 {
+			return 0, -1, errors.New("varint is too large in ReadVarInt() function")
+		}
+		n = (n << 7) | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return n, i + 1, nil
+		}
+		if n == math.MaxUint64 {
+			return 0, -1, errors.New("varint is too large in ReadVarInt() function")
+		}
+		n++
+	}
+
+	errMsg := fmt.Sprintf("varint is truncated after %d bytes in ReadVarInt() function\n", len(b))
+	return 0, -1, errors.New(errMsg)
+}
+
+/*
+AppendVarInt function appends n to b using bitcoin-core's VARINT encoding, see ReadVarInt.
+*/
+func AppendVarInt(b []byte, n uint64) []byte {
+	var tmp [10]byte
+	i := len(tmp) - 1
+	tmp[i] = byte(n & 0x7f)
+	for n > 0x7f {
+		n = (n >> 7) - 1
+		i--
+		tmp[i] = byte(n&0x7f) | 0x80
+	}
+	return append(b, tmp[i:]...)
+}
+
+/*
+varIntReader type reads consecutive values from a bitcoin-core serialized record, keeping the first error.
+*/
+type varIntReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *varIntReader) varInt() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	n, size, err := ReadVarInt(r.b[r.pos:])
+	if err != nil {
+		r.err = err
+		return 0
+	}
+	r.pos += size
+	return n
+}
+
+func (r *varIntReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.b)-r.pos < n {
+		errMsg := fmt.Sprintf("can not read %d bytes at index %d, record has %d bytes\n", n, r.pos, len(r.b))
+		r.err = errors.New(errMsg)
+		return nil
+	}
+	b := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *varIntReader) compactSize() int64 {
+	if r.err != nil {
+		return 0
+	}
+	n, size, err := ParseTransactionBlockSize(r.b[r.pos:])
+	if err != nil {
+		r.err = err
+		return 0
+	}
+	r.pos += size
+	return n
+}
@@ -0,0 +1,54 @@
+package bparser_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/davidhintelmann/blockchain/bparser"
+)
+
+/*
+test vectors from the comment on VARINT in bitcoin-core's serialize.h
+*/
+func TestVarInt(t *testing.T) {
+	tests := []struct {
+		n       uint64
+		encoded []byte
+	}{
+		{n: 0, encoded: []byte{0x00}},
+		{n: 1, encoded: []byte{0x01}},
+		{n: 127, encoded: []byte{0x7f}},
+		{n: 128, encoded: []byte{0x80, 0x00}},
+		{n: 255, encoded: []byte{0x80, 0x7f}},
+		{n: 256, encoded: []byte{0x81, 0x00}},
+		{n: 16383, encoded: []byte{0xfe, 0x7f}},
+		{n: 16384, encoded: []byte{0xff, 0x00}},
+		{n: 16511, encoded: []byte{0xff, 0x7f}},
+		{n: 65535, encoded: []byte{0x82, 0xfe, 0x7f}},
+		{n: 1 << 32, encoded: []byte{0x8e, 0xfe, 0xfe, 0xff, 0x00}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.n), func(t *testing.T) {
+			if got := bparser.AppendVarInt(nil, tt.n); !bytes.Equal(got, tt.encoded) {
+				t.Errorf("AppendVarInt(%d) got = %X, want %X", tt.n, got, tt.encoded)
+			}
+
+			// trailing bytes belong to the next value and must not be read
+			got, size, err := bparser.ReadVarInt(append(bytes.Clone(tt.encoded), 0xab))
+			if err != nil {
+				t.Errorf("ReadVarInt(%X) returned error\nerror: %v\n", tt.encoded, err)
+			} else if got != tt.n || size != len(tt.encoded) {
+				t.Errorf("ReadVarInt(%X) got = %d using %d bytes, want %d using %d bytes", tt.encoded, got, size, tt.n, len(tt.encoded))
+			}
+		})
+	}
+
+	if _, _, err := bparser.ReadVarInt([]byte{0x80, 0x80}); err == nil {
+		t.Errorf("ReadVarInt() expected an error for a truncated varint")
+	}
+	if _, _, err := bparser.ReadVarInt(bytes.Repeat([]byte{0xff}, 11)); err == nil {
+		t.Errorf("ReadVarInt() expected an error for a varint larger than 64 bits")
+	}
+}
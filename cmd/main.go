@@ -1,65 +1,434 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"time"
 
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 
 	"github.com/davidhintelmann/blockchain/bparser"
+	"github.com/davidhintelmann/blockchain/bparser/export"
+	"github.com/davidhintelmann/blockchain/bparser/index"
 )
 
 const (
-	gensisBlock     = "6FE28C0AB6F1B372C1A6A246AE63F74F931E8365E15A089C68D6190000000000"
-	gensisBlockSwap = "000000000019D6689C085AE165831E934FF763AE46A2A6C172B3F1B60A8CE26F"
-	blocksFilePath  = "C:\\Users\\david\\OneDrive\\Documents\\code\\python\\Blockchain\\Bitcoin\\data\\bitcoin_data\\"
-	blk00000Height  = 119_965
+	blocksFilePath = "C:\\Users\\david\\OneDrive\\Documents\\code\\python\\Blockchain\\Bitcoin\\data\\bitcoin_data\\"
 )
 
 func main() {
-	fmt.Printf("Gensis Block Hash: %s\nGensis Block Swap: %s\n", gensisBlock, gensisBlockSwap)
-	// two lines below are not necessary
-	fmt.Println(bparser.ByteSwapStr(gensisBlock))
-	fmt.Println(bparser.ByteSwapArray(gensisBlock))
+	networkName := flag.String("network", "main", "network of the dat files: main, testnet3, testnet4, signet or regtest")
+	dataDir := flag.String("datadir", "", "bitcoin-core data directory, blocks are read from the network's blocks folder within it")
+	height := flag.Int("height", -1, "read only the block at this height using the blocks/index LevelDB database")
+	jsonOut := flag.Bool("json", false, "with -height, write the block as JSON in the shape of bitcoin-cli getblock <hash> 2")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of goroutines parsing blocks in parallel")
+	headersOnly := flag.Bool("headers", false, "scan only the block headers of every blk file, skipping the transactions")
+	verifyPow := flag.Bool("verifypow", false, "verify the proof of work and difficulty retargets of every block in the header chain")
+	utxoSet := flag.String("utxoset", "", "replay the main chain into a UTXO set stored at this path, resuming from its last checkpoint")
+	utxo := flag.String("utxo", "", "look up an unspent output written as txid:vout in the chainstate LevelDB database of -datadir")
+	exportFormat := flag.String("export", "", "export the main chain as tables to -out, as parquet, csv or tsv files")
+	exportDir := flag.String("out", "export", "folder -export writes its tables to")
+	witnesses := flag.Bool("witnesses", false, "with -export, also write the witnesses table")
+	indexPath := flag.String("index", "", "add the main chain blocks after its tip to the SQLite index at this path, creating it if needed")
+	txIndexPath := flag.String("txindex", "", "add the main chain blocks after its last checkpoint to the tx index at this path, creating it if needed")
+	txLookup := flag.String("tx", "", "with -txindex, write the tx with this txid as JSON in the shape of a tx of bitcoin-cli getblock <hash> 2")
+	flag.Parse()
 
-	fmt.Println(filepath.Dir(blocksFilePath))
-
-	matches, err := bparser.GlobDat(blocksFilePath)
+	net, err := bparser.NetworkByName(*networkName)
 	if err != nil {
 		log.Fatalf("error: %v\n", err)
 	}
 
-	fileStart := time.Now()
-	file, err := os.Open(matches[0])
+	blocksPath := blocksFilePath
+	if *dataDir != "" {
+		blocksPath = filepath.Join(*dataDir, net.DataDirSubfolder, "blocks")
+	}
+
+	// JSON is written to stdout on its own so it can be piped
+	jsonOnly := *jsonOut || *txLookup != ""
+	if !jsonOnly {
+		fmt.Printf("Network: %s\nGensis Block Hash: %s\n", net.Name, net.GenesisHash)
+	}
+
+	if *utxo != "" {
+		// chainstate sits beside the blocks folder
+		lookupUTXO(filepath.Join(filepath.Dir(blocksPath), "chainstate"), *utxo)
+		return
+	}
+	if !jsonOnly {
+		fmt.Println(filepath.Dir(blocksPath))
+	}
+
+	// blocks directory loads xor.dat so obfuscated dat files are read transparently
+	blocksDir, err := bparser.OpenBlocksDir(blocksPath)
 	if err != nil {
 		log.Fatalf("error: %v\n", err)
 	}
-	defer file.Close()
 
-	readAll, err := io.ReadAll(file)
+	if *height >= 0 {
+		readBlockAtHeight(blocksDir, net, *height, *jsonOut)
+		return
+	}
+
+	if *txLookup != "" {
+		if *txIndexPath == "" {
+			log.Fatalf("error: -tx needs -txindex\n")
+		}
+		writeTxJSON(blocksDir, net, *txIndexPath, *txLookup)
+		return
+	}
+
+	matches, err := blocksDir.BlockFiles()
 	if err != nil {
 		log.Fatalf("error: %v\n", err)
+	} else if len(matches) == 0 {
+		log.Fatalf("error: no blk*.dat files in %s\n", blocksPath)
 	}
-	fmt.Printf("duration of file read: %v\n", time.Since(fileStart))
 
-	// fmt.Println(readAll[:295])
-	blkFileLen := len(readAll)
+	if *headersOnly {
+		scanHeaders(blocksDir, net, matches)
+		return
+	}
+
+	// link headers across all files to find the true height of every block
+	chainStart := time.Now()
+	chain, err := bparser.BuildHeaderChain(blocksDir, net)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	mainChain, stale, orphan := chain.Counts()
 	p := message.NewPrinter(language.English)
-	p.Printf("block file length: %d\n", blkFileLen)
-	p.Printf("block height: %d\n", blk00000Height)
-	fmt.Println()
+	fmt.Printf("duration of building header chain: %v\n", time.Since(chainStart))
+	p.Printf("chain tip: %s at height %d\n", chain.Tip().Header.BlockHash, chain.Tip().Height)
+	p.Printf("main chain blocks: %d, stale blocks: %d, orphaned blocks: %d\n", mainChain, stale, orphan)
+	p.Printf("tip difficulty: %.2f, chain work: %x\n", chain.Tip().Header.Difficulty, chain.Tip().ChainWork)
+
+	if *verifyPow {
+		verifyStart := time.Now()
+		if err := bparser.VerifyHeaderChain(chain); err != nil {
+			log.Fatalf("error: %v\n", err)
+		}
+		fmt.Printf("duration of verifying proof of work: %v\n", time.Since(verifyStart))
+	}
+
+	if *utxoSet != "" {
+		replayUTXOSet(blocksDir, chain, net, *utxoSet)
+		return
+	}
+
+	if *txIndexPath != "" {
+		replayTxIndex(blocksDir, chain, net, *txIndexPath)
+		return
+	}
+
+	if *indexPath != "" {
+		updateIndex(blocksDir, chain, *indexPath)
+		return
+	}
+
+	if *exportFormat != "" {
+		exportTables(blocksDir, chain, *exportFormat, *exportDir, *workers, *witnesses)
+		return
+	}
+
+	// parse every main chain block in height order, ctrl-c stops the workers
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
+	fmt.Println()
+	var stats bparser.ParseStats
 	parseStart := time.Now()
-	blockHeight, err := bparser.ParseBlocks(readAll, 0, blk00000Height, []byte{0})
+	stats, err = bparser.ParseMainChain(ctx, blocksDir, chain, 0, *workers, func(block bparser.BlockData) error {
+		if block.BlockNumber%10_000 == 0 {
+			elapsed := time.Since(parseStart).Seconds()
+			p.Printf("height %d, %.0f blocks/s\n", block.BlockNumber, float64(block.BlockNumber+1)/elapsed)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	fmt.Printf("duration of parsing %d dat files: %v\n", stats.Files, stats.Elapsed)
+	p.Printf("parsed %d blocks and %d txs, %.0f blocks/s, %.1f MB/s\n", stats.Blocks, stats.Txs, stats.BlocksPerSecond(), stats.MBPerSecond())
+}
+
+// readBlockAtHeight looks up the block in the block index and seeks straight to it in its blk file.
+func readBlockAtHeight(blocksDir *bparser.BlocksDir, net *bparser.Network, height int, jsonOut bool) {
+	index, err := bparser.OpenBlockIndex(filepath.Join(blocksDir.Path, "index"), net)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	defer index.Close()
+
+	readStart := time.Now()
+	entry, err := index.ByHeight(height)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	block, err := index.ReadBlock(blocksDir, entry)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	if jsonOut {
+		writeBlockJSON(blocksDir, index, entry, block)
+		return
+	}
+
+	p := message.NewPrinter(language.English)
+	fmt.Printf("duration of reading block from index: %v\n", time.Since(readStart))
+	p.Printf("block %d: %s\n", block.BlockNumber, block.Header.BlockHash)
+	p.Printf("stored in blk%05d.dat at offset %d\n", entry.FileNum, block.FileOffset)
+	p.Printf("timestamp: %v, size: %d, number of tx: %d\n", block.Header.Timestamp, block.Size, block.Tx.TxCount)
+}
+
+// writeBlockJSON writes block to stdout as getblock JSON, with tx fees when the undo data of the block is stored.
+func writeBlockJSON(blocksDir *bparser.BlocksDir, index *bparser.BlockIndex, entry bparser.DiskBlockIndex, block bparser.BlockData) {
+	chain, err := index.HeaderChain()
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	if entry.HaveUndo() {
+		undo, err := index.ReadUndo(blocksDir, entry)
+		if err != nil {
+			log.Fatalf("error: %v\n", err)
+		}
+		if err := bparser.ApplyUndo(&block, undo); err != nil {
+			log.Fatalf("error: %v\n", err)
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(bparser.NewBlockJSON(block, chain)); err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+}
+
+// lookupUTXO prints the unspent output at outpoint from the chainstate database.
+func lookupUTXO(path string, outpoint string) {
+	out, err := bparser.ParseOutPoint(outpoint)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	chainstate, err := bparser.OpenChainstate(path)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	defer chainstate.Close()
+
+	best, err := chainstate.BestBlock()
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	fmt.Printf("chainstate best block: %s\n", best)
+
+	coin, err := chainstate.Get(out)
+	if err == bparser.ErrCoinNotFound {
+		fmt.Printf("%s is spent or does not exist\n", out)
+		return
+	} else if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	p := message.NewPrinter(language.English)
+	p.Printf("%s created at height %d, coinbase: %v\n", out, coin.Height, coin.Coinbase)
+	p.Printf("amount: %s, script pub key: %X\n", coin.Amount, coin.ScriptPubKey)
+}
+
+// replayUTXOSet connects every main chain block to the UTXO set at path and reports its supply every 10,000 blocks.
+func replayUTXOSet(blocksDir *bparser.BlocksDir, chain *bparser.HeaderChain, net *bparser.Network, path string) {
+	set, err := bparser.OpenUTXOSet(path, net)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	p := message.NewPrinter(language.English)
+	p.Printf("resuming UTXO set after height %d\n", set.Stats().Height)
+
+	replayStart := time.Now()
+	err = set.Replay(ctx, blocksDir, chain, 10_000, func(stats bparser.UTXOStats) error {
+		if stats.Height%10_000 == 0 {
+			p.Printf("height %d: %d utxos, supply %s, block fees %s\n", stats.Height, stats.Count, stats.Supply, stats.Fees)
+		}
+		return nil
+	})
+	// close before exiting, log.Fatalf does not run deferred calls and the blocks since the last checkpoint would be lost
+	if closeErr := set.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	stats := set.Stats()
+	fmt.Printf("duration of replaying UTXO set: %v\n", time.Since(replayStart))
+	p.Printf("tip %s at height %d: %d utxos, supply %s\n", stats.BlockHash, stats.Height, stats.Count, stats.Supply)
+}
+
+// updateIndex brings the SQLite index up to the chain tip, removing blocks a reorg took off the main chain first.
+func updateIndex(blocksDir *bparser.BlocksDir, chain *bparser.HeaderChain, path string) {
+	ix, err := index.Open(path)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	defer ix.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	p := message.NewPrinter(language.English)
+	p.Printf("updating index after height %d\n", ix.Tip().Height)
+	updateStart := time.Now()
+	stats, err := ix.Update(ctx, blocksDir, chain, 0, func(block index.Block) error {
+		if block.Height%10_000 == 0 {
+			p.Printf("indexed height %d\n", block.Height)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	fmt.Printf("duration of updating index: %v\n", time.Since(updateStart))
+	p.Printf("indexed %d blocks and %d txs, tip at height %d\n", stats.Blocks, stats.Txs, ix.Tip().Height)
+}
+
+// exportTables writes every main chain block as normalized tables, see bparser/export/SCHEMA.md for the columns.
+func exportTables(blocksDir *bparser.BlocksDir, chain *bparser.HeaderChain, format string, dir string, workers int, witnesses bool) {
+	var w export.Writer
+	var err error
+	switch format {
+	case "parquet":
+		w, err = export.NewParquetWriter(dir, export.ParquetOptions{Witnesses: witnesses})
+	case "csv":
+		w, err = export.NewCSVWriter(dir, export.CSVOptions{Witnesses: witnesses})
+	case "tsv":
+		w, err = export.NewCSVWriter(dir, export.CSVOptions{Comma: '\t', Witnesses: witnesses})
+	default:
+		log.Fatalf("error: unknown export format %s\n", format)
+	}
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	p := message.NewPrinter(language.English)
+	exportStart := time.Now()
+	stats, err := bparser.ParseMainChain(ctx, blocksDir, chain, 0, workers, func(block bparser.BlockData) error {
+		if block.BlockNumber%10_000 == 0 {
+			p.Printf("exporting height %d\n", block.BlockNumber)
+		}
+		return w.WriteBlock(&block)
+	})
+	// close even when parsing stopped early, so the last partition has its footer and can be read
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		log.Fatalf("error: %v\n", err)
 	}
-	fmt.Printf("duration of parsing single dat file: %v\n", time.Since(parseStart))
-	p.Printf("total blocks %d\n", blk00000Height)
-	p.Printf("parsed %d blocks\n", blockHeight)
+
+	fmt.Printf("duration of exporting to %s: %v\n", dir, time.Since(exportStart))
+	p.Printf("exported %d blocks and %d txs\n", stats.Blocks, stats.Txs)
+}
+
+// replayTxIndex adds the position of every tx of the main chain blocks after its last checkpoint to the tx index at path.
+func replayTxIndex(blocksDir *bparser.BlocksDir, chain *bparser.HeaderChain, net *bparser.Network, path string) {
+	txIndex, err := bparser.OpenTxIndex(path, net)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	p := message.NewPrinter(language.English)
+	p.Printf("resuming tx index after height %d\n", txIndex.Height())
+
+	replayStart := time.Now()
+	err = txIndex.Replay(ctx, blocksDir, chain, 10_000, func(height int) error {
+		if height%10_000 == 0 {
+			p.Printf("tx index at height %d\n", height)
+		}
+		return nil
+	})
+	// close before exiting, log.Fatalf does not run deferred calls and positions since the last checkpoint would be lost
+	if closeErr := txIndex.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	fmt.Printf("duration of replaying tx index: %v\n", time.Since(replayStart))
+	p.Printf("tx index at height %d\n", txIndex.Height())
+}
+
+// writeTxJSON reads the tx with txid straight from its blk file using the tx index at path and writes it to stdout as JSON.
+func writeTxJSON(blocksDir *bparser.BlocksDir, net *bparser.Network, path string, txid string) {
+	hash, err := bparser.ParseHash(txid)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	txIndex, err := bparser.OpenTxIndex(path, net)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	defer txIndex.Close()
+
+	tx, err := txIndex.GetTransaction(blocksDir, hash)
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(bparser.NewTxJSON(tx)); err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+}
+
+// scanHeaders reads the header of every block in the blk files without decoding any transactions.
+func scanHeaders(blocksDir *bparser.BlocksDir, net *bparser.Network, matches []string) {
+	p := message.NewPrinter(language.English)
+	scanStart := time.Now()
+	var blockCount, txCount int
+	for _, match := range matches {
+		file, err := blocksDir.Open(match)
+		if err != nil {
+			log.Fatalf("error: %v\n", err)
+		}
+
+		headers, err := bparser.ParseHeaders(file, net)
+		file.Close()
+		if err != nil {
+			log.Fatalf("error: %v\n", err)
+		}
+
+		blockCount += len(headers)
+		for _, header := range headers {
+			txCount += int(header.TxCount)
+		}
+	}
+
+	fmt.Printf("duration of scanning headers: %v\n", time.Since(scanStart))
+	p.Printf("scanned %d headers in %d files, holding %d txs\n", blockCount, len(matches), txCount)
 }